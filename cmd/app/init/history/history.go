@@ -0,0 +1,109 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"proxy/internal/api/usecase"
+	"proxy/internal/models"
+)
+
+const usage = `usage: app export [flags] <file>
+       app import [flags] <file>...
+
+export writes the history of a project to a JSON lines file, import adds
+such files to it, skipping exchanges it already holds.
+
+flags:
+  -project  project id, the active project by default
+  -filter   (export) filter expression, e.g. host ~ "api\." and resp.status >= 500
+`
+
+// Run executes `app export` and `app import`, args[0] being the command.
+func Run(ctx context.Context, args []string, uc usecase.Usecase, out io.Writer) error {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() { fmt.Fprint(out, usage) }
+	project := fs.Uint64("project", 0, "project id")
+	expression := fs.String("filter", "", "filter expression")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing file")
+	}
+
+	projectId, err := resolveProject(ctx, uc, *project)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "export":
+		if fs.NArg() > 1 {
+			return errors.New("export writes a single file")
+		}
+		filter := models.RequestFilter{ProjectId: projectId, Expression: *expression}
+		return runExport(ctx, uc, filter, fs.Arg(0), out)
+	case "import":
+		if *expression != "" {
+			return errors.New("-filter only applies to export")
+		}
+		for _, path := range fs.Args() {
+			if err := runImport(ctx, uc, projectId, path, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func resolveProject(ctx context.Context, uc usecase.Usecase, id uint64) (uint64, error) {
+	if id == 0 {
+		return uc.ActiveProject(ctx)
+	}
+	if _, err := uc.GetProject(ctx, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func runExport(ctx context.Context, uc usecase.Usecase, filter models.RequestFilter, path string, out io.Writer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	n, err := uc.ExportJSONL(ctx, filter, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("exporting to %s: %w", path, err)
+	}
+	fmt.Fprintf(out, "exported %d exchange(s) to %s\n", n, path)
+	return nil
+}
+
+func runImport(ctx context.Context, uc usecase.Usecase, projectId uint64, path string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	result, err := uc.ImportJSONL(ctx, projectId, f)
+	fmt.Fprintf(out, "%s: imported %d exchange(s), skipped %d already present\n", path, result.Imported, result.Skipped)
+	if err != nil {
+		return fmt.Errorf("importing %s: %w", path, err)
+	}
+	return nil
+}
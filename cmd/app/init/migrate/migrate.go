@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"proxy/internal/migrations"
+)
+
+const usage = `usage: app migrate <command> [flags]
+
+commands:
+  up      apply every pending migration
+  down    revert the latest migration (-steps N for more, -all for every one)
+  status  list migrations and whether they are applied
+`
+
+// Run executes `app migrate <command>`.
+func Run(ctx context.Context, args []string, m *migrations.Migrator, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing migrate command")
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", n)
+		return nil
+	case "down":
+		return runDown(ctx, args[1:], m, out)
+	case "status":
+		return runStatus(ctx, m, out)
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func runDown(ctx context.Context, args []string, m *migrations.Migrator, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to revert")
+	all := fs.Bool("all", false, "revert every applied migration")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *all {
+		*steps = -1
+	} else if *steps <= 0 {
+		return fmt.Errorf("-steps must be positive")
+	}
+
+	n, err := m.Down(ctx, *steps)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "reverted %d migration(s)\n", n)
+	return nil
+}
+
+func runStatus(ctx context.Context, m *migrations.Migrator, out io.Writer) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		state := "pending"
+		if s.AppliedAt != nil {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(out, "%04d  %-30s  %s\n", s.Version, s.Name, state)
+	}
+	return nil
+}
@@ -2,9 +2,12 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	handler "proxy/internal/api/handler/http"
 	"proxy/pkg/config"
 	"proxy/pkg/logger"
@@ -24,11 +27,45 @@ func NewServer(mainCtx context.Context, cfg *config.Config, r *gin.Engine, log l
 	// Set up /api router group
 	api := r.Group("/api")
 
-	api.GET("/requests", h.GetRequests)
-	api.GET("/requests/:id", h.GetRequestById)
+	api.GET("/projects", h.ListProjects)
+	api.POST("/projects", h.CreateProject)
 
-	api.GET("/repeat/:id", h.RepeatRequest)
-	api.GET("/scan/:id", h.ScanRequest)
+	project := api.Group("/projects/:project", h.Project)
+	project.GET("", h.GetProject)
+	project.DELETE("", h.DeleteProject)
+	project.POST("/activate", h.ActivateProject)
+	project.POST("/archive", h.ArchiveProject)
+	project.POST("/unarchive", h.UnarchiveProject)
+
+	// Rules and passthrough hosts apply to all projects.
+	api.GET("/rules", h.GetRules)
+	api.POST("/rules", h.CreateRule)
+	api.GET("/rules/:id", h.GetRule)
+	api.PUT("/rules/:id", h.UpdateRule)
+	api.DELETE("/rules/:id", h.DeleteRule)
+
+	api.GET("/passthrough", h.GetPassthroughHosts)
+	api.POST("/passthrough", h.CreatePassthroughHost)
+	api.GET("/passthrough/:id", h.GetPassthroughHost)
+	api.PUT("/passthrough/:id", h.UpdatePassthroughHost)
+	api.DELETE("/passthrough/:id", h.DeletePassthroughHost)
+
+	// The history routes read the active project under /api and any other
+	// under /api/projects/:project.
+	active := api.Group("", h.ActiveProject)
+	active.GET("/project", h.GetProject)
+	historyRoutes(active, h)
+	historyRoutes(project, h)
+
+	// Held traffic lives in the proxy process. Anywhere else the intercept
+	// routes are forwarded to it.
+	if h.Intercept != nil {
+		interceptRoutes(api, h)
+	} else if cfg.Proxy.Intercept.Addr != "" {
+		forward := forwardToProxy(cfg.Proxy.Intercept.Addr, log)
+		api.Any("/intercept", forward)
+		api.Any("/intercept/*path", forward)
+	}
 
 	s := &Server{
 		Server: &http.Server{
@@ -43,6 +80,80 @@ func NewServer(mainCtx context.Context, cfg *config.Config, r *gin.Engine, log l
 	return s
 }
 
+func historyRoutes(g *gin.RouterGroup, h *handler.Handler) {
+	g.GET("/requests", h.GetRequests)
+	g.GET("/requests/:id", h.GetRequestById)
+	g.GET("/requests/:id/body", h.GetRequestBody)
+	g.GET("/requests/:id/response", h.GetRequestResponse)
+	g.GET("/responses/:id", h.GetResponseById)
+	g.GET("/responses/:id/body", h.GetResponseBody)
+
+	g.GET("/search", h.Search)
+
+	g.GET("/export/har", h.ExportHAR)
+	g.POST("/import/har", h.ImportHAR)
+	g.GET("/export/jsonl", h.ExportJSONL)
+	g.POST("/import/jsonl", h.ImportJSONL)
+
+	g.GET("/repeat/:id", h.RepeatRequest)
+	g.GET("/scan/:id", h.ScanRequest)
+	g.GET("/scans", h.GetScanResults)
+
+	g.GET("/notes", h.GetNotes)
+	g.POST("/notes", h.CreateNote)
+	g.GET("/notes/:id", h.GetNote)
+	g.PUT("/notes/:id", h.UpdateNote)
+	g.DELETE("/notes/:id", h.DeleteNote)
+
+	g.GET("/scope", h.GetScopeRules)
+	g.POST("/scope", h.CreateScopeRule)
+	g.GET("/scope/:id", h.GetScopeRule)
+	g.PUT("/scope/:id", h.UpdateScopeRule)
+	g.DELETE("/scope/:id", h.DeleteScopeRule)
+
+	g.GET("/websockets", h.GetWebSockets)
+	g.GET("/websockets/:id", h.GetWebSocketMessages)
+}
+
+func interceptRoutes(g *gin.RouterGroup, h *handler.Handler) {
+	g.GET("/intercept", h.GetIntercepted)
+	g.PATCH("/intercept", h.UpdateInterceptSettings)
+	g.GET("/intercept/:id", h.GetInterceptedMessage)
+	g.POST("/intercept/:id/forward", h.ForwardIntercepted)
+	g.POST("/intercept/:id/drop", h.DropIntercepted)
+}
+
+// NewInterceptServer serves the intercept routes of the proxy process on
+// addr, for the api server to forward to.
+func NewInterceptServer(mainCtx context.Context, addr string, r *gin.Engine, log logger.Logger, h *handler.Handler) *Server {
+	r.Use(gin.Recovery())
+	interceptRoutes(r.Group("/api"), h)
+
+	return &Server{
+		Server: &http.Server{
+			Addr:    addr,
+			Handler: r,
+			BaseContext: func(_ net.Listener) context.Context {
+				return mainCtx
+			},
+		},
+		Logger: log,
+	}
+}
+
+func forwardToProxy(addr string, log logger.Logger) gin.HandlerFunc {
+	rp := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: addr})
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Errorf("failed to reach the proxy at %s: %v", addr, err)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(gin.H{"error": "proxy unreachable: " + err.Error()})
+	}
+	return func(ctx *gin.Context) {
+		rp.ServeHTTP(ctx.Writer, ctx.Request)
+	}
+}
+
 func (s *Server) ApiRun() {
 	s.Logger.Info("Router initialized")
 	if err := s.ListenAndServe(); err != nil {
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/api/repository/memory"
+	"proxy/internal/api/repository/sqlite"
+	"proxy/internal/migrations"
+	"proxy/pkg/config"
+	"proxy/pkg/logger"
+
+	postgresql "proxy/cmd/app/init/db"
+	repositoryRequest "proxy/internal/api/repository/requests"
+)
+
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+	Memory   = "memory"
+)
+
+const defaultSQLitePath = "proxy.db"
+
+// Storage is the history backend picked by the config.
+type Storage struct {
+	Driver     string
+	Repository repository.Repository
+	// Migrator is only set for postgres. The sqlite schema is brought up
+	// to date on open and memory has none.
+	Migrator *migrations.Migrator
+
+	close func()
+}
+
+func Open(ctx context.Context, cfg config.Config, log logger.Logger) (*Storage, error) {
+	switch cfg.Storage.Driver {
+	case "", Postgres:
+		db, err := postgresql.InitPostgresDB(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("initializing PostgreSQL database: %w", err)
+		}
+
+		migrator, err := migrations.NewMigrator(db, log)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("loading migrations: %w", err)
+		}
+
+		return &Storage{
+			Driver:     Postgres,
+			Repository: repositoryRequest.NewRepository(db, log),
+			Migrator:   migrator,
+			close:      db.Close,
+		}, nil
+
+	case SQLite:
+		path := cfg.Storage.Path
+		if path == "" {
+			path = defaultSQLitePath
+		}
+
+		db, err := sqlite.Open(ctx, path, log)
+		if err != nil {
+			return nil, fmt.Errorf("opening SQLite database %s: %w", path, err)
+		}
+
+		return &Storage{
+			Driver:     SQLite,
+			Repository: sqlite.NewRepository(db, log),
+			close: func() {
+				if err := db.Close(); err != nil {
+					log.Errorf("failed to close %s: %v", path, err)
+				}
+			},
+		}, nil
+
+	case Memory:
+		return &Storage{
+			Driver:     Memory,
+			Repository: memory.NewRepository(),
+			close:      func() {},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage driver %q, want %s, %s or %s", cfg.Storage.Driver, Postgres, SQLite, Memory)
+}
+
+// Migrate applies pending postgres migrations, a no-op for the other
+// backends.
+func (s *Storage) Migrate(ctx context.Context) error {
+	if s.Migrator == nil {
+		return nil
+	}
+	_, err := s.Migrator.Up(ctx)
+	return err
+}
+
+func (s *Storage) Close() {
+	s.close()
+}
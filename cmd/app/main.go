@@ -2,20 +2,22 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"proxy/cmd/app/init/history"
+	"proxy/cmd/app/init/migrate"
 	"proxy/cmd/app/init/server"
+	"proxy/cmd/app/init/storage"
 	"proxy/pkg/config"
 	"proxy/pkg/logger"
-
-	postgresql "proxy/cmd/app/init/db"
+	"proxy/pkg/upstream"
 
 	handlerRequest "proxy/internal/api/handler/http"
-	repositoryRequest "proxy/internal/api/repository/requests"
 	usecaseRequest "proxy/internal/api/usecase/requests"
 
 	"github.com/gin-gonic/gin"
@@ -24,6 +26,8 @@ import (
 )
 
 func main() {
+	flag.Parse()
+
 	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
@@ -46,21 +50,56 @@ func main() {
 
 	router := gin.Default()
 	// ------------------- DB ---------------------
-	db, err := postgresql.InitPostgresDB(ctx, cfg)
+	store, err := storage.Open(ctx, cfg, logger)
 	if err != nil {
-		logger.Errorf("Error Initializing PostgreSQL database: %v", err)
+		logger.Errorf("Error opening storage: %v", err)
 		return
 	}
 	defer func() {
-		db.Close()
+		store.Close()
 		logger.Info("Db closed without errors")
 	}()
 
+	if flag.Arg(0) == "migrate" {
+		if store.Migrator == nil {
+			logger.Errorf("migrate: the %s storage has no migrations to manage", store.Driver)
+			return
+		}
+		if err := migrate.Run(signalCtx, flag.Args()[1:], store.Migrator, os.Stdout); err != nil {
+			logger.Errorf("migrate: %v", err)
+		}
+		return
+	}
+
+	if store.Driver == storage.Memory {
+		// Nothing the proxy captures would ever show up here.
+		logger.Errorf("the memory storage lives inside the proxy, which serves the api itself")
+		return
+	}
+
+	if err := store.Migrate(signalCtx); err != nil {
+		logger.Errorf("Error applying migrations: %v", err)
+		return
+	}
+
 	// ----------------- PROXY ------------------------
 	// ------------------------------------------------
 
-	r := repositoryRequest.NewRepository(db, logger)
-	u := usecaseRequest.NewUsecase(r, logger)
+	upstreams, err := upstream.New(cfg.Proxy.Upstream)
+	if err != nil {
+		logger.Errorf("Error loading upstream proxies: %v", err)
+		return
+	}
+	u := usecaseRequest.NewUsecase(store.Repository, logger, upstreams)
+
+	switch flag.Arg(0) {
+	case "export", "import":
+		if err := history.Run(signalCtx, flag.Args(), u, os.Stdout); err != nil {
+			logger.Errorf("%s: %v", flag.Arg(0), err)
+		}
+		return
+	}
+
 	h := handlerRequest.NewHandler(logger, u)
 
 	server := server.NewServer(signalCtx, &cfg, router, logger, h)
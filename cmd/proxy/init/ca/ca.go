@@ -0,0 +1,285 @@
+package ca
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"proxy/internal/proxy"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	DefaultCertFile = "certs/ca.crt"
+	DefaultKeyFile  = "certs/ca.key"
+)
+
+const usage = `usage: proxy [-ca_cert_file FILE] [-ca_key_file FILE] ca <command> [flags]
+
+commands:
+  init    generate a new CA (refuses to overwrite without -force)
+  show    print the CA subject, validity and fingerprints
+  export  write the CA certificate as pem, der or p12
+  rotate  back up the current CA and generate a new one
+`
+
+// Files locates the CA on disk and the leaf cache that depends on it.
+type Files struct {
+	CertFile  string
+	KeyFile   string
+	LeafCache string
+}
+
+// Run executes `proxy ca <command>`.
+func Run(args []string, files Files, out io.Writer) error {
+	if files.CertFile == "" {
+		files.CertFile = DefaultCertFile
+	}
+	if files.KeyFile == "" {
+		files.KeyFile = DefaultKeyFile
+	}
+
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing ca command")
+	}
+
+	switch args[0] {
+	case "init":
+		return runInit(args[1:], files, out)
+	case "show":
+		return runShow(files, out)
+	case "export":
+		return runExport(args[1:], files, out)
+	case "rotate":
+		return runRotate(args[1:], files, out)
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown ca command %q", args[0])
+	}
+}
+
+func runInit(args []string, files Files, out io.Writer) error {
+	fs := flag.NewFlagSet("ca init", flag.ContinueOnError)
+	cn := fs.String("cn", proxy.DefaultCACommonName, "CA common name")
+	days := fs.Int("days", 3650, "validity in days")
+	force := fs.Bool("force", false, "overwrite an existing CA")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !*force {
+		for _, f := range []string{files.CertFile, files.KeyFile} {
+			if _, err := os.Stat(f); err == nil {
+				return fmt.Errorf("%s already exists, use -force or `ca rotate` to replace it", f)
+			}
+		}
+	}
+
+	if err := writeCA(files, *cn, *days); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "CA written to %s and %s\n", files.CertFile, files.KeyFile)
+	return runShow(files, out)
+}
+
+func runShow(files Files, out io.Writer) error {
+	cert, key, err := proxy.LoadCA(files.CertFile, files.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw)
+
+	fmt.Fprintf(out, "Subject:     %s\n", cert.Subject)
+	fmt.Fprintf(out, "Serial:      %s\n", cert.SerialNumber.Text(16))
+	fmt.Fprintf(out, "Not before:  %s\n", cert.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(out, "Not after:   %s\n", cert.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(out, "Key:         %s\n", proxy.KeyDescription(key.Public()))
+	fmt.Fprintf(out, "SHA-256:     %s\n", fingerprint(sha256Sum[:]))
+	fmt.Fprintf(out, "SHA-1:       %s\n", fingerprint(sha1Sum[:]))
+	return nil
+}
+
+func runExport(args []string, files Files, out io.Writer) error {
+	fs := flag.NewFlagSet("ca export", flag.ContinueOnError)
+	format := fs.String("format", "pem", "pem, der or p12")
+	output := fs.String("out", "", "output file (stdout when empty)")
+	password := fs.String("password", "", "p12 password")
+	withKey := fs.Bool("with-key", false, "include the private key in the p12 bundle")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cert, key, err := proxy.LoadCA(files.CertFile, files.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch strings.ToLower(*format) {
+	case "pem":
+		data = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	case "der", "cer":
+		data = cert.Raw
+	case "p12", "pfx", "pkcs12":
+		if *withKey {
+			data, err = pkcs12.Modern.Encode(key, cert, nil, *password)
+		} else {
+			data, err = pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{cert}, *password)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode p12: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	if *output == "" {
+		_, err = out.Write(data)
+		return err
+	}
+
+	mode := os.FileMode(0o644)
+	if *withKey {
+		mode = 0o600
+	}
+	return os.WriteFile(*output, data, mode)
+}
+
+func runRotate(args []string, files Files, out io.Writer) error {
+	fs := flag.NewFlagSet("ca rotate", flag.ContinueOnError)
+	cn := fs.String("cn", proxy.DefaultCACommonName, "CA common name")
+	days := fs.Int("days", 3650, "validity in days")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// The current CA stays in place until the new one is on disk.
+	staged, err := stageCA(files, *cn, *days)
+	if err != nil {
+		return err
+	}
+	defer removeStaged(staged)
+
+	suffix := "." + time.Now().Format("20060102T150405") + ".bak"
+	for _, f := range []string{files.CertFile, files.KeyFile} {
+		if err := copyFile(f, f+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to back up %s: %w", f, err)
+		}
+	}
+
+	if err := installCA(staged, files); err != nil {
+		return err
+	}
+
+	// Leaves on disk were signed by the old CA and would only be rejected
+	// on load, drop them right away.
+	if files.LeafCache != "" {
+		if err := os.RemoveAll(files.LeafCache); err != nil {
+			return fmt.Errorf("failed to clear leaf cache: %w", err)
+		}
+	}
+
+	fmt.Fprintf(out, "previous CA kept with suffix %s\n", suffix)
+	return runShow(files, out)
+}
+
+func writeCA(files Files, cn string, days int) error {
+	staged, err := stageCA(files, cn, days)
+	if err != nil {
+		return err
+	}
+	defer removeStaged(staged)
+	return installCA(staged, files)
+}
+
+// stageCA generates a CA into temporary files next to those of files.
+func stageCA(files Files, cn string, days int) (Files, error) {
+	certPEM, keyPEM, err := proxy.GenerateCA(cn, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return Files{}, err
+	}
+
+	var staged Files
+	if staged.KeyFile, err = writeTemp(files.KeyFile, keyPEM, 0o600); err != nil {
+		return Files{}, err
+	}
+	if staged.CertFile, err = writeTemp(files.CertFile, certPEM, 0o644); err != nil {
+		os.Remove(staged.KeyFile)
+		return Files{}, err
+	}
+	return staged, nil
+}
+
+// installCA moves a staged CA over the files, the key first so that a
+// certificate is never left without it.
+func installCA(staged, files Files) error {
+	if err := os.Rename(staged.KeyFile, files.KeyFile); err != nil {
+		return err
+	}
+	return os.Rename(staged.CertFile, files.CertFile)
+}
+
+// removeStaged cleans up after a CA that was not installed.
+func removeStaged(staged Files) {
+	for _, f := range []string{staged.CertFile, staged.KeyFile} {
+		os.Remove(f)
+	}
+}
+
+func writeTemp(path string, data []byte, mode os.FileMode) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
+
+func fingerprint(sum []byte) string {
+	h := strings.ToUpper(hex.EncodeToString(sum))
+	parts := make([]string, 0, len(h)/2)
+	for i := 0; i < len(h); i += 2 {
+		parts = append(parts, h[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
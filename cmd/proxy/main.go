@@ -11,15 +11,18 @@ import (
 	"syscall"
 	"time"
 
+	"proxy/cmd/app/init/storage"
+	"proxy/cmd/proxy/init/ca"
 	"proxy/internal/proxy"
 	"proxy/pkg/config"
 	"proxy/pkg/logger"
+	"proxy/pkg/upstream"
 
-	postgresql "proxy/cmd/app/init/db"
-
-	repositoryRequest "proxy/internal/api/repository/requests"
+	apiserver "proxy/cmd/app/init/server"
+	handlerRequest "proxy/internal/api/handler/http"
 	usecaseRequest "proxy/internal/api/usecase/requests"
 
+	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"golang.org/x/sync/errgroup"
 )
@@ -43,13 +46,18 @@ import (
 // }
 
 var (
-	caCertFile = flag.String("ca_cert_file", "", "certificate .pem file for trusted CA")
-	caKeyFile  = flag.String("ca_key_file", "", "key .pem file for trusted CA")
+	caCertFile = flag.String("ca_cert_file", ca.DefaultCertFile, "certificate .pem file for trusted CA")
+	caKeyFile  = flag.String("ca_key_file", ca.DefaultKeyFile, "key .pem file for trusted CA")
 )
 
 func main() {
 	//log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	if flag.Arg(0) == "ca" {
+		runCA()
+		return
+	}
+
 	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
@@ -70,20 +78,29 @@ func main() {
 
 	logger := logger.NewLogger(ctx, cfg.Logger)
 
-	db, err := postgresql.InitPostgresDB(ctx, cfg)
+	store, err := storage.Open(ctx, cfg, logger)
 	if err != nil {
-		logger.Errorf("Error Initializing PostgreSQL database: %v", err)
+		logger.Errorf("Error opening storage: %v", err)
 		return
 	}
 	defer func() {
-		db.Close()
+		store.Close()
 		logger.Info("Db closed without errors")
 	}()
 
-	r := repositoryRequest.NewRepository(db, logger)
-	u := usecaseRequest.NewUsecase(r, logger)
+	if err := store.Migrate(signalCtx); err != nil {
+		logger.Errorf("Error applying migrations: %v", err)
+		return
+	}
+
+	upstreams, err := upstream.New(cfg.Proxy.Upstream)
+	if err != nil {
+		logger.Errorf("Error loading upstream proxies: %v", err)
+		return
+	}
+	u := usecaseRequest.NewUsecase(store.Repository, logger, upstreams)
 
-	proxy, err := proxy.NewProxy(*caCertFile, *caKeyFile, u, logger)
+	proxy, err := proxy.NewProxy(cfg.Proxy, *caCertFile, *caKeyFile, u, logger, upstreams)
 	if err != nil {
 		fmt.Println(*caCertFile, *caKeyFile)
 		log.Fatal(err)
@@ -104,9 +121,65 @@ func main() {
 		return server.Shutdown(context.Background())
 	})
 
+	h := handlerRequest.NewHandler(logger, u)
+	h.Intercept = proxy.Interceptor()
+
+	// A memory history only exists in this process, so the api is served
+	// from here as well, intercept routes included. Otherwise only those
+	// are, for the api server to forward to.
+	if store.Driver == storage.Memory {
+		apiServer := apiserver.NewServer(signalCtx, &cfg, gin.Default(), logger, h)
+		g.Go(func() error {
+			logger.Infof("api on %s", apiServer.Addr)
+			return apiServer.ListenAndServe()
+		})
+		g.Go(func() error {
+			<-gCtx.Done()
+			return apiServer.Shutdown(context.Background())
+		})
+	} else if addr := cfg.Proxy.Intercept.Addr; addr != "" {
+		interceptServer := apiserver.NewInterceptServer(signalCtx, addr, gin.Default(), logger, h)
+		g.Go(func() error {
+			logger.Infof("intercept api on %s", addr)
+			return interceptServer.ListenAndServe()
+		})
+		g.Go(func() error {
+			<-gCtx.Done()
+			return interceptServer.Shutdown(context.Background())
+		})
+	}
+
 	if err := g.Wait(); err != nil {
 		logger.Infof("exit reason: %v\n", err)
 	}
+
+	// Hijacked tunnels outlive Shutdown, whatever they capture from here on
+	// is counted as dropped.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelFlush()
+	if err := proxy.Close(flushCtx); err != nil {
+		logger.Errorf("failed to flush captured traffic: %v", err)
+	}
+
+	recorded := proxy.RecorderStats()
+	logger.Infof("recorder: %d saved, %d failed, %d dropped in %d batches, %d still pending",
+		recorded.Saved, recorded.Failed, recorded.Dropped, recorded.Batches, recorded.Pending)
+
+	stats := proxy.CertCacheStats()
+	logger.Infof("cert cache: %d hits, %d disk hits, %d misses, %d entries", stats.Hits, stats.DiskHits, stats.Misses, stats.Entries)
+}
+
+// runCA runs `proxy ca`, which mostly exists for fresh checkouts, so it
+// needs neither a .env nor a database. The config only tells where the
+// leaf cache to clear on rotation is, a missing one leaves it alone.
+func runCA() {
+	files := ca.Files{CertFile: *caCertFile, KeyFile: *caKeyFile}
+	if cfg, err := config.GetConfig("."); err == nil {
+		files.LeafCache = cfg.Proxy.CertCache.Dir
+	}
+	if err := ca.Run(flag.Args()[1:], files, os.Stdout); err != nil {
+		log.Fatalf("ca: %v", err)
+	}
 }
 
 func init() {
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseRequestFilter reads the history query parameters:
+//
+//	method        comma separated list, e.g. GET,POST
+//	host          exact host or a glob such as *.example.com
+//	path_prefix   path the request starts with
+//	status        200, a class such as 5xx, or a range such as 400-499
+//	content_type  substring of the response MIME type
+//	from, to      RFC 3339 timestamps, to is exclusive
+//	min_size      response body size bounds in bytes
+//	max_size
+//	filter        filter expression, e.g. host ~ "api\." and resp.status >= 500
+//	sort          created_at (default), host, status or size
+//	order         asc (default) or desc
+//	limit         page size, 100 by default
+//	cursor        next_cursor of the previous page
+func parseRequestFilter(ctx *gin.Context) (models.RequestFilter, error) {
+	filter := models.RequestFilter{ProjectId: projectId(ctx)}
+	var err error
+
+	if v := ctx.Query("method"); v != "" {
+		filter.Methods = strings.Split(v, ",")
+	}
+	filter.Host = ctx.Query("host")
+	filter.PathPrefix = ctx.Query("path_prefix")
+	filter.ContentType = ctx.Query("content_type")
+	filter.Sort = ctx.Query("sort")
+	filter.Cursor = ctx.Query("cursor")
+	filter.Expression = ctx.Query("filter")
+
+	if v := ctx.Query("status"); v != "" {
+		if filter.StatusMin, filter.StatusMax, err = parseStatus(v); err != nil {
+			return filter, err
+		}
+	}
+
+	if filter.From, err = parseTime(ctx, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTime(ctx, "to"); err != nil {
+		return filter, err
+	}
+
+	if filter.MinSize, err = parseSize(ctx, "min_size"); err != nil {
+		return filter, err
+	}
+	if filter.MaxSize, err = parseSize(ctx, "max_size"); err != nil {
+		return filter, err
+	}
+
+	switch ctx.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, fmt.Errorf("order must be asc or desc")
+	}
+
+	if v := ctx.Query("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+			return filter, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+
+	return filter, nil
+}
+
+func parseStatus(v string) (int, int, error) {
+	if len(v) == 3 && strings.HasSuffix(strings.ToLower(v), "xx") {
+		class, err := strconv.Atoi(v[:1])
+		if err != nil || class < 1 || class > 5 {
+			return 0, 0, fmt.Errorf("invalid status class %q", v)
+		}
+		return class * 100, class*100 + 99, nil
+	}
+
+	if lo, hi, ok := strings.Cut(v, "-"); ok {
+		min, errMin := strconv.Atoi(lo)
+		max, errMax := strconv.Atoi(hi)
+		if errMin != nil || errMax != nil || min > max {
+			return 0, 0, fmt.Errorf("invalid status range %q", v)
+		}
+		return min, max, nil
+	}
+
+	code, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid status %q", v)
+	}
+	return code, code, nil
+}
+
+func parseTime(ctx *gin.Context, name string) (time.Time, error) {
+	v := ctx.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return t, nil
+}
+
+func parseSize(ctx *gin.Context, name string) (*int64, error) {
+	v := ctx.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	size, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return &size, nil
+}
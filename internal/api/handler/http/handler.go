@@ -21,6 +21,8 @@ import (
 type Handler struct {
 	Logger  logger.Logger
 	Usecase usecase.Usecase
+	// Intercept is only set in the proxy process.
+	Intercept Interceptor
 }
 
 func NewHandler(logger logger.Logger, uc usecase.Usecase) *Handler {
@@ -30,22 +32,31 @@ func NewHandler(logger logger.Logger, uc usecase.Usecase) *Handler {
 	}
 }
 
+// GetRequests returns one page of the request history, see
+// parseRequestFilter for the accepted query parameters.
 func (h *Handler) GetRequests(ctx *gin.Context) {
-	requests, err := h.Usecase.GetAllRequests(ctx.Request.Context())
+	filter, err := parseRequestFilter(ctx)
 	if err != nil {
-		var errNoRequests *models.ErrRequestNotFuound
-		if errors.As(err, &errNoRequests) {
-			ctx.JSON(http.StatusNoContent, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.Usecase.ListRequests(ctx.Request.Context(), filter)
+	if err != nil {
+		var errBadFilter *models.ErrBadFilter
+		if errors.As(err, &errBadFilter) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		h.Logger.Errorf("failed to get all requests %v", err)
+		h.Logger.Errorf("failed to list requests %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"requests": requests})
+	ctx.JSON(http.StatusOK, page)
 }
 
+// GetRequestById returns a request together with its response.
 func (h *Handler) GetRequestById(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id")[:], 10, 64)
 	if err != nil {
@@ -53,7 +64,7 @@ func (h *Handler) GetRequestById(ctx *gin.Context) {
 		return
 	}
 
-	request, err := h.Usecase.GetRequestById(ctx.Request.Context(), id)
+	exchange, err := h.Usecase.GetExchange(ctx.Request.Context(), projectId(ctx), id)
 	if err != nil {
 		var errNoRequests *models.ErrRequestNotFuound
 		if errors.As(err, &errNoRequests) {
@@ -63,7 +74,7 @@ func (h *Handler) GetRequestById(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"request": request})
+	ctx.JSON(http.StatusOK, exchange)
 }
 
 func (h *Handler) RepeatRequest(ctx *gin.Context) {
@@ -73,13 +84,23 @@ func (h *Handler) RepeatRequest(ctx *gin.Context) {
 		return
 	}
 
-	requestHttp, err := h.Usecase.RepeatRequest(ctx.Request.Context(), id)
+	requestHttp, err := h.Usecase.RepeatRequest(ctx.Request.Context(), projectId(ctx), id)
 	if err != nil {
+		var errNoRequests *models.ErrRequestNotFuound
+		var errOutOfScope *models.ErrOutOfScope
+		if errors.As(err, &errNoRequests) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.As(err, &errOutOfScope) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": errOutOfScope.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	repeatedResp, err := http.DefaultClient.Do(requestHttp)
+	repeatedResp, err := h.Usecase.SendRequest(requestHttp)
 	if err != nil {
 		log.Print(err)
 		ctx.JSON(http.StatusInternalServerError, "failed to send request to repeat")
@@ -109,7 +130,7 @@ func (h Handler) ScanRequest(ctx *gin.Context) {
 	}
 	defer file.Close()
 
-	request, err := h.Usecase.GetRequestById(ctx.Request.Context(), id)
+	request, err := h.Usecase.GetRequestById(ctx.Request.Context(), projectId(ctx), id)
 	if err != nil {
 		var errNoRequests *models.ErrRequestNotFuound
 		if errors.As(err, &errNoRequests) {
@@ -128,6 +149,11 @@ func (h Handler) ScanRequest(ctx *gin.Context) {
 		line := scanner.Text()
 		param, err := h.Usecase.ScanRequest(ctx.Request.Context(), line, request)
 		if err != nil {
+			var errOutOfScope *models.ErrOutOfScope
+			if errors.As(err, &errOutOfScope) {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": errOutOfScope.Error()})
+				return
+			}
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -136,5 +162,16 @@ func (h Handler) ScanRequest(ctx *gin.Context) {
 			hiddenParams = append(hiddenParams, param)
 		}
 	}
-	ctx.JSON(http.StatusOK, gin.H{"hidden_params": hiddenParams})
+
+	scanId, err := h.Usecase.SaveScanResult(ctx.Request.Context(), models.ScanResult{
+		ProjectId:    projectId(ctx),
+		RequestId:    id,
+		HiddenParams: hiddenParams,
+	})
+	if err != nil {
+		h.Logger.Errorf("failed to save scan result %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"scan_id": scanId, "hidden_params": hiddenParams})
 }
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRequestBody serves the stored body of a request. By default the raw
+// captured bytes are sent as a download; ?decoded=1 undoes content
+// encodings first and ?format=base64 wraps the body in JSON instead.
+func (h *Handler) GetRequestBody(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := h.Usecase.GetRequestBody(ctx.Request.Context(), projectId(ctx), id, ctx.Query("decoded") == "1")
+	if err != nil {
+		h.bodyError(ctx, err)
+		return
+	}
+
+	h.serveBody(ctx, body, fmt.Sprintf("request-%d", id))
+}
+
+// GetResponseBody is GetRequestBody for responses, looked up by response id.
+func (h *Handler) GetResponseBody(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := h.Usecase.GetResponseBody(ctx.Request.Context(), projectId(ctx), id, ctx.Query("decoded") == "1")
+	if err != nil {
+		h.bodyError(ctx, err)
+		return
+	}
+
+	h.serveBody(ctx, body, fmt.Sprintf("response-%d", id))
+}
+
+func (h *Handler) bodyError(ctx *gin.Context, err error) {
+	var errNoRequests *models.ErrRequestNotFuound
+	var errNoResponse *models.ErrResponseNotFound
+	if errors.As(err, &errNoRequests) || errors.As(err, &errNoResponse) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	h.Logger.Errorf("failed to get body %v", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func (h *Handler) serveBody(ctx *gin.Context, body *models.Body, name string) {
+	if ctx.Query("format") == "base64" {
+		// []byte fields are base64 encoded by encoding/json.
+		ctx.JSON(http.StatusOK, gin.H{"body": body})
+		return
+	}
+
+	contentType := body.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		name += exts[0]
+	}
+
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	ctx.Header("X-Body-Size", strconv.FormatInt(body.Size, 10))
+	ctx.Header("X-Body-Truncated", strconv.FormatBool(body.Truncated))
+	ctx.Data(http.StatusOK, contentType, body.Content)
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"proxy/internal/models"
+	"proxy/pkg/har"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize bounds the files the import endpoints accept.
+const maxImportSize = 512 << 20
+
+// ExportHAR downloads the history as a HAR 1.2 archive. It takes the
+// filters of GetRequests but exports every match rather than a page.
+func (h *Handler) ExportHAR(ctx *gin.Context) {
+	filter, err := parseRequestFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	archive, err := h.Usecase.ExportHAR(ctx.Request.Context(), filter)
+	if err != nil {
+		var errBadFilter *models.ErrBadFilter
+		if errors.As(err, &errBadFilter) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.Logger.Errorf("failed to export har %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="history.har"`)
+	ctx.JSON(http.StatusOK, archive)
+}
+
+// ImportHAR adds the entries of a HAR archive in the body to the history.
+func (h *Handler) ImportHAR(ctx *gin.Context) {
+	var archive har.HAR
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize)
+	if err := json.NewDecoder(body).Decode(&archive); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	n, err := h.Usecase.ImportHAR(ctx.Request.Context(), projectId(ctx), &archive)
+	if err != nil {
+		var errBadImport *models.ErrBadImport
+		if errors.As(err, &errBadImport) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.Logger.Errorf("failed to import har %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"imported": n})
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Interceptor is the queue of traffic the proxy holds for the operator. It
+// only exists in the proxy process, which serves the intercept routes.
+type Interceptor interface {
+	Settings() models.InterceptSettings
+	SetSettings(s models.InterceptSettings) error
+	List() []models.InterceptedMessage
+	Get(id uint64) (*models.InterceptedMessage, error)
+	Forward(id uint64, edit models.InterceptEdit) error
+	Drop(id uint64) error
+}
+
+// GetIntercepted returns the intercept settings and the held messages.
+func (h *Handler) GetIntercepted(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"settings": h.Intercept.Settings(),
+		"pending":  h.Intercept.List(),
+	})
+}
+
+// UpdateInterceptSettings changes the fields of the intercept settings
+// present in the body, e.g. {"enabled": false} to turn interception off.
+func (h *Handler) UpdateInterceptSettings(ctx *gin.Context) {
+	settings := h.Intercept.Settings()
+	if err := ctx.ShouldBindJSON(&settings); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Intercept.SetSettings(settings); err != nil {
+		h.interceptError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"settings": h.Intercept.Settings()})
+}
+
+// GetInterceptedMessage returns one held message.
+func (h *Handler) GetInterceptedMessage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	msg, err := h.Intercept.Get(id)
+	if err != nil {
+		h.interceptError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, msg)
+}
+
+// ForwardIntercepted sends a held message on, changed by the edit in the
+// body if there is one.
+func (h *Handler) ForwardIntercepted(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var edit models.InterceptEdit
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&edit); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Intercept.Forward(id, edit); err != nil {
+		h.interceptError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// DropIntercepted discards a held message, its client gets a 502.
+func (h *Handler) DropIntercepted(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Intercept.Drop(id); err != nil {
+		h.interceptError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) interceptError(ctx *gin.Context, err error) {
+	var errNotFound *models.ErrInterceptNotFound
+	var errBadFilter *models.ErrBadFilter
+	var errBadSettings *models.ErrBadInterceptSettings
+	switch {
+	case errors.As(err, &errNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &errBadFilter), errors.As(err, &errBadSettings):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.Logger.Errorf("intercept: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportJSONL streams the history as JSON lines, one exchange per line. It
+// takes the filters of GetRequests but exports every match rather than a
+// page.
+func (h *Handler) ExportJSONL(ctx *gin.Context) {
+	filter, err := parseRequestFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Type", "application/x-ndjson")
+	ctx.Header("Content-Disposition", `attachment; filename="history.jsonl"`)
+	if _, err := h.Usecase.ExportJSONL(ctx.Request.Context(), filter, ctx.Writer); err != nil {
+		if ctx.Writer.Written() {
+			// Too late for an error response, the client gets a short file.
+			h.Logger.Errorf("failed to export jsonl %v", err)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		var errBadFilter *models.ErrBadFilter
+		if errors.As(err, &errBadFilter) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.Logger.Errorf("failed to export jsonl %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ImportJSONL adds the exchanges of a JSON lines body to the history,
+// skipping those it already holds. On error the response still counts
+// what was imported before it.
+func (h *Handler) ImportJSONL(ctx *gin.Context) {
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize)
+	result, err := h.Usecase.ImportJSONL(ctx.Request.Context(), projectId(ctx), body)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var errBadImport *models.ErrBadImport
+		var errTooLarge *http.MaxBytesError
+		if errors.As(err, &errBadImport) {
+			status = http.StatusBadRequest
+		} else if errors.As(err, &errTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		} else {
+			h.Logger.Errorf("failed to import jsonl %v", err)
+		}
+		ctx.JSON(status, gin.H{"error": err.Error(), "imported": result.Imported, "skipped": result.Skipped})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type noteInput struct {
+	RequestId uint64 `json:"request_id"`
+	Text      string `json:"text"`
+}
+
+// GetNotes lists the notes of the project, only those about one request
+// with ?request_id=.
+func (h *Handler) GetNotes(ctx *gin.Context) {
+	requestId, err := optionalId(ctx, "request_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notes, err := h.Usecase.ListNotes(ctx.Request.Context(), projectId(ctx), requestId)
+	if err != nil {
+		h.noteError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"notes": notes})
+}
+
+func (h *Handler) GetNote(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	note, err := h.Usecase.GetNote(ctx.Request.Context(), projectId(ctx), id)
+	if err != nil {
+		h.noteError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"note": note})
+}
+
+// CreateNote adds a note from a {"text", "request_id"} body, the request
+// being optional.
+func (h *Handler) CreateNote(ctx *gin.Context) {
+	input, ok := bindNote(ctx)
+	if !ok {
+		return
+	}
+
+	note, err := h.Usecase.CreateNote(ctx.Request.Context(), models.Note{
+		ProjectId: projectId(ctx),
+		RequestId: input.RequestId,
+		Text:      input.Text,
+	})
+	if err != nil {
+		h.noteError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"note": note})
+}
+
+// UpdateNote replaces the text of a note, what it is about stays.
+func (h *Handler) UpdateNote(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input, ok := bindNote(ctx)
+	if !ok {
+		return
+	}
+
+	note, err := h.Usecase.UpdateNote(ctx.Request.Context(), models.Note{
+		Id:        id,
+		ProjectId: projectId(ctx),
+		Text:      input.Text,
+	})
+	if err != nil {
+		h.noteError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"note": note})
+}
+
+func (h *Handler) DeleteNote(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Usecase.DeleteNote(ctx.Request.Context(), projectId(ctx), id); err != nil {
+		h.noteError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func bindNote(ctx *gin.Context) (noteInput, bool) {
+	var input noteInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return input, false
+	}
+	if strings.TrimSpace(input.Text) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing note text"})
+		return input, false
+	}
+	return input, true
+}
+
+func (h *Handler) noteError(ctx *gin.Context, err error) {
+	var errNoNote *models.ErrNoteNotFound
+	var errNoRequests *models.ErrRequestNotFuound
+	if errors.As(err, &errNoNote) || errors.As(err, &errNoRequests) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	h.Logger.Errorf("failed to handle note %v", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// optionalId parses an id query parameter, zero when it is absent.
+func optionalId(ctx *gin.Context, name string) (uint64, error) {
+	v := ctx.Query(name)
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return id, nil
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type passthroughInput struct {
+	Host    string `json:"host"`
+	Enabled *bool  `json:"enabled"`
+}
+
+func (in passthroughInput) host(id uint64) models.PassthroughHost {
+	return models.PassthroughHost{
+		Id:      id,
+		Host:    in.Host,
+		Enabled: in.Enabled == nil || *in.Enabled,
+	}
+}
+
+// GetPassthroughHosts lists the hosts whose tunnels are not decrypted,
+// along with the learned candidates waiting to be enabled.
+func (h *Handler) GetPassthroughHosts(ctx *gin.Context) {
+	hosts, err := h.Usecase.ListPassthroughHosts(ctx.Request.Context())
+	if err != nil {
+		h.passthroughError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"passthrough": hosts})
+}
+
+func (h *Handler) GetPassthroughHost(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host, err := h.Usecase.GetPassthroughHost(ctx.Request.Context(), id)
+	if err != nil {
+		h.passthroughError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"passthrough_host": host})
+}
+
+// CreatePassthroughHost lists a host, enabled unless the body says
+// otherwise. The proxy picks it up within a second.
+func (h *Handler) CreatePassthroughHost(ctx *gin.Context) {
+	var input passthroughInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host, err := h.Usecase.CreatePassthroughHost(ctx.Request.Context(), input.host(0))
+	if err != nil {
+		h.passthroughError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"passthrough_host": host})
+}
+
+func (h *Handler) UpdatePassthroughHost(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input passthroughInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host, err := h.Usecase.UpdatePassthroughHost(ctx.Request.Context(), input.host(id))
+	if err != nil {
+		h.passthroughError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"passthrough_host": host})
+}
+
+func (h *Handler) DeletePassthroughHost(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Usecase.DeletePassthroughHost(ctx.Request.Context(), id); err != nil {
+		h.passthroughError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) passthroughError(ctx *gin.Context, err error) {
+	var errNoHost *models.ErrPassthroughNotFound
+	var errBadHost *models.ErrBadPassthrough
+	var errConflict *models.ErrPassthroughConflict
+	switch {
+	case errors.As(err, &errNoHost):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &errBadHost):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errBadHost.Error()})
+	case errors.As(err, &errConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": errConflict.Error()})
+	default:
+		h.Logger.Errorf("failed to handle passthrough host %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
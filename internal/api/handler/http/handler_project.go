@@ -0,0 +1,141 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// projectKey holds the id of the project a request is scoped to in the gin
+// context.
+const projectKey = "project_id"
+
+func projectId(ctx *gin.Context) uint64 {
+	return ctx.GetUint64(projectKey)
+}
+
+// ActiveProject scopes the routes it guards to the active project.
+func (h *Handler) ActiveProject(ctx *gin.Context) {
+	id, err := h.Usecase.ActiveProject(ctx.Request.Context())
+	if err != nil {
+		h.projectError(ctx, err)
+		ctx.Abort()
+		return
+	}
+	ctx.Set(projectKey, id)
+}
+
+// Project scopes the routes it guards to the project in the :project path
+// parameter.
+func (h *Handler) Project(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("project"), 10, 64)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.Usecase.GetProject(ctx.Request.Context(), id); err != nil {
+		h.projectError(ctx, err)
+		ctx.Abort()
+		return
+	}
+	ctx.Set(projectKey, id)
+}
+
+// ListProjects returns the projects, archived ones too with ?archived=1.
+func (h *Handler) ListProjects(ctx *gin.Context) {
+	projects, err := h.Usecase.ListProjects(ctx.Request.Context(), ctx.Query("archived") == "1")
+	if err != nil {
+		h.projectError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"projects": projects})
+}
+
+func (h *Handler) GetProject(ctx *gin.Context) {
+	project, err := h.Usecase.GetProject(ctx.Request.Context(), projectId(ctx))
+	if err != nil {
+		h.projectError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"project": project})
+}
+
+type projectInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CreateProject adds a project from a {"name", "description"} body. It
+// does not become active.
+func (h *Handler) CreateProject(ctx *gin.Context) {
+	var input projectInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing project name"})
+		return
+	}
+
+	project, err := h.Usecase.CreateProject(ctx.Request.Context(), models.Project{
+		Name:        input.Name,
+		Description: input.Description,
+	})
+	if err != nil {
+		h.projectError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"project": project})
+}
+
+// ActivateProject makes the proxy capture into the project.
+func (h *Handler) ActivateProject(ctx *gin.Context) {
+	h.changeProject(ctx, h.Usecase.ActivateProject(ctx.Request.Context(), projectId(ctx)))
+}
+
+func (h *Handler) ArchiveProject(ctx *gin.Context) {
+	h.changeProject(ctx, h.Usecase.ArchiveProject(ctx.Request.Context(), projectId(ctx), true))
+}
+
+func (h *Handler) UnarchiveProject(ctx *gin.Context) {
+	h.changeProject(ctx, h.Usecase.ArchiveProject(ctx.Request.Context(), projectId(ctx), false))
+}
+
+// DeleteProject removes the project with everything recorded in it.
+func (h *Handler) DeleteProject(ctx *gin.Context) {
+	if err := h.Usecase.DeleteProject(ctx.Request.Context(), projectId(ctx)); err != nil {
+		h.projectError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// changeProject answers with the project as it is after a change.
+func (h *Handler) changeProject(ctx *gin.Context, err error) {
+	if err != nil {
+		h.projectError(ctx, err)
+		return
+	}
+	h.GetProject(ctx)
+}
+
+func (h *Handler) projectError(ctx *gin.Context, err error) {
+	var errNoProject *models.ErrProjectNotFound
+	var errConflict *models.ErrProjectConflict
+	switch {
+	case errors.As(err, &errNoProject):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &errConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.Logger.Errorf("failed to handle project %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
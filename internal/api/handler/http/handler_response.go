@@ -0,0 +1,52 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetResponseById(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.Usecase.GetResponseById(ctx.Request.Context(), projectId(ctx), id)
+	if err != nil {
+		h.responseError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"response": response})
+}
+
+// GetRequestResponse returns the response recorded for a request id.
+func (h *Handler) GetRequestResponse(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.Usecase.GetResponseByRequestId(ctx.Request.Context(), projectId(ctx), id)
+	if err != nil {
+		h.responseError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"response": response})
+}
+
+func (h *Handler) responseError(ctx *gin.Context, err error) {
+	var errNoResponse *models.ErrResponseNotFound
+	if errors.As(err, &errNoResponse) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	h.Logger.Errorf("failed to get response %v", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ruleInput struct {
+	Name    string `json:"name"`
+	Enabled *bool  `json:"enabled"`
+	Target  string `json:"target"`
+	Host    string `json:"host"`
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+	Regex   bool   `json:"regex"`
+}
+
+func (in ruleInput) rule(id uint64) models.Rule {
+	return models.Rule{
+		Id:      id,
+		Name:    in.Name,
+		Enabled: in.Enabled == nil || *in.Enabled,
+		Target:  in.Target,
+		Host:    in.Host,
+		Match:   in.Match,
+		Replace: in.Replace,
+		Regex:   in.Regex,
+	}
+}
+
+func (h *Handler) GetRules(ctx *gin.Context) {
+	rules, err := h.Usecase.ListRules(ctx.Request.Context())
+	if err != nil {
+		h.ruleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (h *Handler) GetRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.Usecase.GetRule(ctx.Request.Context(), id)
+	if err != nil {
+		h.ruleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+// CreateRule adds a rule, enabled unless the body says otherwise. The
+// proxy picks it up within a second.
+func (h *Handler) CreateRule(ctx *gin.Context) {
+	var input ruleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.Usecase.CreateRule(ctx.Request.Context(), input.rule(0))
+	if err != nil {
+		h.ruleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"rule": rule})
+}
+
+// UpdateRule replaces a rule with the one in the body.
+func (h *Handler) UpdateRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input ruleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.Usecase.UpdateRule(ctx.Request.Context(), input.rule(id))
+	if err != nil {
+		h.ruleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (h *Handler) DeleteRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Usecase.DeleteRule(ctx.Request.Context(), id); err != nil {
+		h.ruleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ruleError(ctx *gin.Context, err error) {
+	var errNoRule *models.ErrRuleNotFound
+	var errBadRule *models.ErrBadRule
+	switch {
+	case errors.As(err, &errNoRule):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &errBadRule):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errBadRule.Error()})
+	default:
+		h.Logger.Errorf("failed to handle rule %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetScanResults lists the parameter scans run in the project, only those
+// of one request with ?request_id=.
+func (h *Handler) GetScanResults(ctx *gin.Context) {
+	requestId, err := optionalId(ctx, "request_id")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := h.Usecase.ListScanResults(ctx.Request.Context(), projectId(ctx), requestId)
+	if err != nil {
+		var errNoRequests *models.ErrRequestNotFuound
+		if errors.As(err, &errNoRequests) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.Logger.Errorf("failed to list scan results %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"scans": results})
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type scopeRuleInput struct {
+	Kind   string `json:"kind"`
+	Scheme string `json:"scheme"`
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	Path   string `json:"path"`
+}
+
+func (in scopeRuleInput) rule(projectId, id uint64) models.ScopeRule {
+	return models.ScopeRule{
+		Id:        id,
+		ProjectId: projectId,
+		Kind:      in.Kind,
+		Scheme:    in.Scheme,
+		Host:      in.Host,
+		Port:      in.Port,
+		Path:      in.Path,
+	}
+}
+
+// GetScopeRules lists the rules that make up the target scope of the
+// project.
+func (h *Handler) GetScopeRules(ctx *gin.Context) {
+	rules, err := h.Usecase.ListScopeRules(ctx.Request.Context(), projectId(ctx))
+	if err != nil {
+		h.scopeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"scope": rules})
+}
+
+func (h *Handler) GetScopeRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.Usecase.GetScopeRule(ctx.Request.Context(), projectId(ctx), id)
+	if err != nil {
+		h.scopeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"scope_rule": rule})
+}
+
+func (h *Handler) CreateScopeRule(ctx *gin.Context) {
+	var input scopeRuleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.Usecase.CreateScopeRule(ctx.Request.Context(), input.rule(projectId(ctx), 0))
+	if err != nil {
+		h.scopeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"scope_rule": rule})
+}
+
+func (h *Handler) UpdateScopeRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var input scopeRuleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.Usecase.UpdateScopeRule(ctx.Request.Context(), input.rule(projectId(ctx), id))
+	if err != nil {
+		h.scopeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"scope_rule": rule})
+}
+
+func (h *Handler) DeleteScopeRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Usecase.DeleteScopeRule(ctx.Request.Context(), projectId(ctx), id); err != nil {
+		h.scopeError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) scopeError(ctx *gin.Context, err error) {
+	var errNoRule *models.ErrScopeRuleNotFound
+	var errBadRule *models.ErrBadScopeRule
+	switch {
+	case errors.As(err, &errNoRule):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &errBadRule):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errBadRule.Error()})
+	default:
+		h.Logger.Errorf("failed to handle scope rule %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
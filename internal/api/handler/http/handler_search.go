@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// Search finds captured exchanges containing the text of ?q= in their
+// path, query, headers or bodies, oldest first, so the first hit shows
+// where a value first appeared.
+func (h *Handler) Search(ctx *gin.Context) {
+	text := ctx.Query("q")
+	if text == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing search text in q"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := ctx.Query("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxSearchLimit {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+			return
+		}
+	}
+
+	results, err := h.Usecase.Search(ctx.Request.Context(), projectId(ctx), text, limit)
+	if err != nil {
+		h.Logger.Errorf("failed to search %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"proxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetWebSockets(ctx *gin.Context) {
+	sockets, err := h.Usecase.GetWebSockets(ctx.Request.Context(), projectId(ctx))
+	if err != nil {
+		var errNoRequests *models.ErrRequestNotFuound
+		if errors.As(err, &errNoRequests) {
+			ctx.JSON(http.StatusNoContent, gin.H{"error": err.Error()})
+			return
+		}
+		h.Logger.Errorf("failed to get websockets %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"websockets": sockets})
+}
+
+func (h *Handler) GetWebSocketMessages(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := h.Usecase.GetRequestById(ctx.Request.Context(), projectId(ctx), id)
+	if err != nil {
+		var errNoRequests *models.ErrRequestNotFuound
+		if errors.As(err, &errNoRequests) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	messages, err := h.Usecase.GetWebSocketMessages(ctx.Request.Context(), projectId(ctx), id)
+	if err != nil {
+		h.Logger.Errorf("failed to get websocket messages %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"request": request, "messages": messages})
+}
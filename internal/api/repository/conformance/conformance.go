@@ -0,0 +1,839 @@
+// Package conformance is the behaviour every repository.Repository backend
+// must share. Each backend runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, func(t *testing.T) repository.Repository {
+//			return newEmptyRepository(t)
+//		})
+//	}
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/models"
+)
+
+// Opener returns an empty repository, holding only the active default
+// project a new database starts with. It is called once per test.
+type Opener func(t *testing.T) repository.Repository
+
+// Run checks a backend against the suite.
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.Repository)
+	}{
+		{"RequestRoundTrip", testRequestRoundTrip},
+		{"ResponseRoundTrip", testResponseRoundTrip},
+		{"NotFound", testNotFound},
+		{"SaveExchanges", testSaveExchanges},
+		{"SaveExchangesBadRow", testSaveExchangesBadRow},
+		{"ListFilters", testListFilters},
+		{"ListSort", testListSort},
+		{"ListPagination", testListPagination},
+		{"ListBadFilter", testListBadFilter},
+		{"ListRegexpSyntax", testListRegexpSyntax},
+		{"Search", testSearch},
+		{"SearchSnippet", testSearchSnippet},
+		{"WebSockets", testWebSockets},
+		{"Projects", testProjects},
+		{"ProjectIsolation", testProjectIsolation},
+		{"DeleteProject", testDeleteProject},
+		{"Notes", testNotes},
+		{"ScanResults", testScanResults},
+		{"Rules", testRules},
+		{"ScopeRules", testScopeRules},
+		{"PassthroughHosts", testPassthroughHosts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// base is when the seeded history starts. It has no sub-microsecond part,
+// which not every backend keeps.
+var base = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+// missingId is an id no test ever creates.
+const missingId = 999999
+
+// activeProject returns the id of the project the history is captured in.
+func activeProject(t *testing.T, repo repository.Repository) uint64 {
+	t.Helper()
+
+	project, err := repo.GetActiveProject(context.Background())
+	if err != nil {
+		t.Fatalf("GetActiveProject: %v", err)
+	}
+	return project.Id
+}
+
+func testRequestRoundTrip(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+
+	body := make([]byte, 256)
+	for i := range body {
+		body[i] = byte(i)
+	}
+	want := models.Request{
+		ProjectId:   project,
+		Method:      "POST",
+		Scheme:      "https",
+		Path:        "/upload",
+		Host:        "files.example.com",
+		Get_Params:  map[string][]string{"a": {"1", "2"}},
+		Headers:     map[string][]string{"Content-Type": {"application/octet-stream"}, "Accept-Encoding": {"gzip"}},
+		Cookies:     map[string]string{"session": "s1"},
+		Post_Params: map[string][]string{"name": {"value"}},
+		Body:        body,
+		DecodedBody: "decoded",
+		Encodings:   []string{"gzip"},
+		MimeType:    "application/octet-stream",
+		BodySize:    4096,
+		Truncated:   true,
+		Proto:       "HTTP/2.0",
+		FiredRules:  []uint64{3, 5},
+	}
+
+	before := time.Now()
+	id, err := repo.SaveRequest(ctx, want)
+	if err != nil {
+		t.Fatalf("SaveRequest: %v", err)
+	}
+
+	got, err := repo.GetRequestById(ctx, project, id)
+	if err != nil {
+		t.Fatalf("GetRequestById: %v", err)
+	}
+	if got.Id != id {
+		t.Errorf("Id = %d, want %d", got.Id, id)
+	}
+	// SaveRequest stamps the time it was called.
+	if d := got.CreatedAt.Sub(before); d < -time.Minute || d > time.Minute {
+		t.Errorf("CreatedAt = %v, want about %v", got.CreatedAt, before)
+	}
+
+	want.Id, want.CreatedAt = got.Id, got.CreatedAt
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetRequestById = %+v\nwant %+v", *got, want)
+	}
+
+	gotBody, err := repo.GetRequestBody(ctx, project, id)
+	if err != nil {
+		t.Fatalf("GetRequestBody: %v", err)
+	}
+	wantBody := models.Body{Content: body, MimeType: want.MimeType, Size: want.BodySize, Truncated: true, Headers: want.Headers}
+	if !reflect.DeepEqual(*gotBody, wantBody) {
+		t.Errorf("GetRequestBody = %+v\nwant %+v", *gotBody, wantBody)
+	}
+}
+
+func testResponseRoundTrip(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+
+	requestId, err := repo.SaveRequest(ctx, models.Request{ProjectId: project, Method: "GET", Host: "example.com", Path: "/", Proto: "HTTP/1.1"})
+	if err != nil {
+		t.Fatalf("SaveRequest: %v", err)
+	}
+
+	want := models.Response{
+		RequestId:   requestId,
+		Code:        201,
+		Headers:     map[string][]string{"Content-Type": {"text/plain; charset=utf-8"}, "Set-Cookie": {"a=1", "b=2"}},
+		Body:        []byte("\x1f\x8b\x00compressed"),
+		DecodedBody: "created",
+		Encodings:   []string{"gzip"},
+		MimeType:    "text/plain",
+		BodySize:    13,
+		Proto:       "HTTP/1.1",
+		WaitMs:      12,
+		DurationMs:  34,
+	}
+	if err := repo.SaveResponse(ctx, want); err != nil {
+		t.Fatalf("SaveResponse: %v", err)
+	}
+
+	got, err := repo.GetResponseByRequestId(ctx, project, requestId)
+	if err != nil {
+		t.Fatalf("GetResponseByRequestId: %v", err)
+	}
+	if got.Id == 0 || got.CreatedAt.IsZero() {
+		t.Errorf("GetResponseByRequestId left Id = %d, CreatedAt = %v unset", got.Id, got.CreatedAt)
+	}
+
+	want.Id, want.CreatedAt = got.Id, got.CreatedAt
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetResponseByRequestId = %+v\nwant %+v", *got, want)
+	}
+
+	byId, err := repo.GetResponseById(ctx, project, got.Id)
+	if err != nil {
+		t.Fatalf("GetResponseById: %v", err)
+	}
+	if !reflect.DeepEqual(byId, got) {
+		t.Errorf("GetResponseById = %+v\nwant %+v", *byId, *got)
+	}
+
+	body, err := repo.GetResponseBody(ctx, project, got.Id)
+	if err != nil {
+		t.Fatalf("GetResponseBody: %v", err)
+	}
+	if !bytes.Equal(body.Content, want.Body) || body.MimeType != want.MimeType || body.Size != want.BodySize || body.Truncated {
+		t.Errorf("GetResponseBody = %+v", *body)
+	}
+	if !reflect.DeepEqual(body.Headers, want.Headers) {
+		t.Errorf("GetResponseBody headers = %v, want %v", body.Headers, want.Headers)
+	}
+
+	if err := repo.SaveResponse(ctx, models.Response{RequestId: missingId, Code: 200}); err == nil {
+		t.Errorf("SaveResponse for a missing request succeeded")
+	}
+}
+
+func testNotFound(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+
+	requestId, err := repo.SaveRequest(ctx, models.Request{ProjectId: project, Method: "GET", Host: "example.com", Path: "/"})
+	if err != nil {
+		t.Fatalf("SaveRequest: %v", err)
+	}
+
+	requestNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrRequestNotFuound)) {
+			t.Errorf("%s: err = %v, want request not found", name, err)
+		}
+	}
+	responseNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrResponseNotFound)) {
+			t.Errorf("%s: err = %v, want response not found", name, err)
+		}
+	}
+
+	_, err = repo.GetRequestById(ctx, project, missingId)
+	requestNotFound("GetRequestById", err)
+	_, err = repo.GetRequestBody(ctx, project, missingId)
+	requestNotFound("GetRequestBody", err)
+
+	_, err = repo.GetResponseById(ctx, project, missingId)
+	responseNotFound("GetResponseById", err)
+	_, err = repo.GetResponseBody(ctx, project, missingId)
+	responseNotFound("GetResponseBody", err)
+	_, err = repo.GetResponseByRequestId(ctx, project, requestId)
+	responseNotFound("GetResponseByRequestId without response", err)
+	_, err = repo.GetResponseByRequestId(ctx, project, missingId)
+	responseNotFound("GetResponseByRequestId", err)
+
+	projectNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrProjectNotFound)) {
+			t.Errorf("%s: err = %v, want project not found", name, err)
+		}
+	}
+	_, err = repo.GetProject(ctx, missingId)
+	projectNotFound("GetProject", err)
+	projectNotFound("SetActiveProject", repo.SetActiveProject(ctx, missingId))
+	projectNotFound("SetProjectArchived", repo.SetProjectArchived(ctx, missingId, true))
+	projectNotFound("DeleteProject", repo.DeleteProject(ctx, missingId))
+
+	noteNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrNoteNotFound)) {
+			t.Errorf("%s: err = %v, want note not found", name, err)
+		}
+	}
+	_, err = repo.GetNote(ctx, project, missingId)
+	noteNotFound("GetNote", err)
+	noteNotFound("UpdateNote", repo.UpdateNote(ctx, models.Note{Id: missingId, ProjectId: project, Text: "x"}))
+	noteNotFound("DeleteNote", repo.DeleteNote(ctx, project, missingId))
+}
+
+func testSaveExchanges(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+
+	if err := repo.SaveExchanges(ctx, nil); err != nil {
+		t.Fatalf("SaveExchanges(nil): %v", err)
+	}
+
+	exchanges := []models.Exchange{
+		{
+			Request:  &models.Request{ProjectId: project, Method: "GET", Scheme: "https", Host: "a.test", Path: "/one", Proto: "HTTP/2.0", CreatedAt: at(0), FiredRules: []uint64{1}},
+			Response: &models.Response{Code: 200, Body: []byte("one"), BodySize: 3, Proto: "HTTP/2.0", WaitMs: 5, DurationMs: 7, CreatedAt: at(1)},
+		},
+		{
+			// Nothing came back.
+			Request: &models.Request{ProjectId: project, Method: "GET", Host: "b.test", Path: "/two", CreatedAt: at(2)},
+		},
+		{
+			// Unset scheme, proto and time get defaults.
+			Request:  &models.Request{ProjectId: project, Method: "POST", Host: "c.test", Path: "/three"},
+			Response: &models.Response{Code: 204},
+		},
+	}
+	before := time.Now()
+	var partial *models.ErrPartialSave
+	if err := repo.SaveExchanges(ctx, exchanges); err != nil && (!errors.As(err, &partial) || partial.Dropped != 1 || partial.Total != 3) {
+		t.Fatalf("SaveExchanges: %v, want nil or 1 of 3 dropped", err)
+	}
+
+	page, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: project})
+	if err != nil {
+		t.Fatalf("ListRequests: %v", err)
+	}
+	if got := paths(page.Requests); !reflect.DeepEqual(got, []string{"/one", "/two", "/three"}) {
+		t.Fatalf("saved %v, want /one /two /three", got)
+	}
+	first, second, third := page.Requests[0], page.Requests[1], page.Requests[2]
+
+	if !first.CreatedAt.Equal(at(0)) || first.Proto != "HTTP/2.0" {
+		t.Errorf("first request CreatedAt = %v, Proto = %q", first.CreatedAt, first.Proto)
+	}
+	if third.Proto != "HTTP/1.1" {
+		t.Errorf("request without proto got %q, want HTTP/1.1", third.Proto)
+	}
+	if first.Scheme != "https" || third.Scheme != "http" {
+		t.Errorf("Scheme = %q and %q, want https and http", first.Scheme, third.Scheme)
+	}
+	if !reflect.DeepEqual(first.FiredRules, []uint64{1}) || third.FiredRules == nil || len(third.FiredRules) != 0 {
+		t.Errorf("FiredRules = %v and %v, want [1] and []", first.FiredRules, third.FiredRules)
+	}
+	if d := third.CreatedAt.Sub(before); d < -time.Minute || d > time.Minute {
+		t.Errorf("request without time got CreatedAt = %v, want about %v", third.CreatedAt, before)
+	}
+
+	resp, err := repo.GetResponseByRequestId(ctx, project, first.Id)
+	if err != nil {
+		t.Fatalf("GetResponseByRequestId: %v", err)
+	}
+	if resp.RequestId != first.Id || resp.Code != 200 || string(resp.Body) != "one" ||
+		resp.WaitMs != 5 || resp.DurationMs != 7 || !resp.CreatedAt.Equal(at(1)) {
+		t.Errorf("first response = %+v", *resp)
+	}
+
+	if _, err := repo.GetResponseByRequestId(ctx, project, second.Id); !errors.As(err, new(*models.ErrResponseNotFound)) {
+		t.Errorf("request without response: err = %v, want response not found", err)
+	}
+
+	resp, err = repo.GetResponseByRequestId(ctx, project, third.Id)
+	if err != nil {
+		t.Fatalf("GetResponseByRequestId: %v", err)
+	}
+	if resp.Code != 204 || resp.Proto != "HTTP/1.1" {
+		t.Errorf("third response = %+v", *resp)
+	}
+}
+
+// seed stores a small history in project and returns the request ids in
+// order.
+func seed(t *testing.T, repo repository.Repository, project uint64) []uint64 {
+	t.Helper()
+
+	exchanges := []models.Exchange{
+		{
+			Request: &models.Request{
+				Method:     "GET",
+				Host:       "api.example.com",
+				Path:       "/v1/users",
+				Get_Params: map[string][]string{"page": {"2"}},
+				Headers:    map[string][]string{"Accept": {"text/html", "application/json"}, "X-Token": {"abc"}},
+				CreatedAt:  at(0),
+			},
+			Response: &models.Response{
+				Code:        200,
+				Headers:     map[string][]string{"Content-Type": {"application/json"}},
+				DecodedBody: `{"users":["alice","bob"]}`,
+				MimeType:    "application/json",
+				BodySize:    120,
+			},
+		},
+		{
+			Request: &models.Request{
+				Method:      "POST",
+				Host:        "api.example.com:8443",
+				Path:        "/v1/login",
+				Cookies:     map[string]string{"session": "s1"},
+				Post_Params: map[string][]string{"user": {"alice"}},
+				DecodedBody: "user=alice",
+				BodySize:    10,
+				CreatedAt:   at(1),
+			},
+			Response: &models.Response{
+				Code:    302,
+				Headers: map[string][]string{"Location": {"/home"}},
+			},
+		},
+		{
+			Request: &models.Request{Method: "GET", Host: "www.example.org", Path: "/index.html", CreatedAt: at(2)},
+			Response: &models.Response{
+				Code:        200,
+				DecodedBody: "<html>Welcome Home</html>",
+				MimeType:    "text/html",
+				BodySize:    2048,
+			},
+		},
+		{
+			Request:  &models.Request{Method: "DELETE", Host: "api.example.com", Path: "/v1/users/7", CreatedAt: at(3)},
+			Response: &models.Response{Code: 500, DecodedBody: `{"error":"boom"}`, MimeType: "application/json", BodySize: 40},
+		},
+		{
+			Request: &models.Request{Method: "GET", Host: "cdn.example.net", Path: "/static/app.js", CreatedAt: at(4)},
+		},
+		{
+			Request:  &models.Request{Method: "PUT", Host: "api.example.com", Path: "/v2/items", CreatedAt: at(5)},
+			Response: &models.Response{Code: 404, DecodedBody: "not found", MimeType: "text/plain", BodySize: 9},
+		},
+	}
+	for _, ex := range exchanges {
+		ex.Request.ProjectId = project
+	}
+	if err := repo.SaveExchanges(context.Background(), exchanges); err != nil {
+		t.Fatalf("SaveExchanges: %v", err)
+	}
+
+	page, err := repo.ListRequests(context.Background(), models.RequestFilter{ProjectId: project})
+	if err != nil {
+		t.Fatalf("ListRequests: %v", err)
+	}
+	if len(page.Requests) != len(exchanges) {
+		t.Fatalf("seeded %d requests, listed %d", len(exchanges), len(page.Requests))
+	}
+
+	ids := make([]uint64, len(page.Requests))
+	for i, req := range page.Requests {
+		ids[i] = req.Id
+	}
+	return ids
+}
+
+func paths(requests []models.Request) []string {
+	out := []string{}
+	for _, req := range requests {
+		out = append(out, req.Path)
+	}
+	return out
+}
+
+// picked returns the ids of the seeded requests at the given positions.
+func picked(ids []uint64, positions ...int) []uint64 {
+	out := []uint64{}
+	for _, p := range positions {
+		out = append(out, ids[p])
+	}
+	return out
+}
+
+func requestIds(requests []models.Request) []uint64 {
+	out := []uint64{}
+	for _, req := range requests {
+		out = append(out, req.Id)
+	}
+	return out
+}
+
+func int64p(n int64) *int64 {
+	return &n
+}
+
+// testSaveExchangesBadRow checks that an exchange some backend refuses,
+// here a path past the Postgres length check, costs at most itself, and
+// that a backend refusing it says so.
+func testSaveExchangesBadRow(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+
+	exchanges := []models.Exchange{
+		{
+			Request:  &models.Request{ProjectId: project, Method: "GET", Host: "a.test", Path: "/before", CreatedAt: at(0)},
+			Response: &models.Response{Code: 200, Body: []byte("before")},
+		},
+		{
+			Request:  &models.Request{ProjectId: project, Method: "GET", Host: "a.test", Path: "/" + strings.Repeat("x", 600), CreatedAt: at(1)},
+			Response: &models.Response{Code: 414},
+		},
+		{
+			Request:  &models.Request{ProjectId: project, Method: "GET", Host: "a.test", Path: "/after", CreatedAt: at(2)},
+			Response: &models.Response{Code: 200, Body: []byte("after")},
+		},
+	}
+	if err := repo.SaveExchanges(ctx, exchanges); err != nil {
+		t.Fatalf("SaveExchanges: %v", err)
+	}
+
+	page, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: project})
+	if err != nil {
+		t.Fatalf("ListRequests: %v", err)
+	}
+	saved := map[string]uint64{}
+	for _, req := range page.Requests {
+		saved[req.Path] = req.Id
+	}
+	for _, path := range []string{"/before", "/after"} {
+		id, ok := saved[path]
+		if !ok {
+			t.Errorf("%s was not saved next to the bad row, got %d requests", path, len(page.Requests))
+			continue
+		}
+		resp, err := repo.GetResponseByRequestId(ctx, project, id)
+		if err != nil {
+			t.Errorf("response of %s: %v", path, err)
+		} else if string(resp.Body) != strings.TrimPrefix(path, "/") {
+			t.Errorf("response of %s = %q", path, resp.Body)
+		}
+	}
+}
+
+func testListFilters(t *testing.T, repo repository.Repository) {
+	project := activeProject(t, repo)
+	ids := seed(t, repo, project)
+
+	tests := []struct {
+		name   string
+		filter models.RequestFilter
+		want   []int
+	}{
+		{"all", models.RequestFilter{}, []int{0, 1, 2, 3, 4, 5}},
+		{"method", models.RequestFilter{Methods: []string{"get"}}, []int{0, 2, 4}},
+		{"methods", models.RequestFilter{Methods: []string{"POST", "put"}}, []int{1, 5}},
+		{"host with port", models.RequestFilter{Host: "API.example.com"}, []int{0, 1, 3, 5}},
+		{"host glob", models.RequestFilter{Host: "*.example.org"}, []int{2}},
+		{"path prefix", models.RequestFilter{PathPrefix: "/v1/"}, []int{0, 1, 3}},
+		{"path prefix is case sensitive", models.RequestFilter{PathPrefix: "/V1"}, []int{}},
+		{"path prefix is literal", models.RequestFilter{PathPrefix: "/v_"}, []int{}},
+		{"status min", models.RequestFilter{StatusMin: 400}, []int{3, 5}},
+		{"status range", models.RequestFilter{StatusMin: 200, StatusMax: 299}, []int{0, 2}},
+		{"content type", models.RequestFilter{ContentType: "JSON"}, []int{0, 3}},
+		{"from", models.RequestFilter{From: at(2)}, []int{2, 3, 4, 5}},
+		{"to", models.RequestFilter{To: at(2)}, []int{0, 1}},
+		{"min size", models.RequestFilter{MinSize: int64p(100)}, []int{0, 2}},
+		{"max size counts missing responses as empty", models.RequestFilter{MaxSize: int64p(0)}, []int{1, 4}},
+		{"expression", models.RequestFilter{Expression: `resp.status >= 500 or req.method == "PUT"`}, []int{3, 5}},
+		{"header key is case insensitive", models.RequestFilter{Expression: `req.header["accept"] contains "json"`}, []int{0}},
+		{"any header value", models.RequestFilter{Expression: `req.header["Accept"] == "text/html"`}, []int{0}},
+		{"cookie exists", models.RequestFilter{Expression: `req.cookie["session"]`}, []int{1}},
+		{"form value", models.RequestFilter{Expression: `req.form["user"] == "alice"`}, []int{1}},
+		{"query value", models.RequestFilter{Expression: `req.query["page"] == "2"`}, []int{0}},
+		{"no response", models.RequestFilter{Expression: `not resp.status`}, []int{4}},
+		{"negation includes missing responses", models.RequestFilter{Expression: `resp.status != 200`}, []int{1, 3, 4, 5}},
+		{"regex", models.RequestFilter{Expression: `resp.body ~ "^\{"`}, []int{0, 3}},
+		{"time", models.RequestFilter{Expression: `created_at >= "2024-01-02T03:07:05Z"`}, []int{3, 4, 5}},
+		{"request size", models.RequestFilter{Expression: `req.size > 5`}, []int{1}},
+		{"expression and fields", models.RequestFilter{Methods: []string{"GET"}, Expression: `host == "www.example.org"`}, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.ProjectId = project
+			page, err := repo.ListRequests(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("ListRequests: %v", err)
+			}
+			if got, want := requestIds(page.Requests), picked(ids, tt.want...); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("NextCursor = %q on the only page", page.NextCursor)
+			}
+		})
+	}
+}
+
+func testListSort(t *testing.T, repo repository.Repository) {
+	project := activeProject(t, repo)
+	ids := seed(t, repo, project)
+
+	tests := []struct {
+		sort string
+		desc bool
+		want []int
+	}{
+		{"created_at", false, []int{0, 1, 2, 3, 4, 5}},
+		{"created_at", true, []int{5, 4, 3, 2, 1, 0}},
+		{"host", false, []int{0, 3, 5, 1, 4, 2}},
+		{"host", true, []int{2, 4, 1, 5, 3, 0}},
+		{"status", false, []int{4, 0, 2, 1, 5, 3}},
+		{"status", true, []int{3, 5, 1, 2, 0, 4}},
+		{"size", false, []int{1, 4, 5, 3, 0, 2}},
+		{"size", true, []int{2, 0, 3, 5, 4, 1}},
+	}
+
+	for _, tt := range tests {
+		page, err := repo.ListRequests(context.Background(), models.RequestFilter{ProjectId: project, Sort: tt.sort, Desc: tt.desc})
+		if err != nil {
+			t.Fatalf("ListRequests(%s, desc=%v): %v", tt.sort, tt.desc, err)
+		}
+		if got, want := requestIds(page.Requests), picked(ids, tt.want...); !reflect.DeepEqual(got, want) {
+			t.Errorf("sort %s desc=%v: got %v, want %v", tt.sort, tt.desc, got, want)
+		}
+	}
+}
+
+func testListPagination(t *testing.T, repo repository.Repository) {
+	project := activeProject(t, repo)
+	seed(t, repo, project)
+	ctx := context.Background()
+
+	for _, sort := range []string{"created_at", "host", "status", "size"} {
+		for _, desc := range []bool{false, true} {
+			all, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: project, Sort: sort, Desc: desc})
+			if err != nil {
+				t.Fatalf("ListRequests: %v", err)
+			}
+
+			var paged []uint64
+			filter := models.RequestFilter{ProjectId: project, Sort: sort, Desc: desc, Limit: 4}
+			for pages := 0; ; pages++ {
+				if pages > len(all.Requests) {
+					t.Fatalf("sort %s desc=%v: pagination does not end", sort, desc)
+				}
+				page, err := repo.ListRequests(ctx, filter)
+				if err != nil {
+					t.Fatalf("sort %s desc=%v: ListRequests: %v", sort, desc, err)
+				}
+				if len(page.Requests) > filter.Limit {
+					t.Fatalf("sort %s desc=%v: page of %d with limit %d", sort, desc, len(page.Requests), filter.Limit)
+				}
+				paged = append(paged, requestIds(page.Requests)...)
+				if page.NextCursor == "" {
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+
+			if want := requestIds(all.Requests); !reflect.DeepEqual(paged, want) {
+				t.Errorf("sort %s desc=%v: pages %v, want %v", sort, desc, paged, want)
+			}
+		}
+	}
+
+	// A page that ends exactly with the history has no cursor.
+	page, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: project, Limit: 6})
+	if err != nil {
+		t.Fatalf("ListRequests: %v", err)
+	}
+	if page.NextCursor != "" {
+		t.Errorf("NextCursor = %q after the last request", page.NextCursor)
+	}
+}
+
+func testListBadFilter(t *testing.T, repo repository.Repository) {
+	project := activeProject(t, repo)
+	seed(t, repo, project)
+	ctx := context.Background()
+
+	page, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: project, Sort: "host", Limit: 1})
+	if err != nil {
+		t.Fatalf("ListRequests: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.RequestFilter
+	}{
+		{"sort key", models.RequestFilter{Sort: "nope"}},
+		{"expression", models.RequestFilter{Expression: `req.nope == 1`}},
+		{"limit", models.RequestFilter{Limit: repository.MaxPageSize + 1}},
+		{"cursor", models.RequestFilter{Cursor: "!!!"}},
+		{"cursor of another order", models.RequestFilter{Sort: "host", Desc: true, Cursor: page.NextCursor}},
+	}
+
+	for _, tt := range tests {
+		tt.filter.ProjectId = project
+		_, err := repo.ListRequests(ctx, tt.filter)
+		if !errors.As(err, new(*models.ErrBadFilter)) {
+			t.Errorf("%s: err = %v, want a bad filter error", tt.name, err)
+		}
+	}
+}
+
+// testListRegexpSyntax checks that ~ follows Go's syntax everywhere, in
+// the places where other dialects read a pattern differently.
+func testListRegexpSyntax(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+
+	bodies := map[string]string{"/api": "abc", "/apix": "a\nc", "/lines": "a\nb\nc", "/upper": "ACME K"}
+	var exchanges []models.Exchange
+	for i, path := range []string{"/api", "/apix", "/lines", "/upper"} {
+		exchanges = append(exchanges, models.Exchange{
+			Request: &models.Request{ProjectId: project, Method: "POST", Host: "re.test", Path: path, DecodedBody: bodies[path], CreatedAt: at(i)},
+		})
+	}
+	if err := repo.SaveExchanges(ctx, exchanges); err != nil {
+		t.Fatalf("SaveExchanges: %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{`req.body ~ "a.c"`, []string{"/api"}},
+		{`req.body ~ "(?s)a.c"`, []string{"/api", "/apix"}},
+		{`req.body ~ "^b$"`, []string{}},
+		{`req.body ~ "(?m)^b$"`, []string{"/lines"}},
+		{`req.path ~ "\\bapi\\b"`, []string{"/api"}},
+		{`req.path ~ "api\\B"`, []string{"/apix"}},
+		{`req.body ~ "(?i)acme k"`, []string{"/upper"}},
+		{`req.body ~ "\\Aa\\z"`, []string{}},
+		{`req.body !~ "[[:alpha:]]{2,}"`, []string{"/apix", "/lines"}},
+	}
+	for _, tt := range tests {
+		page, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: project, Expression: tt.expr})
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := paths(page.Requests); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func testSearch(t *testing.T, repo repository.Repository) {
+	project := activeProject(t, repo)
+	ids := seed(t, repo, project)
+	ctx := context.Background()
+
+	type hit struct {
+		id    uint64
+		field string
+	}
+	tests := []struct {
+		text  string
+		limit int
+		want  []hit
+	}{
+		{"alice", 10, []hit{{ids[0], "resp.body"}, {ids[1], "req.body"}}},
+		{"ALICE", 10, []hit{{ids[0], "resp.body"}, {ids[1], "req.body"}}},
+		{"alice", 1, []hit{{ids[0], "resp.body"}}},
+		{"welcome", 10, []hit{{ids[2], "resp.body"}}},
+		{"x-token", 10, []hit{{ids[0], "req.header"}}},
+		{"/home", 10, []hit{{ids[1], "resp.header"}}},
+		{"users", 10, []hit{{ids[0], "req.path"}, {ids[0], "resp.body"}, {ids[3], "req.path"}}},
+		{"page", 10, []hit{{ids[0], "req.query"}}},
+		{"no such text", 10, []hit{}},
+	}
+
+	for _, tt := range tests {
+		results, err := repo.Search(ctx, project, tt.text, tt.limit)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", tt.text, err)
+		}
+
+		got := []hit{}
+		for _, res := range results {
+			got = append(got, hit{res.RequestId, res.Field})
+			if !strings.Contains(strings.ToLower(res.Snippet), strings.ToLower(tt.text)) {
+				t.Errorf("Search(%q): snippet %q does not contain the text", tt.text, res.Snippet)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q, %d) = %v, want %v", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func testSearchSnippet(t *testing.T, repo repository.Repository) {
+	project := activeProject(t, repo)
+	body := strings.Repeat("a", 100) + "Needle" + strings.Repeat("b", 100)
+	exchanges := []models.Exchange{{Request: &models.Request{ProjectId: project, Method: "POST", Host: "example.com", Path: "/", DecodedBody: body, CreatedAt: at(0)}}}
+	if err := repo.SaveExchanges(context.Background(), exchanges); err != nil {
+		t.Fatalf("SaveExchanges: %v", err)
+	}
+
+	results, err := repo.Search(context.Background(), project, "needle", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Search returned %d results, want 1", len(results))
+	}
+
+	res := results[0]
+	if want := strings.Repeat("a", 60) + "Needle" + strings.Repeat("b", 60); res.Snippet != want {
+		t.Errorf("Snippet = %q, want %q", res.Snippet, want)
+	}
+	if !res.CreatedAt.Equal(at(0)) {
+		t.Errorf("CreatedAt = %v, want %v", res.CreatedAt, at(0))
+	}
+}
+
+func testWebSockets(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+
+	if _, err := repo.GetWebSockets(ctx, project); !errors.As(err, new(*models.ErrRequestNotFuound)) {
+		t.Errorf("GetWebSockets on an empty history: err = %v, want request not found", err)
+	}
+
+	ids := seed(t, repo, project)
+	messages := []models.WebSocketMessage{
+		{RequestId: ids[2], Direction: "client", Opcode: 1, Payload: []byte("hello"), CreatedAt: at(10)},
+		{RequestId: ids[0], Direction: "client", Opcode: 1, Payload: []byte("ping"), CreatedAt: at(11)},
+		{RequestId: ids[2], Direction: "server", Opcode: 2, Payload: []byte{0, 1, 2}, CreatedAt: at(12)},
+	}
+	for _, msg := range messages {
+		if err := repo.SaveWebSocketMessage(ctx, msg); err != nil {
+			t.Fatalf("SaveWebSocketMessage: %v", err)
+		}
+	}
+
+	sockets, err := repo.GetWebSockets(ctx, project)
+	if err != nil {
+		t.Fatalf("GetWebSockets: %v", err)
+	}
+	if len(sockets) != 2 {
+		t.Fatalf("GetWebSockets returned %d sockets, want 2", len(sockets))
+	}
+	if ws := sockets[0]; ws.RequestId != ids[0] || ws.Host != "api.example.com" || ws.Path != "/v1/users" || ws.MessageCount != 1 || !ws.CreatedAt.Equal(at(0)) {
+		t.Errorf("first socket = %+v", ws)
+	}
+	if ws := sockets[1]; ws.RequestId != ids[2] || ws.MessageCount != 2 {
+		t.Errorf("second socket = %+v", ws)
+	}
+
+	got, err := repo.GetWebSocketMessages(ctx, project, ids[2])
+	if err != nil {
+		t.Fatalf("GetWebSocketMessages: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("GetWebSocketMessages returned %d messages, want 2", len(got))
+	}
+	for i, want := range []models.WebSocketMessage{messages[0], messages[2]} {
+		msg := got[i]
+		if msg.Id == 0 || msg.RequestId != want.RequestId || msg.Direction != want.Direction || msg.Opcode != want.Opcode ||
+			!bytes.Equal(msg.Payload, want.Payload) || !msg.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("message %d = %+v, want %+v", i, msg, want)
+		}
+	}
+	if got[0].Id >= got[1].Id {
+		t.Errorf("messages out of order: ids %d, %d", got[0].Id, got[1].Id)
+	}
+
+	none, err := repo.GetWebSocketMessages(ctx, project, ids[1])
+	if err != nil {
+		t.Fatalf("GetWebSocketMessages: %v", err)
+	}
+	if len(none) != 0 {
+		t.Errorf("GetWebSocketMessages for a plain request returned %d messages", len(none))
+	}
+}
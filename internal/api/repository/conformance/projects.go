@@ -0,0 +1,340 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/models"
+)
+
+func saveProject(t *testing.T, repo repository.Repository, name string) uint64 {
+	t.Helper()
+
+	id, err := repo.SaveProject(context.Background(), models.Project{Name: name})
+	if err != nil {
+		t.Fatalf("SaveProject(%q): %v", name, err)
+	}
+	return id
+}
+
+func projectNames(projects []models.Project) []string {
+	out := []string{}
+	for _, p := range projects {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
+func testProjects(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	projects, err := repo.ListProjects(ctx, false)
+	if err != nil {
+		t.Fatalf("ListProjects: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Name != "default" || !projects[0].Active || projects[0].Archived {
+		t.Fatalf("a new repository has projects %+v, want an active default", projects)
+	}
+	def := projects[0].Id
+
+	id, err := repo.SaveProject(ctx, models.Project{Name: "acme", Description: "Q3 engagement", Active: true, Archived: true})
+	if err != nil {
+		t.Fatalf("SaveProject: %v", err)
+	}
+	got, err := repo.GetProject(ctx, id)
+	if err != nil {
+		t.Fatalf("GetProject: %v", err)
+	}
+	// A new project starts inactive and unarchived whatever it was given.
+	if got.Id != id || got.Name != "acme" || got.Description != "Q3 engagement" || got.Active || got.Archived || got.CreatedAt.IsZero() {
+		t.Errorf("GetProject = %+v", *got)
+	}
+
+	if _, err := repo.SaveProject(ctx, models.Project{Name: "acme"}); !errors.As(err, new(*models.ErrProjectConflict)) {
+		t.Errorf("SaveProject with a taken name: err = %v, want a conflict", err)
+	}
+
+	if err := repo.SetActiveProject(ctx, id); err != nil {
+		t.Fatalf("SetActiveProject: %v", err)
+	}
+	if active := activeProject(t, repo); active != id {
+		t.Errorf("active project = %d after activating %d", active, id)
+	}
+	if p, err := repo.GetProject(ctx, def); err != nil || p.Active {
+		t.Errorf("default project after switching: %+v, %v", p, err)
+	}
+	// Activating the active project changes nothing.
+	if err := repo.SetActiveProject(ctx, id); err != nil {
+		t.Fatalf("SetActiveProject again: %v", err)
+	}
+	if active := activeProject(t, repo); active != id {
+		t.Errorf("active project = %d after activating %d twice", active, id)
+	}
+
+	if err := repo.SetProjectArchived(ctx, def, true); err != nil {
+		t.Fatalf("SetProjectArchived: %v", err)
+	}
+	if projects, err := repo.ListProjects(ctx, false); err != nil || !reflect.DeepEqual(projectNames(projects), []string{"acme"}) {
+		t.Errorf("ListProjects without archived = %v, %v, want acme", projectNames(projects), err)
+	}
+	projects, err = repo.ListProjects(ctx, true)
+	if err != nil || !reflect.DeepEqual(projectNames(projects), []string{"default", "acme"}) {
+		t.Fatalf("ListProjects with archived = %v, %v, want default acme", projectNames(projects), err)
+	}
+	if !projects[0].Archived || projects[1].Archived {
+		t.Errorf("archived flags = %v, %v", projects[0].Archived, projects[1].Archived)
+	}
+
+	if err := repo.SetProjectArchived(ctx, def, false); err != nil {
+		t.Fatalf("SetProjectArchived: %v", err)
+	}
+	if projects, err := repo.ListProjects(ctx, false); err != nil || len(projects) != 2 {
+		t.Errorf("ListProjects after unarchiving = %v, %v", projectNames(projects), err)
+	}
+}
+
+func testProjectIsolation(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+	other := saveProject(t, repo, "other")
+
+	// seed fails if it lists the other project's requests too.
+	ids := seed(t, repo, project)
+	otherIds := seed(t, repo, other)
+
+	requestNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrRequestNotFuound)) {
+			t.Errorf("%s from another project: err = %v, want request not found", name, err)
+		}
+	}
+	responseNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrResponseNotFound)) {
+			t.Errorf("%s from another project: err = %v, want response not found", name, err)
+		}
+	}
+
+	_, err := repo.GetRequestById(ctx, other, ids[0])
+	requestNotFound("GetRequestById", err)
+	_, err = repo.GetRequestBody(ctx, other, ids[0])
+	requestNotFound("GetRequestBody", err)
+	_, err = repo.GetResponseByRequestId(ctx, other, ids[0])
+	responseNotFound("GetResponseByRequestId", err)
+
+	resp, err := repo.GetResponseByRequestId(ctx, project, ids[0])
+	if err != nil {
+		t.Fatalf("GetResponseByRequestId: %v", err)
+	}
+	_, err = repo.GetResponseById(ctx, other, resp.Id)
+	responseNotFound("GetResponseById", err)
+	_, err = repo.GetResponseBody(ctx, other, resp.Id)
+	responseNotFound("GetResponseBody", err)
+
+	results, err := repo.Search(ctx, other, "alice", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	for _, res := range results {
+		if res.RequestId != otherIds[0] && res.RequestId != otherIds[1] {
+			t.Errorf("Search in another project found request %d", res.RequestId)
+		}
+	}
+	if len(results) != 2 {
+		t.Errorf("Search returned %d results, want 2", len(results))
+	}
+
+	if err := repo.SaveWebSocketMessage(ctx, models.WebSocketMessage{RequestId: ids[0], Direction: "client", Opcode: 1, Payload: []byte("hi"), CreatedAt: at(10)}); err != nil {
+		t.Fatalf("SaveWebSocketMessage: %v", err)
+	}
+	if _, err := repo.GetWebSockets(ctx, other); !errors.As(err, new(*models.ErrRequestNotFuound)) {
+		t.Errorf("GetWebSockets of another project: err = %v, want request not found", err)
+	}
+	if messages, err := repo.GetWebSocketMessages(ctx, other, ids[0]); err != nil || len(messages) != 0 {
+		t.Errorf("GetWebSocketMessages from another project = %d messages, %v", len(messages), err)
+	}
+}
+
+func testDeleteProject(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+	other := saveProject(t, repo, "other")
+
+	ids := seed(t, repo, project)
+	otherIds := seed(t, repo, other)
+
+	if err := repo.SaveWebSocketMessage(ctx, models.WebSocketMessage{RequestId: otherIds[0], Direction: "client", Opcode: 1, CreatedAt: at(10)}); err != nil {
+		t.Fatalf("SaveWebSocketMessage: %v", err)
+	}
+	if _, err := repo.SaveNote(ctx, models.Note{ProjectId: other, RequestId: otherIds[1], Text: "gone"}); err != nil {
+		t.Fatalf("SaveNote: %v", err)
+	}
+	if _, err := repo.SaveScanResult(ctx, models.ScanResult{ProjectId: other, RequestId: otherIds[1], HiddenParams: []string{"debug"}}); err != nil {
+		t.Fatalf("SaveScanResult: %v", err)
+	}
+
+	if err := repo.DeleteProject(ctx, other); err != nil {
+		t.Fatalf("DeleteProject: %v", err)
+	}
+
+	if _, err := repo.GetProject(ctx, other); !errors.As(err, new(*models.ErrProjectNotFound)) {
+		t.Errorf("GetProject after delete: err = %v, want project not found", err)
+	}
+	if _, err := repo.GetRequestById(ctx, other, otherIds[0]); !errors.As(err, new(*models.ErrRequestNotFuound)) {
+		t.Errorf("GetRequestById after delete: err = %v, want request not found", err)
+	}
+	if page, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: other}); err != nil || len(page.Requests) != 0 {
+		t.Errorf("ListRequests after delete = %v, %v", requestIds(page.Requests), err)
+	}
+	if notes, err := repo.ListNotes(ctx, other, 0); err != nil || len(notes) != 0 {
+		t.Errorf("ListNotes after delete = %+v, %v", notes, err)
+	}
+	if scans, err := repo.ListScanResults(ctx, other, 0); err != nil || len(scans) != 0 {
+		t.Errorf("ListScanResults after delete = %+v, %v", scans, err)
+	}
+
+	// The rest of the history stays.
+	page, err := repo.ListRequests(ctx, models.RequestFilter{ProjectId: project})
+	if err != nil {
+		t.Fatalf("ListRequests: %v", err)
+	}
+	if got := requestIds(page.Requests); !reflect.DeepEqual(got, ids) {
+		t.Errorf("remaining project lists %v, want %v", got, ids)
+	}
+	if _, err := repo.GetResponseByRequestId(ctx, project, ids[0]); err != nil {
+		t.Errorf("GetResponseByRequestId in the remaining project: %v", err)
+	}
+}
+
+func testNotes(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+	other := saveProject(t, repo, "other")
+	ids := seed(t, repo, project)
+
+	general, err := repo.SaveNote(ctx, models.Note{ProjectId: project, Text: "scope is *.example.com"})
+	if err != nil {
+		t.Fatalf("SaveNote: %v", err)
+	}
+	login, err := repo.SaveNote(ctx, models.Note{ProjectId: project, RequestId: ids[1], Text: "login takes any password?"})
+	if err != nil {
+		t.Fatalf("SaveNote: %v", err)
+	}
+
+	notes, err := repo.ListNotes(ctx, project, 0)
+	if err != nil {
+		t.Fatalf("ListNotes: %v", err)
+	}
+	if len(notes) != 2 || notes[0].Id != general || notes[1].Id != login {
+		t.Fatalf("ListNotes = %+v, want notes %d and %d", notes, general, login)
+	}
+	if notes[0].RequestId != 0 || notes[0].ProjectId != project || notes[0].Text != "scope is *.example.com" {
+		t.Errorf("project note = %+v", notes[0])
+	}
+
+	notes, err = repo.ListNotes(ctx, project, ids[1])
+	if err != nil {
+		t.Fatalf("ListNotes: %v", err)
+	}
+	if len(notes) != 1 || notes[0].Id != login || notes[0].RequestId != ids[1] {
+		t.Errorf("ListNotes of a request = %+v, want note %d", notes, login)
+	}
+
+	before, err := repo.GetNote(ctx, project, login)
+	if err != nil {
+		t.Fatalf("GetNote: %v", err)
+	}
+	if before.CreatedAt.IsZero() || before.UpdatedAt.Before(before.CreatedAt) {
+		t.Errorf("new note CreatedAt = %v, UpdatedAt = %v", before.CreatedAt, before.UpdatedAt)
+	}
+
+	if err := repo.UpdateNote(ctx, models.Note{Id: login, ProjectId: project, Text: "confirmed"}); err != nil {
+		t.Fatalf("UpdateNote: %v", err)
+	}
+	after, err := repo.GetNote(ctx, project, login)
+	if err != nil {
+		t.Fatalf("GetNote: %v", err)
+	}
+	if after.Text != "confirmed" || after.RequestId != ids[1] || !after.CreatedAt.Equal(before.CreatedAt) || after.UpdatedAt.Before(before.UpdatedAt) {
+		t.Errorf("updated note = %+v, was %+v", *after, *before)
+	}
+
+	noteNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrNoteNotFound)) {
+			t.Errorf("%s from another project: err = %v, want note not found", name, err)
+		}
+	}
+	_, err = repo.GetNote(ctx, other, login)
+	noteNotFound("GetNote", err)
+	noteNotFound("UpdateNote", repo.UpdateNote(ctx, models.Note{Id: login, ProjectId: other, Text: "x"}))
+	noteNotFound("DeleteNote", repo.DeleteNote(ctx, other, login))
+	if notes, err := repo.ListNotes(ctx, other, 0); err != nil || len(notes) != 0 {
+		t.Errorf("ListNotes of another project = %+v, %v", notes, err)
+	}
+
+	if err := repo.DeleteNote(ctx, project, general); err != nil {
+		t.Fatalf("DeleteNote: %v", err)
+	}
+	_, err = repo.GetNote(ctx, project, general)
+	if !errors.As(err, new(*models.ErrNoteNotFound)) {
+		t.Errorf("GetNote after delete: err = %v, want note not found", err)
+	}
+	if notes, err := repo.ListNotes(ctx, project, 0); err != nil || len(notes) != 1 || notes[0].Id != login {
+		t.Errorf("ListNotes after delete = %+v, %v", notes, err)
+	}
+}
+
+func testScanResults(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+	other := saveProject(t, repo, "other")
+	ids := seed(t, repo, project)
+
+	saved := []models.ScanResult{
+		{ProjectId: project, RequestId: ids[0], HiddenParams: []string{"debug", "admin"}},
+		{ProjectId: project, RequestId: ids[0]},
+		{ProjectId: project, RequestId: ids[2], HiddenParams: []string{"lang"}},
+	}
+	for i := range saved {
+		id, err := repo.SaveScanResult(ctx, saved[i])
+		if err != nil {
+			t.Fatalf("SaveScanResult: %v", err)
+		}
+		saved[i].Id = id
+	}
+
+	all, err := repo.ListScanResults(ctx, project, 0)
+	if err != nil {
+		t.Fatalf("ListScanResults: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("ListScanResults returned %d results, want 3", len(all))
+	}
+	for i, got := range all {
+		want := saved[i]
+		if want.HiddenParams == nil {
+			// Nothing found is an empty list, not a missing one.
+			want.HiddenParams = []string{}
+		}
+		if got.Id != want.Id || got.ProjectId != project || got.RequestId != want.RequestId ||
+			!reflect.DeepEqual(got.HiddenParams, want.HiddenParams) || got.CreatedAt.IsZero() {
+			t.Errorf("scan %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	byRequest, err := repo.ListScanResults(ctx, project, ids[0])
+	if err != nil {
+		t.Fatalf("ListScanResults: %v", err)
+	}
+	if len(byRequest) != 2 || byRequest[0].Id != saved[0].Id || byRequest[1].Id != saved[1].Id {
+		t.Errorf("ListScanResults of a request = %+v", byRequest)
+	}
+
+	if scans, err := repo.ListScanResults(ctx, other, 0); err != nil || len(scans) != 0 {
+		t.Errorf("ListScanResults of another project = %+v, %v", scans, err)
+	}
+}
@@ -0,0 +1,268 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/models"
+)
+
+func testRules(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	if rules, err := repo.ListRules(ctx); err != nil || rules == nil || len(rules) != 0 {
+		t.Fatalf("ListRules of a new database = %#v, %v, want none", rules, err)
+	}
+
+	agent := models.Rule{
+		Name:    "agent",
+		Enabled: true,
+		Target:  models.RuleRequestHeader,
+		Match:   `^User-Agent: .*$`,
+		Replace: "User-Agent: proxy",
+		Regex:   true,
+	}
+	banner := models.Rule{
+		Target:  models.RuleResponseBody,
+		Host:    "*.example.com",
+		Match:   "Welcome",
+		Replace: "Hello",
+	}
+	for _, rule := range []*models.Rule{&agent, &banner} {
+		id, err := repo.SaveRule(ctx, *rule)
+		if err != nil {
+			t.Fatalf("SaveRule: %v", err)
+		}
+		rule.Id = id
+	}
+
+	rules, err := repo.ListRules(ctx)
+	if err != nil {
+		t.Fatalf("ListRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Id != agent.Id || rules[1].Id != banner.Id {
+		t.Fatalf("ListRules = %+v, want rules %d and %d", rules, agent.Id, banner.Id)
+	}
+	for i, want := range []models.Rule{agent, banner} {
+		got := rules[i]
+		if got.CreatedAt.IsZero() || got.UpdatedAt.Before(got.CreatedAt) {
+			t.Errorf("new rule CreatedAt = %v, UpdatedAt = %v", got.CreatedAt, got.UpdatedAt)
+		}
+		want.CreatedAt, want.UpdatedAt = got.CreatedAt, got.UpdatedAt
+		if got != want {
+			t.Errorf("rule %d = %+v\nwant %+v", i, got, want)
+		}
+	}
+
+	before, err := repo.GetRule(ctx, banner.Id)
+	if err != nil {
+		t.Fatalf("GetRule: %v", err)
+	}
+	edited := banner
+	edited.Enabled, edited.Regex, edited.Match = true, true, `Welcome (\w+)`
+	if err := repo.UpdateRule(ctx, edited); err != nil {
+		t.Fatalf("UpdateRule: %v", err)
+	}
+	after, err := repo.GetRule(ctx, banner.Id)
+	if err != nil {
+		t.Fatalf("GetRule: %v", err)
+	}
+	if !after.Enabled || !after.Regex || after.Match != edited.Match || after.Host != banner.Host ||
+		!after.CreatedAt.Equal(before.CreatedAt) || after.UpdatedAt.Before(before.UpdatedAt) {
+		t.Errorf("updated rule = %+v, was %+v", *after, *before)
+	}
+
+	ruleNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrRuleNotFound)) {
+			t.Errorf("%s of a missing rule: err = %v, want rule not found", name, err)
+		}
+	}
+	_, err = repo.GetRule(ctx, missingId)
+	ruleNotFound("GetRule", err)
+	ruleNotFound("UpdateRule", repo.UpdateRule(ctx, models.Rule{Id: missingId, Target: models.RuleRequestBody}))
+	ruleNotFound("DeleteRule", repo.DeleteRule(ctx, missingId))
+
+	if err := repo.DeleteRule(ctx, agent.Id); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	_, err = repo.GetRule(ctx, agent.Id)
+	ruleNotFound("GetRule after delete", err)
+	if rules, err := repo.ListRules(ctx); err != nil || len(rules) != 1 || rules[0].Id != banner.Id {
+		t.Errorf("ListRules after delete = %+v, %v", rules, err)
+	}
+}
+
+func testScopeRules(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	project := activeProject(t, repo)
+	other := saveProject(t, repo, "other")
+
+	if rules, err := repo.ListScopeRules(ctx, project); err != nil || rules == nil || len(rules) != 0 {
+		t.Fatalf("ListScopeRules of a new project = %#v, %v, want none", rules, err)
+	}
+
+	include := models.ScopeRule{ProjectId: project, Kind: models.ScopeInclude, Scheme: "https", Host: "*.example.com", Port: 8443}
+	exclude := models.ScopeRule{ProjectId: project, Kind: models.ScopeExclude, Path: `\.png$`}
+	for _, rule := range []*models.ScopeRule{&include, &exclude} {
+		id, err := repo.SaveScopeRule(ctx, *rule)
+		if err != nil {
+			t.Fatalf("SaveScopeRule: %v", err)
+		}
+		rule.Id = id
+	}
+
+	rules, err := repo.ListScopeRules(ctx, project)
+	if err != nil {
+		t.Fatalf("ListScopeRules: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("ListScopeRules = %+v, want rules %d and %d", rules, include.Id, exclude.Id)
+	}
+	for i, want := range []models.ScopeRule{include, exclude} {
+		got := rules[i]
+		if got.CreatedAt.IsZero() || got.UpdatedAt.Before(got.CreatedAt) {
+			t.Errorf("new scope rule CreatedAt = %v, UpdatedAt = %v", got.CreatedAt, got.UpdatedAt)
+		}
+		want.CreatedAt, want.UpdatedAt = got.CreatedAt, got.UpdatedAt
+		if got != want {
+			t.Errorf("scope rule %d = %+v\nwant %+v", i, got, want)
+		}
+	}
+
+	before, err := repo.GetScopeRule(ctx, project, include.Id)
+	if err != nil {
+		t.Fatalf("GetScopeRule: %v", err)
+	}
+	edited := include
+	edited.Scheme, edited.Port, edited.Host = "", 0, "example.com"
+	if err := repo.UpdateScopeRule(ctx, edited); err != nil {
+		t.Fatalf("UpdateScopeRule: %v", err)
+	}
+	after, err := repo.GetScopeRule(ctx, project, include.Id)
+	if err != nil {
+		t.Fatalf("GetScopeRule: %v", err)
+	}
+	if after.Scheme != "" || after.Port != 0 || after.Host != "example.com" || after.Kind != models.ScopeInclude ||
+		!after.CreatedAt.Equal(before.CreatedAt) || after.UpdatedAt.Before(before.UpdatedAt) {
+		t.Errorf("updated scope rule = %+v, was %+v", *after, *before)
+	}
+
+	scopeRuleNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrScopeRuleNotFound)) {
+			t.Errorf("%s: err = %v, want scope rule not found", name, err)
+		}
+	}
+	_, err = repo.GetScopeRule(ctx, other, include.Id)
+	scopeRuleNotFound("GetScopeRule from another project", err)
+	scopeRuleNotFound("UpdateScopeRule from another project", repo.UpdateScopeRule(ctx, models.ScopeRule{Id: include.Id, ProjectId: other, Kind: models.ScopeExclude}))
+	scopeRuleNotFound("DeleteScopeRule from another project", repo.DeleteScopeRule(ctx, other, include.Id))
+	if rules, err := repo.ListScopeRules(ctx, other); err != nil || len(rules) != 0 {
+		t.Errorf("ListScopeRules of another project = %+v, %v", rules, err)
+	}
+
+	if err := repo.DeleteScopeRule(ctx, project, exclude.Id); err != nil {
+		t.Fatalf("DeleteScopeRule: %v", err)
+	}
+	_, err = repo.GetScopeRule(ctx, project, exclude.Id)
+	scopeRuleNotFound("GetScopeRule after delete", err)
+	if rules, err := repo.ListScopeRules(ctx, project); err != nil || len(rules) != 1 || rules[0].Id != include.Id {
+		t.Errorf("ListScopeRules after delete = %+v, %v", rules, err)
+	}
+
+	if _, err := repo.SaveScopeRule(ctx, models.ScopeRule{ProjectId: other, Kind: models.ScopeInclude}); err != nil {
+		t.Fatalf("SaveScopeRule: %v", err)
+	}
+	if err := repo.DeleteProject(ctx, other); err != nil {
+		t.Fatalf("DeleteProject: %v", err)
+	}
+	if rules, err := repo.ListScopeRules(ctx, other); err != nil || len(rules) != 0 {
+		t.Errorf("ListScopeRules of a deleted project = %+v, %v", rules, err)
+	}
+}
+
+func testPassthroughHosts(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	if hosts, err := repo.ListPassthroughHosts(ctx); err != nil || hosts == nil || len(hosts) != 0 {
+		t.Fatalf("ListPassthroughHosts of a new database = %#v, %v, want none", hosts, err)
+	}
+
+	bank := models.PassthroughHost{Host: "*.bank.example", Enabled: true}
+	id, err := repo.SavePassthroughHost(ctx, bank)
+	if err != nil {
+		t.Fatalf("SavePassthroughHost: %v", err)
+	}
+	bank.Id = id
+	if _, err := repo.SavePassthroughHost(ctx, bank); !errors.As(err, new(*models.ErrPassthroughConflict)) {
+		t.Errorf("SavePassthroughHost of a listed host: err = %v, want conflict", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repo.RecordHandshakeFailure(ctx, "pinned.example", "remote error: tls: unknown certificate authority"); err != nil {
+			t.Fatalf("RecordHandshakeFailure: %v", err)
+		}
+	}
+	if err := repo.RecordHandshakeFailure(ctx, bank.Host, "EOF"); err != nil {
+		t.Fatalf("RecordHandshakeFailure: %v", err)
+	}
+
+	hosts, err := repo.ListPassthroughHosts(ctx)
+	if err != nil {
+		t.Fatalf("ListPassthroughHosts: %v", err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("ListPassthroughHosts = %+v, want 2 hosts", hosts)
+	}
+	listed, learned := hosts[0], hosts[1]
+	if listed.Id != bank.Id || listed.Host != bank.Host || !listed.Enabled || listed.Learned ||
+		listed.Failures != 1 || listed.LastError != "EOF" || listed.CreatedAt.IsZero() {
+		t.Errorf("listed host after a failure = %+v", listed)
+	}
+	if learned.Host != "pinned.example" || learned.Enabled || !learned.Learned || learned.Failures != 2 ||
+		learned.LastError != "remote error: tls: unknown certificate authority" || learned.UpdatedAt.Before(learned.CreatedAt) {
+		t.Errorf("learned host = %+v", learned)
+	}
+
+	enabled := learned
+	enabled.Enabled = true
+	if err := repo.UpdatePassthroughHost(ctx, enabled); err != nil {
+		t.Fatalf("UpdatePassthroughHost: %v", err)
+	}
+	after, err := repo.GetPassthroughHost(ctx, learned.Id)
+	if err != nil {
+		t.Fatalf("GetPassthroughHost: %v", err)
+	}
+	if !after.Enabled || !after.Learned || after.Failures != 2 || !after.CreatedAt.Equal(learned.CreatedAt) ||
+		after.UpdatedAt.Before(learned.UpdatedAt) {
+		t.Errorf("enabled host = %+v, was %+v", *after, learned)
+	}
+	renamed := enabled
+	renamed.Host = bank.Host
+	if err := repo.UpdatePassthroughHost(ctx, renamed); !errors.As(err, new(*models.ErrPassthroughConflict)) {
+		t.Errorf("UpdatePassthroughHost to a listed host: err = %v, want conflict", err)
+	}
+
+	passthroughNotFound := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, new(*models.ErrPassthroughNotFound)) {
+			t.Errorf("%s of a missing host: err = %v, want passthrough host not found", name, err)
+		}
+	}
+	_, err = repo.GetPassthroughHost(ctx, missingId)
+	passthroughNotFound("GetPassthroughHost", err)
+	passthroughNotFound("UpdatePassthroughHost", repo.UpdatePassthroughHost(ctx, models.PassthroughHost{Id: missingId, Host: "missing.example"}))
+	passthroughNotFound("DeletePassthroughHost", repo.DeletePassthroughHost(ctx, missingId))
+
+	if err := repo.DeletePassthroughHost(ctx, bank.Id); err != nil {
+		t.Fatalf("DeletePassthroughHost: %v", err)
+	}
+	_, err = repo.GetPassthroughHost(ctx, bank.Id)
+	passthroughNotFound("GetPassthroughHost after delete", err)
+	if hosts, err := repo.ListPassthroughHosts(ctx); err != nil || len(hosts) != 1 || hosts[0].Id != learned.Id {
+		t.Errorf("ListPassthroughHosts after delete = %+v, %v", hosts, err)
+	}
+}
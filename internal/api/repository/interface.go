@@ -5,10 +5,64 @@ import (
 	"proxy/internal/models"
 )
 
+// Repository stores the captured history. Reads are scoped to a project:
+// looking up something that belongs to another project reports it as not
+// found.
 type Repository interface {
-	GetAllRequests(ctx context.Context) ([]models.Request, error)
-	GetRequestById(ctx context.Context, id uint64) (*models.Request, error)
+	ListRequests(ctx context.Context, filter models.RequestFilter) (*models.RequestPage, error)
+	GetRequestById(ctx context.Context, projectId, id uint64) (*models.Request, error)
+	GetResponseById(ctx context.Context, projectId, id uint64) (*models.Response, error)
+	GetResponseByRequestId(ctx context.Context, projectId, requestId uint64) (*models.Response, error)
+	GetRequestBody(ctx context.Context, projectId, id uint64) (*models.Body, error)
+	GetResponseBody(ctx context.Context, projectId, id uint64) (*models.Body, error)
+
+	Search(ctx context.Context, projectId uint64, text string, limit int) ([]models.SearchResult, error)
 
 	SaveRequest(ctx context.Context, request models.Request) (uint64, error)
 	SaveResponse(ctx context.Context, response models.Response) error
+	SaveExchanges(ctx context.Context, exchanges []models.Exchange) error
+
+	GetWebSockets(ctx context.Context, projectId uint64) ([]models.WebSocket, error)
+	GetWebSocketMessages(ctx context.Context, projectId, requestId uint64) ([]models.WebSocketMessage, error)
+	SaveWebSocketMessage(ctx context.Context, message models.WebSocketMessage) error
+
+	ListProjects(ctx context.Context, archived bool) ([]models.Project, error)
+	GetProject(ctx context.Context, id uint64) (*models.Project, error)
+	GetActiveProject(ctx context.Context) (*models.Project, error)
+	SaveProject(ctx context.Context, project models.Project) (uint64, error)
+	SetActiveProject(ctx context.Context, id uint64) error
+	SetProjectArchived(ctx context.Context, id uint64, archived bool) error
+	DeleteProject(ctx context.Context, id uint64) error
+
+	ListNotes(ctx context.Context, projectId, requestId uint64) ([]models.Note, error)
+	GetNote(ctx context.Context, projectId, id uint64) (*models.Note, error)
+	SaveNote(ctx context.Context, note models.Note) (uint64, error)
+	UpdateNote(ctx context.Context, note models.Note) error
+	DeleteNote(ctx context.Context, projectId, id uint64) error
+
+	ListScanResults(ctx context.Context, projectId, requestId uint64) ([]models.ScanResult, error)
+	SaveScanResult(ctx context.Context, result models.ScanResult) (uint64, error)
+
+	ListScopeRules(ctx context.Context, projectId uint64) ([]models.ScopeRule, error)
+	GetScopeRule(ctx context.Context, projectId, id uint64) (*models.ScopeRule, error)
+	SaveScopeRule(ctx context.Context, rule models.ScopeRule) (uint64, error)
+	UpdateScopeRule(ctx context.Context, rule models.ScopeRule) error
+	DeleteScopeRule(ctx context.Context, projectId, id uint64) error
+
+	// Rules apply to all projects.
+	ListRules(ctx context.Context) ([]models.Rule, error)
+	GetRule(ctx context.Context, id uint64) (*models.Rule, error)
+	SaveRule(ctx context.Context, rule models.Rule) (uint64, error)
+	UpdateRule(ctx context.Context, rule models.Rule) error
+	DeleteRule(ctx context.Context, id uint64) error
+
+	// Passthrough hosts apply to all projects. RecordHandshakeFailure
+	// counts a refused handshake against host, listing it as a learned
+	// candidate the first time.
+	ListPassthroughHosts(ctx context.Context) ([]models.PassthroughHost, error)
+	GetPassthroughHost(ctx context.Context, id uint64) (*models.PassthroughHost, error)
+	SavePassthroughHost(ctx context.Context, host models.PassthroughHost) (uint64, error)
+	UpdatePassthroughHost(ctx context.Context, host models.PassthroughHost) error
+	DeletePassthroughHost(ctx context.Context, id uint64) error
+	RecordHandshakeFailure(ctx context.Context, host, reason string) error
 }
@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/models"
+	"proxy/pkg/filterexpr"
+)
+
+// sortKey orders exchanges by one column. format and parse convert the
+// key of an exchange to and from its cursor form; parse fills in the key
+// of a blank exchange that stands for the cursor position.
+type sortKey struct {
+	compare func(a, b *exchange) int
+	format  func(ex *exchange) string
+	parse   func(key string, ex *exchange) error
+}
+
+var sortKeys = map[string]sortKey{
+	"created_at": {
+		compare: func(a, b *exchange) int { return a.request.CreatedAt.Compare(b.request.CreatedAt) },
+		format:  func(ex *exchange) string { return ex.request.CreatedAt.Format(time.RFC3339Nano) },
+		parse: func(key string, ex *exchange) (err error) {
+			ex.request.CreatedAt, err = time.Parse(time.RFC3339Nano, key)
+			return err
+		},
+	},
+	"host": {
+		compare: func(a, b *exchange) int { return strings.Compare(a.request.Host, b.request.Host) },
+		format:  func(ex *exchange) string { return ex.request.Host },
+		parse: func(key string, ex *exchange) error {
+			ex.request.Host = key
+			return nil
+		},
+	},
+	"status": {
+		compare: func(a, b *exchange) int { return cmp.Compare(statusOf(a), statusOf(b)) },
+		format:  func(ex *exchange) string { return strconv.Itoa(statusOf(ex)) },
+		parse: func(key string, ex *exchange) (err error) {
+			ex.response = &models.Response{}
+			ex.response.Code, err = strconv.Atoi(key)
+			return err
+		},
+	},
+	"size": {
+		compare: func(a, b *exchange) int { return cmp.Compare(sizeOf(a), sizeOf(b)) },
+		format:  func(ex *exchange) string { return strconv.FormatInt(sizeOf(ex), 10) },
+		parse: func(key string, ex *exchange) (err error) {
+			ex.response = &models.Response{}
+			ex.response.BodySize, err = strconv.ParseInt(key, 10, 64)
+			return err
+		},
+	},
+}
+
+// statusOf and sizeOf treat a missing response as 0, as the database
+// backends do.
+func statusOf(ex *exchange) int {
+	if ex.response == nil {
+		return 0
+	}
+	return ex.response.Code
+}
+
+func sizeOf(ex *exchange) int64 {
+	if ex.response == nil {
+		return 0
+	}
+	return ex.response.BodySize
+}
+
+// ListRequests returns one page of the history matching filter, ordered by
+// the requested sort key with the id as tie breaker.
+func (r *Repository) ListRequests(ctx context.Context, filter models.RequestFilter) (*models.RequestPage, error) {
+	filter, err := repository.PageFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := sortKeys[filter.Sort]
+	if !ok {
+		return nil, &models.ErrBadFilter{Reason: fmt.Sprintf("unknown sort key %q", filter.Sort)}
+	}
+
+	match, err := buildPredicate(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	order := func(a, b *exchange) int {
+		c := key.compare(a, b)
+		if c == 0 {
+			c = cmp.Compare(a.request.Id, b.request.Id)
+		}
+		if filter.Desc {
+			c = -c
+		}
+		return c
+	}
+
+	if filter.Cursor != "" {
+		c, err := repository.DecodeCursor(filter.Cursor, filter)
+		if err != nil {
+			return nil, err
+		}
+		after := &exchange{request: models.Request{Id: c.Id}}
+		if err := key.parse(c.Key, after); err != nil {
+			return nil, &models.ErrBadFilter{Reason: "malformed cursor"}
+		}
+		prev := match
+		match = func(ex *exchange) bool {
+			return order(ex, after) > 0 && prev(ex)
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []*exchange
+	for _, ex := range r.exchanges {
+		if ex != nil && ex.request.ProjectId == filter.ProjectId && match(ex) {
+			matched = append(matched, ex)
+		}
+	}
+	slices.SortFunc(matched, order)
+
+	page := &models.RequestPage{Requests: []models.Request{}}
+	for _, ex := range matched {
+		if len(page.Requests) == filter.Limit {
+			last := matched[filter.Limit-1]
+			page.NextCursor = repository.EncodeCursor(repository.Cursor{
+				Sort: filter.Sort,
+				Desc: filter.Desc,
+				Key:  key.format(last),
+				Id:   last.request.Id,
+			})
+			break
+		}
+		page.Requests = append(page.Requests, cloneRequest(ex.request))
+	}
+
+	return page, nil
+}
+
+// buildPredicate turns the conditions of filter into one test, with the
+// semantics of the SQL the database backends run: conditions on the
+// response never hold for a request that has none.
+func buildPredicate(filter models.RequestFilter) (func(ex *exchange) bool, error) {
+	var conds []func(ex *exchange) bool
+	withResponse := func(test func(resp *models.Response) bool) {
+		conds = append(conds, func(ex *exchange) bool {
+			return ex.response != nil && test(ex.response)
+		})
+	}
+
+	if len(filter.Methods) > 0 {
+		conds = append(conds, func(ex *exchange) bool {
+			return slices.ContainsFunc(filter.Methods, func(m string) bool {
+				return strings.ToUpper(m) == ex.request.Method
+			})
+		})
+	}
+
+	if filter.Host != "" {
+		host := strings.ToLower(filter.Host)
+		if strings.Contains(host, "*") {
+			re := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(host), `\*`, ".*") + "$")
+			conds = append(conds, func(ex *exchange) bool {
+				return re.MatchString(strings.ToLower(ex.request.Host))
+			})
+		} else {
+			// Hosts are stored as sent, possibly with a port.
+			conds = append(conds, func(ex *exchange) bool {
+				h := strings.ToLower(ex.request.Host)
+				return h == host || strings.HasPrefix(h, host+":")
+			})
+		}
+	}
+
+	if filter.PathPrefix != "" {
+		conds = append(conds, func(ex *exchange) bool {
+			return strings.HasPrefix(ex.request.Path, filter.PathPrefix)
+		})
+	}
+
+	if filter.StatusMin > 0 {
+		withResponse(func(resp *models.Response) bool { return resp.Code >= filter.StatusMin })
+	}
+	if filter.StatusMax > 0 {
+		withResponse(func(resp *models.Response) bool { return resp.Code <= filter.StatusMax })
+	}
+
+	if filter.ContentType != "" {
+		contentType := strings.ToLower(filter.ContentType)
+		withResponse(func(resp *models.Response) bool {
+			return strings.Contains(strings.ToLower(resp.MimeType), contentType)
+		})
+	}
+
+	if !filter.From.IsZero() {
+		conds = append(conds, func(ex *exchange) bool { return !ex.request.CreatedAt.Before(filter.From) })
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, func(ex *exchange) bool { return ex.request.CreatedAt.Before(filter.To) })
+	}
+
+	if filter.MinSize != nil {
+		conds = append(conds, func(ex *exchange) bool { return sizeOf(ex) >= *filter.MinSize })
+	}
+	if filter.MaxSize != nil {
+		conds = append(conds, func(ex *exchange) bool { return sizeOf(ex) <= *filter.MaxSize })
+	}
+
+	if filter.Expression != "" {
+		node, err := filterexpr.Parse(filter.Expression)
+		if err != nil {
+			return nil, &models.ErrBadFilter{Reason: err.Error()}
+		}
+		conds = append(conds, func(ex *exchange) bool {
+			return filterexpr.Match(node, filterexpr.Exchange{Request: &ex.request, Response: ex.response})
+		})
+	}
+
+	return func(ex *exchange) bool {
+		for _, cond := range conds {
+			if !cond(ex) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+// ListNotes returns the notes of a project, only those about requestId
+// unless it is zero.
+func (r *Repository) ListNotes(ctx context.Context, projectId, requestId uint64) ([]models.Note, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notes := []models.Note{}
+	for _, note := range r.notes {
+		if note != nil && note.ProjectId == projectId && (requestId == 0 || note.RequestId == requestId) {
+			notes = append(notes, *note)
+		}
+	}
+	return notes, nil
+}
+
+func (r *Repository) GetNote(ctx context.Context, projectId, id uint64) (*models.Note, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.note(projectId, id)
+	if stored == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrNoteNotFound{})
+	}
+	note := *stored
+	return &note, nil
+}
+
+func (r *Repository) SaveNote(ctx context.Context, note models.Note) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.project(note.ProjectId) == nil {
+		return 0, fmt.Errorf("[repo] note for unknown project %d", note.ProjectId)
+	}
+	if note.RequestId != 0 && r.exchange(note.RequestId) == nil {
+		return 0, fmt.Errorf("[repo] note for unknown request %d", note.RequestId)
+	}
+
+	note.Id = uint64(len(r.notes)) + 1
+	note.CreatedAt = time.Now()
+	note.UpdatedAt = note.CreatedAt
+	r.notes = append(r.notes, &note)
+	return note.Id, nil
+}
+
+func (r *Repository) UpdateNote(ctx context.Context, note models.Note) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.note(note.ProjectId, note.Id)
+	if stored == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrNoteNotFound{})
+	}
+	stored.Text = note.Text
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *Repository) DeleteNote(ctx context.Context, projectId, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.note(projectId, id) == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrNoteNotFound{})
+	}
+	r.notes[id-1] = nil
+	return nil
+}
+
+func (r *Repository) note(projectId, id uint64) *models.Note {
+	if id == 0 || id > uint64(len(r.notes)) {
+		return nil
+	}
+	note := r.notes[id-1]
+	if note == nil || note.ProjectId != projectId {
+		return nil
+	}
+	return note
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+func (r *Repository) ListPassthroughHosts(ctx context.Context) ([]models.PassthroughHost, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hosts := []models.PassthroughHost{}
+	for _, host := range r.passthrough {
+		if host != nil {
+			hosts = append(hosts, *host)
+		}
+	}
+	return hosts, nil
+}
+
+func (r *Repository) GetPassthroughHost(ctx context.Context, id uint64) (*models.PassthroughHost, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.passthroughHost(id)
+	if stored == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrPassthroughNotFound{})
+	}
+	host := *stored
+	return &host, nil
+}
+
+func (r *Repository) SavePassthroughHost(ctx context.Context, host models.PassthroughHost) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.passthroughByHost(host.Host) != nil {
+		return 0, fmt.Errorf("[repo] %w", &models.ErrPassthroughConflict{Host: host.Host})
+	}
+
+	host.Id = uint64(len(r.passthrough)) + 1
+	host.Learned, host.Failures, host.LastError = false, 0, ""
+	host.CreatedAt = time.Now()
+	host.UpdatedAt = host.CreatedAt
+	r.passthrough = append(r.passthrough, &host)
+	return host.Id, nil
+}
+
+func (r *Repository) UpdatePassthroughHost(ctx context.Context, host models.PassthroughHost) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.passthroughHost(host.Id)
+	if stored == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrPassthroughNotFound{})
+	}
+	if other := r.passthroughByHost(host.Host); other != nil && other != stored {
+		return fmt.Errorf("[repo] %w", &models.ErrPassthroughConflict{Host: host.Host})
+	}
+	stored.Host = host.Host
+	stored.Enabled = host.Enabled
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *Repository) DeletePassthroughHost(ctx context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.passthroughHost(id) == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrPassthroughNotFound{})
+	}
+	r.passthrough[id-1] = nil
+	return nil
+}
+
+func (r *Repository) RecordHandshakeFailure(ctx context.Context, host, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if stored := r.passthroughByHost(host); stored != nil {
+		stored.Failures++
+		stored.LastError = reason
+		stored.UpdatedAt = now
+		return nil
+	}
+	r.passthrough = append(r.passthrough, &models.PassthroughHost{
+		Id:        uint64(len(r.passthrough)) + 1,
+		Host:      host,
+		Learned:   true,
+		Failures:  1,
+		LastError: reason,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	return nil
+}
+
+func (r *Repository) passthroughHost(id uint64) *models.PassthroughHost {
+	if id == 0 || id > uint64(len(r.passthrough)) {
+		return nil
+	}
+	return r.passthrough[id-1]
+}
+
+func (r *Repository) passthroughByHost(host string) *models.PassthroughHost {
+	for _, stored := range r.passthrough {
+		if stored != nil && stored.Host == host {
+			return stored
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"proxy/internal/models"
+)
+
+func (r *Repository) ListProjects(ctx context.Context, archived bool) ([]models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	projects := []models.Project{}
+	for _, p := range r.projects {
+		if p != nil && (archived || !p.Archived) {
+			projects = append(projects, *p)
+		}
+	}
+	return projects, nil
+}
+
+func (r *Repository) GetProject(ctx context.Context, id uint64) (*models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p := r.project(id)
+	if p == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrProjectNotFound{})
+	}
+	project := *p
+	return &project, nil
+}
+
+func (r *Repository) GetActiveProject(ctx context.Context) (*models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.projects {
+		if p != nil && p.Active {
+			project := *p
+			return &project, nil
+		}
+	}
+	return nil, fmt.Errorf("[repo] %w", &models.ErrProjectNotFound{})
+}
+
+func (r *Repository) SaveProject(ctx context.Context, project models.Project) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.projects {
+		if p != nil && p.Name == project.Name {
+			return 0, fmt.Errorf("[repo] %w", &models.ErrProjectConflict{Reason: fmt.Sprintf("name %q is taken", project.Name)})
+		}
+	}
+
+	project.Id = uint64(len(r.projects)) + 1
+	project.Active = false
+	project.Archived = false
+	project.CreatedAt = time.Now()
+	r.projects = append(r.projects, &project)
+	return project.Id, nil
+}
+
+// SetActiveProject makes id the one active project.
+func (r *Repository) SetActiveProject(ctx context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	target := r.project(id)
+	if target == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrProjectNotFound{})
+	}
+	for _, p := range r.projects {
+		if p != nil {
+			p.Active = false
+		}
+	}
+	target.Active = true
+	return nil
+}
+
+func (r *Repository) SetProjectArchived(ctx context.Context, id uint64, archived bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.project(id)
+	if p == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrProjectNotFound{})
+	}
+	p.Archived = archived
+	return nil
+}
+
+// DeleteProject removes the project with everything captured in it.
+func (r *Repository) DeleteProject(ctx context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.project(id) == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrProjectNotFound{})
+	}
+	r.projects[id-1] = nil
+
+	for i, ex := range r.exchanges {
+		if ex != nil && ex.request.ProjectId == id {
+			r.exchanges[i] = nil
+		}
+	}
+	for i, resp := range r.responses {
+		if resp != nil && r.exchange(resp.RequestId) == nil {
+			r.responses[i] = nil
+		}
+	}
+	r.messages = slices.DeleteFunc(r.messages, func(msg models.WebSocketMessage) bool {
+		return r.exchange(msg.RequestId) == nil
+	})
+	for i, note := range r.notes {
+		if note != nil && note.ProjectId == id {
+			r.notes[i] = nil
+		}
+	}
+	for i, scan := range r.scans {
+		if scan != nil && scan.ProjectId == id {
+			r.scans[i] = nil
+		}
+	}
+	for i, rule := range r.scope {
+		if rule != nil && rule.ProjectId == id {
+			r.scope[i] = nil
+		}
+	}
+	return nil
+}
+
+// project returns the stored project of an id, nil if there is none. The
+// caller holds mu.
+func (r *Repository) project(id uint64) *models.Project {
+	if id == 0 || id > uint64(len(r.projects)) {
+		return nil
+	}
+	return r.projects[id-1]
+}
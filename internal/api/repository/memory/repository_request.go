@@ -0,0 +1,278 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"proxy/internal/models"
+)
+
+// exchange is a stored request with the first response recorded for it.
+type exchange struct {
+	request  models.Request
+	response *models.Response
+}
+
+// Repository keeps the history in process memory. It is meant for tests
+// and throwaway sessions: nothing survives a restart. Ids are handed out
+// in order starting at 1, like the database sequences of the other
+// backends, and stored values are copied in and out so callers can't
+// change the history behind its back. Deleted entries leave a nil behind
+// so that ids keep matching positions.
+type Repository struct {
+	mu          sync.RWMutex
+	exchanges   []*exchange // by request id - 1
+	responses   []*models.Response
+	messages    []models.WebSocketMessage
+	messageSeq  uint64
+	projects    []*models.Project // by project id - 1
+	notes       []*models.Note
+	scans       []*models.ScanResult
+	rules       []*models.Rule
+	scope       []*models.ScopeRule
+	passthrough []*models.PassthroughHost
+}
+
+// NewRepository returns an empty history with an active default project,
+// as a freshly migrated database has.
+func NewRepository() *Repository {
+	return &Repository{
+		projects: []*models.Project{{
+			Id:        1,
+			Name:      "default",
+			Active:    true,
+			CreatedAt: time.Now(),
+		}},
+	}
+}
+
+func (r *Repository) GetRequestById(ctx context.Context, projectId, id uint64) (*models.Request, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ex := r.exchangeIn(projectId, id)
+	if ex == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrRequestNotFuound{})
+	}
+	req := cloneRequest(ex.request)
+	return &req, nil
+}
+
+func (r *Repository) GetResponseById(ctx context.Context, projectId, id uint64) (*models.Response, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.response(projectId, id)
+	if stored == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrResponseNotFound{})
+	}
+	resp := cloneResponse(*stored)
+	return &resp, nil
+}
+
+// GetResponseByRequestId returns the response recorded for a request.
+func (r *Repository) GetResponseByRequestId(ctx context.Context, projectId, requestId uint64) (*models.Response, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ex := r.exchangeIn(projectId, requestId)
+	if ex == nil || ex.response == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrResponseNotFound{})
+	}
+	resp := cloneResponse(*ex.response)
+	return &resp, nil
+}
+
+func (r *Repository) GetRequestBody(ctx context.Context, projectId, id uint64) (*models.Body, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ex := r.exchangeIn(projectId, id)
+	if ex == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrRequestNotFuound{})
+	}
+	return &models.Body{
+		Content:   slices.Clone(ex.request.Body),
+		MimeType:  ex.request.MimeType,
+		Size:      ex.request.BodySize,
+		Truncated: ex.request.Truncated,
+		Headers:   cloneMultiMap(ex.request.Headers),
+	}, nil
+}
+
+func (r *Repository) GetResponseBody(ctx context.Context, projectId, id uint64) (*models.Body, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resp := r.response(projectId, id)
+	if resp == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrResponseNotFound{})
+	}
+	return &models.Body{
+		Content:   slices.Clone(resp.Body),
+		MimeType:  resp.MimeType,
+		Size:      resp.BodySize,
+		Truncated: resp.Truncated,
+		Headers:   cloneMultiMap(resp.Headers),
+	}, nil
+}
+
+func (r *Repository) SaveRequest(ctx context.Context, request models.Request) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	request.CreatedAt = time.Now()
+	return r.addRequest(request)
+}
+
+func (r *Repository) SaveResponse(ctx context.Context, response models.Response) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	response.CreatedAt = time.Now()
+	return r.addResponse(response)
+}
+
+// SaveExchanges stores a batch of exchanges at once. Exchanges without a
+// response only add a request.
+func (r *Repository) SaveExchanges(ctx context.Context, exchanges []models.Exchange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, ex := range exchanges {
+		request := *ex.Request
+		request.Proto = defaultProto(request.Proto)
+		request.CreatedAt = capturedAt(request.CreatedAt, now)
+		id, err := r.addRequest(request)
+		if err != nil {
+			return err
+		}
+
+		if ex.Response != nil {
+			response := *ex.Response
+			response.RequestId = id
+			response.Proto = defaultProto(response.Proto)
+			response.CreatedAt = capturedAt(response.CreatedAt, now)
+			if err := r.addResponse(response); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exchange returns the stored exchange of a request id, nil if there is
+// none. The caller holds mu.
+func (r *Repository) exchange(id uint64) *exchange {
+	if id == 0 || id > uint64(len(r.exchanges)) {
+		return nil
+	}
+	return r.exchanges[id-1]
+}
+
+// exchangeIn is exchange limited to one project.
+func (r *Repository) exchangeIn(projectId, id uint64) *exchange {
+	ex := r.exchange(id)
+	if ex == nil || ex.request.ProjectId != projectId {
+		return nil
+	}
+	return ex
+}
+
+// response returns a stored response of the project, nil if there is none.
+func (r *Repository) response(projectId, id uint64) *models.Response {
+	if id == 0 || id > uint64(len(r.responses)) {
+		return nil
+	}
+	resp := r.responses[id-1]
+	if resp == nil || r.exchangeIn(projectId, resp.RequestId) == nil {
+		return nil
+	}
+	return resp
+}
+
+func (r *Repository) addRequest(request models.Request) (uint64, error) {
+	if r.project(request.ProjectId) == nil {
+		return 0, fmt.Errorf("[repo] request for unknown project %d", request.ProjectId)
+	}
+
+	request = cloneRequest(request)
+	request.Id = uint64(len(r.exchanges)) + 1
+	request.Scheme = defaultScheme(request.Scheme)
+	if request.FiredRules == nil {
+		request.FiredRules = []uint64{}
+	}
+	r.exchanges = append(r.exchanges, &exchange{request: request})
+	return request.Id, nil
+}
+
+func (r *Repository) addResponse(response models.Response) error {
+	ex := r.exchange(response.RequestId)
+	if ex == nil {
+		return fmt.Errorf("[repo] response for unknown request %d", response.RequestId)
+	}
+
+	stored := cloneResponse(response)
+	stored.Id = uint64(len(r.responses)) + 1
+	r.responses = append(r.responses, &stored)
+	if ex.response == nil {
+		ex.response = &stored
+	}
+	return nil
+}
+
+func cloneRequest(req models.Request) models.Request {
+	req.Get_Params = cloneMultiMap(req.Get_Params)
+	req.Headers = cloneMultiMap(req.Headers)
+	req.Cookies = maps.Clone(req.Cookies)
+	req.Post_Params = cloneMultiMap(req.Post_Params)
+	req.Body = slices.Clone(req.Body)
+	req.Encodings = slices.Clone(req.Encodings)
+	req.FiredRules = slices.Clone(req.FiredRules)
+	return req
+}
+
+func cloneResponse(resp models.Response) models.Response {
+	resp.Headers = cloneMultiMap(resp.Headers)
+	resp.Body = slices.Clone(resp.Body)
+	resp.Encodings = slices.Clone(resp.Encodings)
+	return resp
+}
+
+func cloneMultiMap(m map[string][]string) map[string][]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(m))
+	for k, v := range m {
+		out[k] = slices.Clone(v)
+	}
+	return out
+}
+
+// defaultProto mirrors the column default of the database backends.
+func defaultProto(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func defaultScheme(scheme string) string {
+	if scheme == "" {
+		return "http"
+	}
+	return scheme
+}
+
+func capturedAt(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t
+}
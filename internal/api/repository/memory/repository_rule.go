@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+func (r *Repository) ListRules(ctx context.Context) ([]models.Rule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := []models.Rule{}
+	for _, rule := range r.rules {
+		if rule != nil {
+			rules = append(rules, *rule)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Repository) GetRule(ctx context.Context, id uint64) (*models.Rule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.rule(id)
+	if stored == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrRuleNotFound{})
+	}
+	rule := *stored
+	return &rule, nil
+}
+
+func (r *Repository) SaveRule(ctx context.Context, rule models.Rule) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule.Id = uint64(len(r.rules)) + 1
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = rule.CreatedAt
+	r.rules = append(r.rules, &rule)
+	return rule.Id, nil
+}
+
+func (r *Repository) UpdateRule(ctx context.Context, rule models.Rule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.rule(rule.Id)
+	if stored == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrRuleNotFound{})
+	}
+	rule.CreatedAt = stored.CreatedAt
+	rule.UpdatedAt = time.Now()
+	*stored = rule
+	return nil
+}
+
+func (r *Repository) DeleteRule(ctx context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rule(id) == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrRuleNotFound{})
+	}
+	r.rules[id-1] = nil
+	return nil
+}
+
+func (r *Repository) rule(id uint64) *models.Rule {
+	if id == 0 || id > uint64(len(r.rules)) {
+		return nil
+	}
+	return r.rules[id-1]
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"proxy/internal/models"
+)
+
+// ListScanResults returns the scans of a project, only those of requestId
+// unless it is zero.
+func (r *Repository) ListScanResults(ctx context.Context, projectId, requestId uint64) ([]models.ScanResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := []models.ScanResult{}
+	for _, scan := range r.scans {
+		if scan != nil && scan.ProjectId == projectId && (requestId == 0 || scan.RequestId == requestId) {
+			result := *scan
+			result.HiddenParams = slices.Clone(scan.HiddenParams)
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func (r *Repository) SaveScanResult(ctx context.Context, result models.ScanResult) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.project(result.ProjectId) == nil {
+		return 0, fmt.Errorf("[repo] scan result for unknown project %d", result.ProjectId)
+	}
+	if r.exchange(result.RequestId) == nil {
+		return 0, fmt.Errorf("[repo] scan result for unknown request %d", result.RequestId)
+	}
+
+	result.Id = uint64(len(r.scans)) + 1
+	result.HiddenParams = slices.Clone(result.HiddenParams)
+	if result.HiddenParams == nil {
+		result.HiddenParams = []string{}
+	}
+	result.CreatedAt = time.Now()
+	r.scans = append(r.scans, &result)
+	return result.Id, nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+func (r *Repository) ListScopeRules(ctx context.Context, projectId uint64) ([]models.ScopeRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := []models.ScopeRule{}
+	for _, rule := range r.scope {
+		if rule != nil && rule.ProjectId == projectId {
+			rules = append(rules, *rule)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Repository) GetScopeRule(ctx context.Context, projectId, id uint64) (*models.ScopeRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.scopeRule(projectId, id)
+	if stored == nil {
+		return nil, fmt.Errorf("[repo] %w", &models.ErrScopeRuleNotFound{})
+	}
+	rule := *stored
+	return &rule, nil
+}
+
+func (r *Repository) SaveScopeRule(ctx context.Context, rule models.ScopeRule) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.project(rule.ProjectId) == nil {
+		return 0, fmt.Errorf("[repo] scope rule for unknown project %d", rule.ProjectId)
+	}
+
+	rule.Id = uint64(len(r.scope)) + 1
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = rule.CreatedAt
+	r.scope = append(r.scope, &rule)
+	return rule.Id, nil
+}
+
+func (r *Repository) UpdateScopeRule(ctx context.Context, rule models.ScopeRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.scopeRule(rule.ProjectId, rule.Id)
+	if stored == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrScopeRuleNotFound{})
+	}
+	rule.CreatedAt = stored.CreatedAt
+	rule.UpdatedAt = time.Now()
+	*stored = rule
+	return nil
+}
+
+func (r *Repository) DeleteScopeRule(ctx context.Context, projectId, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.scopeRule(projectId, id) == nil {
+		return fmt.Errorf("[repo] %w", &models.ErrScopeRuleNotFound{})
+	}
+	r.scope[id-1] = nil
+	return nil
+}
+
+func (r *Repository) scopeRule(projectId, id uint64) *models.ScopeRule {
+	if id == 0 || id > uint64(len(r.scope)) {
+		return nil
+	}
+	rule := r.scope[id-1]
+	if rule == nil || rule.ProjectId != projectId {
+		return nil
+	}
+	return rule
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"unicode"
+
+	"proxy/internal/models"
+)
+
+// snippetContext is how many characters around a match Search returns.
+const snippetContext = 60
+
+// Search runs a case-insensitive substring match over the same fields as
+// the database backends, one result per matching field.
+func (r *Repository) Search(ctx context.Context, projectId uint64, text string, limit int) ([]models.SearchResult, error) {
+	needle := lowerRunes(text)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := []models.SearchResult{}
+	add := func(ex *exchange, field, content string) {
+		if snippet, ok := findSnippet(content, needle); ok {
+			results = append(results, models.SearchResult{
+				RequestId: ex.request.Id,
+				Field:     field,
+				Snippet:   snippet,
+				CreatedAt: ex.request.CreatedAt,
+			})
+		}
+	}
+
+	for _, ex := range r.exchanges {
+		if ex == nil || ex.request.ProjectId != projectId {
+			continue
+		}
+		add(ex, "req.path", ex.request.Path)
+		add(ex, "req.query", jsonText(ex.request.Get_Params))
+		add(ex, "req.header", jsonText(ex.request.Headers))
+		add(ex, "req.body", ex.request.DecodedBody)
+		if ex.response != nil {
+			add(ex, "resp.header", jsonText(ex.response.Headers))
+			add(ex, "resp.body", ex.response.DecodedBody)
+		}
+	}
+
+	slices.SortFunc(results, func(a, b models.SearchResult) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.RequestId, b.RequestId); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Field, b.Field)
+	})
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// findSnippet looks for needle, already lower case, in content and returns
+// the window of snippetContext characters around its first match.
+func findSnippet(content string, needle []rune) (string, bool) {
+	haystack := []rune(content)
+	lowered := lowerRunes(content)
+
+	for i := 0; i+len(needle) <= len(lowered); i++ {
+		if slices.Equal(lowered[i:i+len(needle)], needle) {
+			start := max(i-snippetContext, 0)
+			end := min(i+len(needle)+snippetContext, len(haystack))
+			return string(haystack[start:end]), true
+		}
+	}
+	return "", false
+}
+
+// lowerRunes lower cases s rune by rune, so positions line up with []rune(s).
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, c := range runes {
+		runes[i] = unicode.ToLower(c)
+	}
+	return runes
+}
+
+func jsonText(v any) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
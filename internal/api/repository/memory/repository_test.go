@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/api/repository/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) repository.Repository {
+		return NewRepository()
+	})
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"proxy/internal/models"
+)
+
+func (r *Repository) GetWebSockets(ctx context.Context, projectId uint64) ([]models.WebSocket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[uint64]int)
+	for _, msg := range r.messages {
+		if r.exchangeIn(projectId, msg.RequestId) != nil {
+			counts[msg.RequestId]++
+		}
+	}
+
+	var sockets []models.WebSocket
+	for id, count := range counts {
+		req := &r.exchange(id).request
+		sockets = append(sockets, models.WebSocket{
+			RequestId:    id,
+			Host:         req.Host,
+			Path:         req.Path,
+			MessageCount: count,
+			CreatedAt:    req.CreatedAt,
+		})
+	}
+
+	if len(sockets) == 0 {
+		return nil, fmt.Errorf("[repo] Error no websockets found: %w", &models.ErrRequestNotFuound{})
+	}
+
+	slices.SortFunc(sockets, func(a, b models.WebSocket) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.RequestId, b.RequestId)
+	})
+	return sockets, nil
+}
+
+func (r *Repository) GetWebSocketMessages(ctx context.Context, projectId, requestId uint64) ([]models.WebSocketMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []models.WebSocketMessage{}
+	if r.exchangeIn(projectId, requestId) == nil {
+		return messages, nil
+	}
+	for _, msg := range r.messages {
+		if msg.RequestId == requestId {
+			msg.Payload = slices.Clone(msg.Payload)
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (r *Repository) SaveWebSocketMessage(ctx context.Context, message models.WebSocketMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.exchange(message.RequestId) == nil {
+		return fmt.Errorf("[repo] websocket message for unknown request %d", message.RequestId)
+	}
+
+	r.messageSeq++
+	message.Id = r.messageSeq
+	message.Payload = slices.Clone(message.Payload)
+	message.Text = ""
+	r.messages = append(r.messages, message)
+	return nil
+}
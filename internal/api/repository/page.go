@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"proxy/internal/models"
+)
+
+// Page sizes of ListRequests, the same for every backend.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// PageFilter fills in the default sort order and page size of filter and
+// checks its limit.
+func PageFilter(filter models.RequestFilter) (models.RequestFilter, error) {
+	if filter.Sort == "" {
+		filter.Sort = "created_at"
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > MaxPageSize {
+		return filter, &models.ErrBadFilter{Reason: fmt.Sprintf("limit must be between 1 and %d", MaxPageSize)}
+	}
+	return filter, nil
+}
+
+// Cursor marks the last row of a history page. It carries the sort order
+// it was made for so it can't be replayed against another one. Key is the
+// sort key of that row in a form only the backend that made it reads.
+type Cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  string `json:"k"`
+	Id   uint64 `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a cursor made for filter's sort order.
+func DecodeCursor(s string, filter models.RequestFilter) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, &models.ErrBadFilter{Reason: "malformed cursor"}
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, &models.ErrBadFilter{Reason: "malformed cursor"}
+	}
+	if c.Sort != filter.Sort || c.Desc != filter.Desc {
+		return nil, &models.ErrBadFilter{Reason: "cursor belongs to a different sort order"}
+	}
+	return &c, nil
+}
@@ -0,0 +1,112 @@
+package requests
+
+import (
+	"fmt"
+
+	"proxy/pkg/filterexpr"
+)
+
+// filterColumns maps filter language fields to SQL. Keyed fields name the
+// JSONB column holding the map.
+var filterColumns = map[string]string{
+	"id":          "req.id",
+	"created_at":  "req.created_at",
+	"req.method":  "req.method",
+	"req.host":    "req.host",
+	"req.path":    "req.path",
+	"req.proto":   "req.proto",
+	"req.body":    "req.decoded_body",
+	"req.mime":    "req.mime_type",
+	"req.size":    "req.body_size",
+	"req.header":  "req.headers",
+	"req.query":   "req.query_params",
+	"req.form":    "req.post_params",
+	"req.cookie":  "req.cookies",
+	"resp.status": "resp.status_code",
+	"resp.proto":  "resp.proto",
+	"resp.body":   "resp.decoded_body",
+	"resp.mime":   "resp.mime_type",
+	"resp.size":   "resp.body_size",
+	"resp.header": "resp.headers",
+}
+
+var sqlOperators = map[string]string{
+	filterexpr.OpEq:    "=",
+	filterexpr.OpMatch: "~",
+	filterexpr.OpLt:    "<",
+	filterexpr.OpLe:    "<=",
+	filterexpr.OpGt:    ">",
+	filterexpr.OpGe:    ">=",
+}
+
+// compileFilter translates a parsed filter expression into a condition on
+// the request/response join. Comparisons are made two-valued so that a
+// missing response or header never turns a whole expression into NULL.
+func compileFilter(b *queryBuilder, node filterexpr.Node) string {
+	switch n := node.(type) {
+	case *filterexpr.And:
+		return "(" + compileFilter(b, n.Left) + " AND " + compileFilter(b, n.Right) + ")"
+	case *filterexpr.Or:
+		return "(" + compileFilter(b, n.Left) + " OR " + compileFilter(b, n.Right) + ")"
+	case *filterexpr.Not:
+		return "NOT " + compileFilter(b, n.X)
+	case *filterexpr.Exists:
+		return compileExists(b, n.Field)
+	case *filterexpr.Compare:
+		op, negated := filterexpr.Negated(n.Op)
+		cond := compileCompare(b, n.Field, op, n.Value)
+		if negated {
+			return "NOT " + cond
+		}
+		return cond
+	}
+	panic(fmt.Sprintf("unexpected filter node %T", node))
+}
+
+func compileExists(b *queryBuilder, field filterexpr.Field) string {
+	column := filterColumns[field.Name]
+	info := field.Info()
+
+	switch {
+	case info.Keyed:
+		return fmt.Sprintf("COALESCE(%s ? %s, false)", column, b.arg(field.Key))
+	case info.Type == filterexpr.StringValue:
+		return fmt.Sprintf("COALESCE(%s <> '', false)", column)
+	}
+	return fmt.Sprintf("(%s IS NOT NULL)", column)
+}
+
+func compileCompare(b *queryBuilder, field filterexpr.Field, op string, value filterexpr.Value) string {
+	column := filterColumns[field.Name]
+	info := field.Info()
+
+	var lit any
+	switch value.Kind {
+	case filterexpr.NumberValue:
+		lit = value.Num
+	case filterexpr.TimeValue:
+		lit = value.Time
+	default:
+		lit = value.Str
+	}
+	if op == filterexpr.OpMatch {
+		lit = postgresRegexp(value.Str)
+	}
+
+	test := func(expr string) string {
+		if op == filterexpr.OpContains {
+			return fmt.Sprintf("strpos(%s, %s) > 0", expr, b.arg(lit))
+		}
+		return fmt.Sprintf("%s %s %s", expr, sqlOperators[op], b.arg(lit))
+	}
+
+	switch {
+	case info.Keyed && info.Multi:
+		key := b.arg(field.Key)
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM jsonb_array_elements_text(%s -> %s) AS v(x) WHERE %s)", column, key, test("v.x"))
+	case info.Keyed:
+		key := b.arg(field.Key)
+		return fmt.Sprintf("COALESCE(%s, false)", test(fmt.Sprintf("(%s ->> %s)", column, key)))
+	}
+	return fmt.Sprintf("COALESCE(%s, false)", test(column))
+}
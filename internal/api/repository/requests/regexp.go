@@ -0,0 +1,162 @@
+package requests
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// Filters are written in Go's regular expression syntax, which the other
+// backends run as is. Postgres speaks its own dialect, where among others
+// . matches a newline and \b is a backspace, so patterns are rebuilt from
+// their parse tree as advanced regular expressions with the same meaning.
+
+const (
+	// areNever is a pattern no text matches.
+	areNever = `(?=a)b`
+
+	areWord = `[0-9A-Za-z_]`
+)
+
+// postgresRegexp translates pattern, which the filter parser has already
+// checked, for the ~ operator.
+func postgresRegexp(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return pattern
+	}
+	var b strings.Builder
+	// Simplify spells counted repeats out, Postgres stops at 255.
+	writeARE(&b, re.Simplify())
+	return b.String()
+}
+
+func writeARE(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		b.WriteString(areNever)
+	case syntax.OpEmptyMatch:
+		b.WriteString(`(?:)`)
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 {
+				writeClass(b, foldOrbit(r))
+			} else {
+				writeLiteral(b, r)
+			}
+		}
+	case syntax.OpCharClass:
+		writeClass(b, re.Rune)
+	case syntax.OpAnyCharNotNL:
+		b.WriteString(`[^\n]`)
+	case syntax.OpAnyChar:
+		// Without the newline options . matches anything in Postgres.
+		b.WriteString(`.`)
+	case syntax.OpBeginLine:
+		b.WriteString(`(?:^|(?<=\n))`)
+	case syntax.OpEndLine:
+		b.WriteString(`(?:$|(?=\n))`)
+	case syntax.OpBeginText:
+		b.WriteString(`^`)
+	case syntax.OpEndText:
+		b.WriteString(`$`)
+	case syntax.OpWordBoundary:
+		// Go's \b knows ASCII word characters only, Postgres' \y more.
+		b.WriteString(`(?:(?<=` + areWord + `)(?!` + areWord + `)|(?<!` + areWord + `)(?=` + areWord + `))`)
+	case syntax.OpNoWordBoundary:
+		b.WriteString(`(?:(?<=` + areWord + `)(?=` + areWord + `)|(?<!` + areWord + `)(?!` + areWord + `))`)
+	case syntax.OpCapture:
+		writeGroup(b, re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		writeGroup(b, re.Sub[0])
+		b.WriteString(map[syntax.Op]string{syntax.OpStar: "*", syntax.OpPlus: "+", syntax.OpQuest: "?"}[re.Op])
+		if re.Flags&syntax.NonGreedy != 0 {
+			b.WriteByte('?')
+		}
+	case syntax.OpRepeat:
+		writeGroup(b, re.Sub[0])
+		if re.Max < 0 {
+			fmt.Fprintf(b, "{%d,}", re.Min)
+		} else {
+			fmt.Fprintf(b, "{%d,%d}", re.Min, re.Max)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writeARE(b, sub)
+		}
+	case syntax.OpAlternate:
+		b.WriteString("(?:")
+		for i, sub := range re.Sub {
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			writeARE(b, sub)
+		}
+		b.WriteByte(')')
+	}
+}
+
+func writeGroup(b *strings.Builder, re *syntax.Regexp) {
+	b.WriteString("(?:")
+	writeARE(b, re)
+	b.WriteByte(')')
+}
+
+// writeLiteral writes r as an escape unless it is a letter or digit, so
+// that nothing in the pattern is read as an operator.
+func writeLiteral(b *strings.Builder, r rune) {
+	switch {
+	case r == 0:
+		// Text in Postgres never holds a NUL.
+		b.WriteString(areNever)
+	case r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		b.WriteRune(r)
+	default:
+		writeEscape(b, r)
+	}
+}
+
+func writeEscape(b *strings.Builder, r rune) {
+	if r <= 0xffff {
+		fmt.Fprintf(b, `\u%04x`, r)
+	} else {
+		fmt.Fprintf(b, `\U%08x`, r)
+	}
+}
+
+// writeClass writes the rune ranges lo, hi, lo, hi... as a bracket
+// expression.
+func writeClass(b *strings.Builder, ranges []rune) {
+	var body strings.Builder
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo == 0 {
+			lo = 1
+		}
+		if lo > hi {
+			continue
+		}
+		writeEscape(&body, lo)
+		if hi != lo {
+			body.WriteByte('-')
+			writeEscape(&body, hi)
+		}
+	}
+	if body.Len() == 0 {
+		b.WriteString(areNever)
+		return
+	}
+	b.WriteByte('[')
+	b.WriteString(body.String())
+	b.WriteByte(']')
+}
+
+// foldOrbit returns the runes that match r case-insensitively, as ranges.
+func foldOrbit(r rune) []rune {
+	ranges := []rune{r, r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		ranges = append(ranges, f, f)
+	}
+	return ranges
+}
@@ -0,0 +1,25 @@
+package requests
+
+import "testing"
+
+func TestPostgresRegexp(t *testing.T) {
+	tests := []struct {
+		pattern, want string
+	}{
+		{`api`, `api`},
+		{`a.c`, `a[^\n]c`},
+		{`(?s)a.c`, `a.c`},
+		{`^/api/v\d+$`, `^\u002fapi\u002fv(?:[\u0030-\u0039])+$`},
+		{`(?i)k`, `[\u004b\u006b\u212a]`},
+		{`(?m)^b$`, `(?:^|(?<=\n))b(?:$|(?=\n))`},
+		{`\bx`, `(?:(?<=[0-9A-Za-z_])(?![0-9A-Za-z_])|(?<![0-9A-Za-z_])(?=[0-9A-Za-z_]))x`},
+		{`x{2,3}`, `xx(?:x)?`},
+		{`a|b*?`, `(?:a|(?:b)*?)`},
+		{`[^\x00-\x{10FFFF}]`, `(?=a)b`},
+	}
+	for _, tt := range tests {
+		if got := postgresRegexp(tt.pattern); got != tt.want {
+			t.Errorf("postgresRegexp(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const ReserveRequestIds = `SELECT nextval(pg_get_serial_sequence('request', 'id')) FROM generate_series(1, $1)`
+
+var (
+	requestCopyColumns = []string{
+		"id", "project_id", "method", "host", "path", "headers", "query_params", "post_params", "cookies",
+		"body", "decoded_body", "encodings", "mime_type", "body_size", "body_truncated", "proto", "created_at", "fired_rules", "scheme",
+	}
+	responseCopyColumns = []string{
+		"request_id", "status_code", "headers", "body", "decoded_body", "encodings", "mime_type",
+		"body_size", "body_truncated", "proto", "wait_ms", "duration_ms", "created_at",
+	}
+)
+
+// SaveExchanges stores a batch of exchanges in one transaction. Request
+// ids are reserved from the sequence up front so that both tables can be
+// filled with COPY. Exchanges without a response only add a request row.
+//
+// One row breaking a constraint aborts a whole COPY, so a failed batch is
+// inserted again row by row and only the exchanges that fail are dropped,
+// which is reported with a *models.ErrPartialSave.
+func (r *Repository) SaveExchanges(ctx context.Context, exchanges []models.Exchange) error {
+	if len(exchanges) == 0 {
+		return nil
+	}
+
+	ids, err := r.reserveRequestIds(ctx, len(exchanges))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rows := make([]exchangeRows, 0, len(exchanges))
+	for i, ex := range exchanges {
+		var row exchangeRows
+		if row.request, err = requestCopyRow(ids[i], ex.Request, now); err != nil {
+			return err
+		}
+		if ex.Response != nil {
+			if row.response, err = responseCopyRow(ids[i], ex.Response, now); err != nil {
+				return err
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	err = r.copyExchanges(ctx, rows)
+	if err == nil {
+		return nil
+	}
+	r.log.Warnf("[repo] batch of %d exchanges failed, inserting them one by one: %v", len(rows), err)
+	return r.insertExchanges(ctx, rows)
+}
+
+// exchangeRows holds the column values of one exchange, response nil if
+// it has none.
+type exchangeRows struct {
+	request  []any
+	response []any
+}
+
+func (r *Repository) copyExchanges(ctx context.Context, rows []exchangeRows) error {
+	requestRows := make([][]any, 0, len(rows))
+	responseRows := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		requestRows = append(requestRows, row.request)
+		if row.response != nil {
+			responseRows = append(responseRows, row.response)
+		}
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to begin batch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"request"}, requestCopyColumns, pgx.CopyFromRows(requestRows)); err != nil {
+		return fmt.Errorf("[repo] failed to copy requests: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"response"}, responseCopyColumns, pgx.CopyFromRows(responseRows)); err != nil {
+		return fmt.Errorf("[repo] failed to copy responses: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("[repo] failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// insertExchanges stores each exchange under its own savepoint so that a
+// bad row only loses its exchange. It fails only if nothing was stored.
+func (r *Repository) insertExchanges(ctx context.Context, rows []exchangeRows) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to begin batch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var lastErr error
+	dropped := 0
+	for _, row := range rows {
+		if err := insertExchange(ctx, tx, row); err != nil {
+			r.log.Warnf("[repo] dropped exchange %v: %v", row.request[0], err)
+			lastErr = err
+			dropped++
+		}
+	}
+	if dropped == len(rows) {
+		return fmt.Errorf("[repo] failed to insert batch: %w", lastErr)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("[repo] failed to commit batch: %w", err)
+	}
+	if dropped > 0 {
+		return &models.ErrPartialSave{Dropped: dropped, Total: len(rows), Err: lastErr}
+	}
+	return nil
+}
+
+func insertExchange(ctx context.Context, tx pgx.Tx, row exchangeRows) error {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer savepoint.Rollback(ctx)
+
+	if _, err := savepoint.Exec(ctx, insertRequestRow, row.request...); err != nil {
+		return err
+	}
+	if row.response != nil {
+		if _, err := savepoint.Exec(ctx, insertResponseRow, row.response...); err != nil {
+			return err
+		}
+	}
+	return savepoint.Commit(ctx)
+}
+
+var (
+	insertRequestRow  = insertInto("request", requestCopyColumns)
+	insertResponseRow = insertInto("response", responseCopyColumns)
+)
+
+func insertInto(table string, columns []string) string {
+	params := make([]string, len(columns))
+	for i := range columns {
+		params[i] = "$" + strconv.Itoa(i+1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(params, ", "))
+}
+
+func (r *Repository) reserveRequestIds(ctx context.Context, n int) ([]int64, error) {
+	rows, err := r.db.Query(ctx, ReserveRequestIds, n)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to reserve request ids: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] failed to reserve request ids: %w", err)
+	}
+	return ids, nil
+}
+
+func requestCopyRow(id int64, request *models.Request, now time.Time) ([]any, error) {
+	rawHeaders, err := json.Marshal(&request.Headers)
+	if err != nil {
+		return nil, err
+	}
+	rawQuery, err := json.Marshal(&request.Get_Params)
+	if err != nil {
+		return nil, err
+	}
+	rawPostParams, err := json.Marshal(&request.Post_Params)
+	if err != nil {
+		return nil, err
+	}
+	rawCookies, err := json.Marshal(&request.Cookies)
+	if err != nil {
+		return nil, err
+	}
+	rawEncodings, err := json.Marshal(&request.Encodings)
+	if err != nil {
+		return nil, err
+	}
+	rawFiredRules, err := json.Marshal(firedRules(*request))
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		id,
+		request.ProjectId,
+		request.Method,
+		request.Host,
+		request.Path,
+		rawHeaders,
+		rawQuery,
+		rawPostParams,
+		rawCookies,
+		request.Body,
+		request.DecodedBody,
+		rawEncodings,
+		request.MimeType,
+		request.BodySize,
+		request.Truncated,
+		defaultProto(request.Proto),
+		capturedAt(request.CreatedAt, now),
+		rawFiredRules,
+		defaultScheme(request.Scheme),
+	}, nil
+}
+
+func responseCopyRow(requestId int64, response *models.Response, now time.Time) ([]any, error) {
+	rawHeaders, err := json.Marshal(&response.Headers)
+	if err != nil {
+		return nil, err
+	}
+	rawEncodings, err := json.Marshal(&response.Encodings)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		requestId,
+		response.Code,
+		rawHeaders,
+		response.Body,
+		response.DecodedBody,
+		rawEncodings,
+		response.MimeType,
+		response.BodySize,
+		response.Truncated,
+		defaultProto(response.Proto),
+		response.WaitMs,
+		response.DurationMs,
+		capturedAt(response.CreatedAt, now),
+	}, nil
+}
+
+// defaultProto mirrors the column default, which COPY does not apply to
+// explicitly listed columns.
+func defaultProto(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func defaultScheme(scheme string) string {
+	if scheme == "" {
+		return "http"
+	}
+	return scheme
+}
+
+func capturedAt(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t
+}
@@ -0,0 +1,50 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	RequestBody  = `SELECT body, mime_type, body_size, body_truncated, headers FROM request WHERE id=$1 AND project_id=$2`
+	ResponseBody = `SELECT resp.body, resp.mime_type, resp.body_size, resp.body_truncated, resp.headers
+		FROM response resp JOIN request req ON req.id = resp.request_id WHERE resp.id=$1 AND req.project_id=$2`
+)
+
+func (r *Repository) GetRequestBody(ctx context.Context, projectId, id uint64) (*models.Body, error) {
+	return r.getBody(ctx, RequestBody, projectId, id, &models.ErrRequestNotFuound{})
+}
+
+func (r *Repository) GetResponseBody(ctx context.Context, projectId, id uint64) (*models.Body, error) {
+	return r.getBody(ctx, ResponseBody, projectId, id, &models.ErrResponseNotFound{})
+}
+
+func (r *Repository) getBody(ctx context.Context, query string, projectId, id uint64, notFound error) (*models.Body, error) {
+	var body models.Body
+	var rawHeaders json.RawMessage
+
+	err := r.db.QueryRow(ctx, query, id, projectId).Scan(
+		&body.Content,
+		&body.MimeType,
+		&body.Size,
+		&body.Truncated,
+		&rawHeaders,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", notFound, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query body: %w", err)
+	}
+
+	if err := unmarshalColumn(rawHeaders, &body.Headers); err != nil {
+		return nil, err
+	}
+
+	return &body, nil
+}
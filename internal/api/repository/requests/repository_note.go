@@ -0,0 +1,96 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	noteColumns = `id, project_id, COALESCE(request_id, 0), "text", created_at, updated_at`
+
+	// A zero request id lists every note of the project.
+	NotesAll   = `SELECT ` + noteColumns + ` FROM note WHERE project_id=$1 AND ($2 = 0 OR request_id=$2) ORDER BY id`
+	NoteById   = `SELECT ` + noteColumns + ` FROM note WHERE id=$1 AND project_id=$2`
+	AddNote    = `INSERT INTO note (project_id, request_id, "text") VALUES ($1, NULLIF($2, 0), $3) RETURNING id`
+	UpdateNote = `UPDATE note SET "text"=$3, updated_at=CURRENT_TIMESTAMP WHERE id=$1 AND project_id=$2`
+	DeleteNote = `DELETE FROM note WHERE id=$1 AND project_id=$2`
+)
+
+func (r *Repository) ListNotes(ctx context.Context, projectId, requestId uint64) ([]models.Note, error) {
+	rows, err := r.db.Query(ctx, NotesAll, projectId, int64(requestId))
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return notes, nil
+}
+
+func (r *Repository) GetNote(ctx context.Context, projectId, id uint64) (*models.Note, error) {
+	note, err := scanNote(r.db.QueryRow(ctx, NoteById, id, projectId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrNoteNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed note db %w", err)
+	}
+	return note, nil
+}
+
+func scanNote(row pgx.Row) (*models.Note, error) {
+	var note models.Note
+	if err := row.Scan(
+		&note.Id,
+		&note.ProjectId,
+		&note.RequestId,
+		&note.Text,
+		&note.CreatedAt,
+		&note.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (r *Repository) SaveNote(ctx context.Context, note models.Note) (uint64, error) {
+	var id uint64
+	if err := r.db.QueryRow(ctx, AddNote, note.ProjectId, int64(note.RequestId), note.Text).Scan(&id); err != nil {
+		return 0, fmt.Errorf("[repo] failed to save note: %w", err)
+	}
+	return id, nil
+}
+
+func (r *Repository) UpdateNote(ctx context.Context, note models.Note) error {
+	return r.execNote(ctx, UpdateNote, note.Id, note.ProjectId, note.Text)
+}
+
+func (r *Repository) DeleteNote(ctx context.Context, projectId, id uint64) error {
+	return r.execNote(ctx, DeleteNote, id, projectId)
+}
+
+func (r *Repository) execNote(ctx context.Context, query string, args ...any) error {
+	tag, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to update note: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("[repo] %w", &models.ErrNoteNotFound{})
+	}
+	return nil
+}
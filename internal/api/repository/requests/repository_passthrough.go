@@ -0,0 +1,119 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	passthroughColumns = `id, host, enabled, learned, failures, last_error, created_at, updated_at`
+
+	PassthroughAll    = `SELECT ` + passthroughColumns + ` FROM passthrough ORDER BY id`
+	PassthroughById   = `SELECT ` + passthroughColumns + ` FROM passthrough WHERE id=$1`
+	AddPassthrough    = `INSERT INTO passthrough (host, enabled) VALUES ($1, $2) RETURNING id`
+	UpdatePassthrough = `UPDATE passthrough SET host=$2, enabled=$3, updated_at=CURRENT_TIMESTAMP WHERE id=$1`
+	DeletePassthrough = `DELETE FROM passthrough WHERE id=$1`
+	LearnPassthrough  = `INSERT INTO passthrough (host, enabled, learned, failures, last_error) VALUES ($1, FALSE, TRUE, 1, $2)
+		ON CONFLICT (host) DO UPDATE SET failures=passthrough.failures+1, last_error=$2, updated_at=CURRENT_TIMESTAMP`
+)
+
+func (r *Repository) ListPassthroughHosts(ctx context.Context) ([]models.PassthroughHost, error) {
+	rows, err := r.db.Query(ctx, PassthroughAll)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query passthrough hosts: %w", err)
+	}
+	defer rows.Close()
+
+	hosts := []models.PassthroughHost{}
+	for rows.Next() {
+		host, err := scanPassthroughHost(rows)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, *host)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return hosts, nil
+}
+
+func (r *Repository) GetPassthroughHost(ctx context.Context, id uint64) (*models.PassthroughHost, error) {
+	host, err := scanPassthroughHost(r.db.QueryRow(ctx, PassthroughById, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrPassthroughNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed passthrough host db %w", err)
+	}
+	return host, nil
+}
+
+func scanPassthroughHost(row pgx.Row) (*models.PassthroughHost, error) {
+	var host models.PassthroughHost
+	if err := row.Scan(
+		&host.Id,
+		&host.Host,
+		&host.Enabled,
+		&host.Learned,
+		&host.Failures,
+		&host.LastError,
+		&host.CreatedAt,
+		&host.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &host, nil
+}
+
+func (r *Repository) SavePassthroughHost(ctx context.Context, host models.PassthroughHost) (uint64, error) {
+	var id uint64
+	err := r.db.QueryRow(ctx, AddPassthrough, host.Host, host.Enabled).Scan(&id)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return 0, fmt.Errorf("[repo] %w", &models.ErrPassthroughConflict{Host: host.Host})
+	} else if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save passthrough host: %w", err)
+	}
+	return id, nil
+}
+
+func (r *Repository) UpdatePassthroughHost(ctx context.Context, host models.PassthroughHost) error {
+	tag, err := r.db.Exec(ctx, UpdatePassthrough, host.Id, host.Host, host.Enabled)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("[repo] %w", &models.ErrPassthroughConflict{Host: host.Host})
+	} else if err != nil {
+		return fmt.Errorf("[repo] failed to update passthrough host: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("[repo] %w", &models.ErrPassthroughNotFound{})
+	}
+	return nil
+}
+
+func (r *Repository) DeletePassthroughHost(ctx context.Context, id uint64) error {
+	tag, err := r.db.Exec(ctx, DeletePassthrough, id)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to delete passthrough host: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("[repo] %w", &models.ErrPassthroughNotFound{})
+	}
+	return nil
+}
+
+func (r *Repository) RecordHandshakeFailure(ctx context.Context, host, reason string) error {
+	if _, err := r.db.Exec(ctx, LearnPassthrough, host, reason); err != nil {
+		return fmt.Errorf("[repo] failed to record handshake failure: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	projectColumns = `id, name, description, active, archived, created_at`
+
+	ProjectsAll      = `SELECT ` + projectColumns + ` FROM project WHERE $1 OR NOT archived ORDER BY id`
+	ProjectById      = `SELECT ` + projectColumns + ` FROM project WHERE id=$1`
+	ProjectActive    = `SELECT ` + projectColumns + ` FROM project WHERE active`
+	AddProject       = `INSERT INTO project (name, description) VALUES ($1, $2) RETURNING id`
+	DeactivateOthers = `UPDATE project SET active = FALSE WHERE active AND id <> $1`
+	ActivateProject  = `UPDATE project SET active = TRUE WHERE id=$1`
+	ArchiveProject   = `UPDATE project SET archived = $2 WHERE id=$1`
+	DeleteProject    = `DELETE FROM project WHERE id=$1`
+)
+
+// uniqueViolation is the postgres error code for a unique constraint.
+const uniqueViolation = "23505"
+
+func (r *Repository) ListProjects(ctx context.Context, archived bool) ([]models.Project, error) {
+	rows, err := r.db.Query(ctx, ProjectsAll, archived)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, *project)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return projects, nil
+}
+
+func (r *Repository) GetProject(ctx context.Context, id uint64) (*models.Project, error) {
+	return r.getProject(ctx, ProjectById, id)
+}
+
+func (r *Repository) GetActiveProject(ctx context.Context) (*models.Project, error) {
+	return r.getProject(ctx, ProjectActive)
+}
+
+func (r *Repository) getProject(ctx context.Context, query string, args ...any) (*models.Project, error) {
+	project, err := scanProject(r.db.QueryRow(ctx, query, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrProjectNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed project db %w", err)
+	}
+	return project, nil
+}
+
+func scanProject(row pgx.Row) (*models.Project, error) {
+	var project models.Project
+	if err := row.Scan(
+		&project.Id,
+		&project.Name,
+		&project.Description,
+		&project.Active,
+		&project.Archived,
+		&project.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *Repository) SaveProject(ctx context.Context, project models.Project) (uint64, error) {
+	var id uint64
+	err := r.db.QueryRow(ctx, AddProject, project.Name, project.Description).Scan(&id)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return 0, fmt.Errorf("[repo] %w", &models.ErrProjectConflict{Reason: fmt.Sprintf("name %q is taken", project.Name)})
+	} else if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save project: %w", err)
+	}
+	return id, nil
+}
+
+// SetActiveProject makes id the one active project.
+func (r *Repository) SetActiveProject(ctx context.Context, id uint64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, DeactivateOthers, id); err != nil {
+		return fmt.Errorf("[repo] failed to deactivate projects: %w", err)
+	}
+	tag, err := tx.Exec(ctx, ActivateProject, id)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to activate project: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("[repo] %w", &models.ErrProjectNotFound{})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("[repo] failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) SetProjectArchived(ctx context.Context, id uint64, archived bool) error {
+	return r.execProject(ctx, ArchiveProject, id, archived)
+}
+
+// DeleteProject removes the project with everything captured in it.
+func (r *Repository) DeleteProject(ctx context.Context, id uint64) error {
+	return r.execProject(ctx, DeleteProject, id)
+}
+
+func (r *Repository) execProject(ctx context.Context, query string, args ...any) error {
+	tag, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to update project: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("[repo] %w", &models.ErrProjectNotFound{})
+	}
+	return nil
+}
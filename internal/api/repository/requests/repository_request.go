@@ -6,18 +6,20 @@ import (
 	"errors"
 	"fmt"
 
+	"proxy/internal/api/repository"
 	"proxy/internal/models"
 	"proxy/pkg/logger"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 const (
-	RequestsAll = `SELECT * FROM request ORDER BY created_at`
-	RequestById = `SELECT * FROM request WHERE id=$1`
-	AddRequest  = `INSERT INTO request (method, host, path, headers, query_params, post_params, cookies, body) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	AddResponse = `INSERT INTO response (request_id, status_code, headers, body) VALUES ($1, $2, $3, $4) RETURNING id`
+	requestColumns = `req.id, req.project_id, req.method, req.host, req.path, req.headers, req.query_params, req.post_params, req.cookies, req.body, COALESCE(req.decoded_body, ''), req.encodings, req.mime_type, req.body_size, req.body_truncated, req.proto, req.created_at, req.fired_rules, req.scheme`
+
+	RequestById = `SELECT ` + requestColumns + ` FROM request req WHERE req.id=$1 AND req.project_id=$2`
+	AddRequest  = `INSERT INTO request (project_id, method, host, path, headers, query_params, post_params, cookies, body, decoded_body, encodings, mime_type, body_size, body_truncated, proto, fired_rules, scheme) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id`
+	AddResponse = `INSERT INTO response (request_id, status_code, headers, body, decoded_body, encodings, mime_type, body_size, body_truncated, proto, wait_ms, duration_ms) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
 	/* INSERT INTO request (method, "url", body, headers)
 	   VALUES ('GET', 'https://example.com', 'body content', '{"Content-Type": "application/json"}'); */
 )
@@ -34,85 +36,80 @@ func NewRepository(db *pgxpool.Pool, log logger.Logger) *Repository {
 	}
 }
 
-func (r *Repository) GetAllRequests(ctx context.Context) ([]models.Request, error) {
-	var requests []models.Request
+// ListRequests returns one page of the history matching filter, ordered by
+// the requested sort key with the id as tie breaker.
+func (r *Repository) ListRequests(ctx context.Context, filter models.RequestFilter) (*models.RequestPage, error) {
+	filter, err := repository.PageFilter(filter)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := r.db.Query(ctx, RequestsAll)
+	query, args, err := buildRequestQuery(filter)
 	if err != nil {
-		return nil, fmt.Errorf("[repo] Error no tags found: %w, %v", &models.ErrRequestNotFuound{}, err)
+		return nil, err
 	}
 
-	var rawHeaders json.RawMessage
-	var rawCookies json.RawMessage
-	var rawGetParams json.RawMessage
-	var rawPostParams json.RawMessage
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query requests: %w", err)
+	}
+	defer rows.Close()
 
+	page := &models.RequestPage{Requests: []models.Request{}}
+	var lastKey string
 	for rows.Next() {
-		var request models.Request
-		if err := rows.Scan(
-			&request.Id,
-			&request.Method,
-			&request.Host,
-			&request.Path,
-			&rawHeaders,
-			&rawGetParams,
-			&rawPostParams,
-			&rawCookies,
-			&request.Body,
-			&request.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-
-		var headers map[string][]string
-		if err := json.Unmarshal(rawHeaders, &headers); err != nil {
-			return nil, err
-		}
-
-		var query map[string][]string
-		if err := json.Unmarshal(rawGetParams, &query); err != nil {
-			return nil, err
-		}
-
-		var formdata map[string][]string
-		if err := json.Unmarshal(rawPostParams, &formdata); err != nil {
+		var sortKey string
+		request, err := scanRequest(rows, &sortKey)
+		if err != nil {
 			return nil, err
 		}
 
-		var cookies map[string]string
-		if err := json.Unmarshal(rawCookies, &cookies); err != nil {
-			return nil, err
+		if len(page.Requests) == filter.Limit {
+			// One row past the page only tells that there is more.
+			page.NextCursor = repository.EncodeCursor(repository.Cursor{
+				Sort: filter.Sort,
+				Desc: filter.Desc,
+				Key:  lastKey,
+				Id:   page.Requests[len(page.Requests)-1].Id,
+			})
+			break
 		}
-
-		request.Headers = headers
-		request.Cookies = cookies
-		request.Get_Params = query
-		request.Post_Params = formdata
-
-		requests = append(requests, request)
+		page.Requests = append(page.Requests, *request)
+		lastKey = sortKey
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
 	}
 
-	if len(requests) == 0 {
-		return nil, fmt.Errorf("[repo] Error no requests found: %w:%v", &models.ErrRequestNotFuound{}, err)
+	return page, nil
+}
+
+func (r *Repository) GetRequestById(ctx context.Context, projectId, id uint64) (*models.Request, error) {
+	req, err := scanRequest(r.db.QueryRow(ctx, RequestById, id, projectId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrRequestNotFuound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed request db %w", err)
 	}
-	return requests, nil
+
+	return req, nil
 }
 
-func (r *Repository) GetRequestById(ctx context.Context, id uint64) (*models.Request, error) {
-	row := r.db.QueryRow(ctx, RequestById, id)
+// scanRequest reads a row made of requestColumns followed by extra.
+func scanRequest(row pgx.Row, extra ...any) (*models.Request, error) {
 	var req models.Request
 
 	var rawHeaders json.RawMessage
 	var rawCookies json.RawMessage
 	var rawGetParams json.RawMessage
 	var rawPostParams json.RawMessage
+	var rawEncodings json.RawMessage
+	var rawFiredRules json.RawMessage
 
-	err := row.Scan(
+	dest := []any{
 		&req.Id,
+		&req.ProjectId,
 		&req.Method,
 		&req.Host,
 		&req.Path,
@@ -121,42 +118,61 @@ func (r *Repository) GetRequestById(ctx context.Context, id uint64) (*models.Req
 		&rawPostParams,
 		&rawCookies,
 		&req.Body,
+		&req.DecodedBody,
+		&rawEncodings,
+		&req.MimeType,
+		&req.BodySize,
+		&req.Truncated,
+		&req.Proto,
 		&req.CreatedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrRequestNotFuound{}, err)
-	} else if err != nil {
-		return nil, fmt.Errorf("failed request db %w", err)
+		&rawFiredRules,
+		&req.Scheme,
 	}
-
-	var headers map[string][]string
-	if err := json.Unmarshal(rawHeaders, &headers); err != nil {
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		return nil, err
 	}
 
-	var query map[string][]string
-	if err := json.Unmarshal(rawGetParams, &query); err != nil {
+	if err := unmarshalColumn(rawHeaders, &req.Headers); err != nil {
 		return nil, err
 	}
-
-	var formdata map[string][]string
-	if err := json.Unmarshal(rawPostParams, &formdata); err != nil {
+	if err := unmarshalColumn(rawGetParams, &req.Get_Params); err != nil {
 		return nil, err
 	}
-
-	var cookies map[string]string
-	if err := json.Unmarshal(rawCookies, &cookies); err != nil {
+	if err := unmarshalColumn(rawPostParams, &req.Post_Params); err != nil {
 		return nil, err
 	}
-
-	req.Headers = headers
-	req.Cookies = cookies
-	req.Get_Params = query
-	req.Post_Params = formdata
+	if err := unmarshalColumn(rawCookies, &req.Cookies); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawEncodings, &req.Encodings); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawFiredRules, &req.FiredRules); err != nil {
+		return nil, err
+	}
+	req.FiredRules = firedRules(req)
 
 	return &req, nil
 }
 
+// firedRules returns the rules that fired for request, an empty list
+// rather than nil when none did.
+func firedRules(request models.Request) []uint64 {
+	if request.FiredRules == nil {
+		return []uint64{}
+	}
+	return request.FiredRules
+}
+
+// unmarshalColumn decodes a JSONB column, leaving v untouched for NULL,
+// which rows written before a column existed have.
+func unmarshalColumn(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
 func (r *Repository) SaveRequest(ctx context.Context, request models.Request) (uint64, error) {
 	rawHeaders, err := json.Marshal(&request.Headers)
 	if err != nil {
@@ -178,7 +194,18 @@ func (r *Repository) SaveRequest(ctx context.Context, request models.Request) (u
 		return 0, err
 	}
 
+	rawEncodings, err := json.Marshal(&request.Encodings)
+	if err != nil {
+		return 0, err
+	}
+
+	rawFiredRules, err := json.Marshal(firedRules(request))
+	if err != nil {
+		return 0, err
+	}
+
 	row := r.db.QueryRow(ctx, AddRequest,
+		request.ProjectId,
 		request.Method,
 		request.Host,
 		request.Path,
@@ -187,6 +214,14 @@ func (r *Repository) SaveRequest(ctx context.Context, request models.Request) (u
 		rawPostParams,
 		rawCookies,
 		request.Body,
+		request.DecodedBody,
+		rawEncodings,
+		request.MimeType,
+		request.BodySize,
+		request.Truncated,
+		request.Proto,
+		rawFiredRules,
+		defaultScheme(request.Scheme),
 	)
 
 	if err := row.Scan(&request.Id); err != nil {
@@ -202,11 +237,24 @@ func (r *Repository) SaveResponse(ctx context.Context, response models.Response)
 		return err
 	}
 
+	rawEncodings, err := json.Marshal(&response.Encodings)
+	if err != nil {
+		return err
+	}
+
 	row := r.db.QueryRow(ctx, AddResponse,
 		response.RequestId,
 		response.Code,
 		rawHeaders,
 		response.Body,
+		response.DecodedBody,
+		rawEncodings,
+		response.MimeType,
+		response.BodySize,
+		response.Truncated,
+		response.Proto,
+		response.WaitMs,
+		response.DurationMs,
 	)
 
 	if err := row.Scan(&response.Id); err != nil {
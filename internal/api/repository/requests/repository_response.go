@@ -0,0 +1,74 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	responseColumns = `resp.id, resp.request_id, resp.status_code, resp.headers, resp.body, COALESCE(resp.decoded_body, ''), resp.encodings, resp.mime_type, resp.body_size, resp.body_truncated, resp.proto, COALESCE(resp.wait_ms, 0), COALESCE(resp.duration_ms, 0), resp.created_at`
+
+	ResponseById        = `SELECT ` + responseColumns + ` FROM response resp JOIN request req ON req.id = resp.request_id WHERE resp.id=$1 AND req.project_id=$2`
+	ResponseByRequestId = `SELECT ` + responseColumns + ` FROM response resp JOIN request req ON req.id = resp.request_id WHERE resp.request_id=$1 AND req.project_id=$2 ORDER BY resp.id LIMIT 1`
+)
+
+func (r *Repository) GetResponseById(ctx context.Context, projectId, id uint64) (*models.Response, error) {
+	return r.getResponse(ctx, ResponseById, projectId, id)
+}
+
+// GetResponseByRequestId returns the response recorded for a request.
+func (r *Repository) GetResponseByRequestId(ctx context.Context, projectId, requestId uint64) (*models.Response, error) {
+	return r.getResponse(ctx, ResponseByRequestId, projectId, requestId)
+}
+
+func (r *Repository) getResponse(ctx context.Context, query string, projectId, id uint64) (*models.Response, error) {
+	resp, err := scanResponse(r.db.QueryRow(ctx, query, id, projectId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrResponseNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query response: %w", err)
+	}
+	return resp, nil
+}
+
+// scanResponse reads a row made of responseColumns.
+func scanResponse(row pgx.Row) (*models.Response, error) {
+	var resp models.Response
+
+	var rawHeaders json.RawMessage
+	var rawEncodings json.RawMessage
+
+	if err := row.Scan(
+		&resp.Id,
+		&resp.RequestId,
+		&resp.Code,
+		&rawHeaders,
+		&resp.Body,
+		&resp.DecodedBody,
+		&rawEncodings,
+		&resp.MimeType,
+		&resp.BodySize,
+		&resp.Truncated,
+		&resp.Proto,
+		&resp.WaitMs,
+		&resp.DurationMs,
+		&resp.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalColumn(rawHeaders, &resp.Headers); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawEncodings, &resp.Encodings); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,102 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	ruleColumns = `id, name, enabled, target, host, match, replace, regex, created_at, updated_at`
+
+	RulesAll   = `SELECT ` + ruleColumns + ` FROM rule ORDER BY id`
+	RuleById   = `SELECT ` + ruleColumns + ` FROM rule WHERE id=$1`
+	AddRule    = `INSERT INTO rule (name, enabled, target, host, match, replace, regex) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	UpdateRule = `UPDATE rule SET name=$2, enabled=$3, target=$4, host=$5, match=$6, replace=$7, regex=$8, updated_at=CURRENT_TIMESTAMP WHERE id=$1`
+	DeleteRule = `DELETE FROM rule WHERE id=$1`
+)
+
+func (r *Repository) ListRules(ctx context.Context) ([]models.Rule, error) {
+	rows, err := r.db.Query(ctx, RulesAll)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.Rule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return rules, nil
+}
+
+func (r *Repository) GetRule(ctx context.Context, id uint64) (*models.Rule, error) {
+	rule, err := scanRule(r.db.QueryRow(ctx, RuleById, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrRuleNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed rule db %w", err)
+	}
+	return rule, nil
+}
+
+func scanRule(row pgx.Row) (*models.Rule, error) {
+	var rule models.Rule
+	if err := row.Scan(
+		&rule.Id,
+		&rule.Name,
+		&rule.Enabled,
+		&rule.Target,
+		&rule.Host,
+		&rule.Match,
+		&rule.Replace,
+		&rule.Regex,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *Repository) SaveRule(ctx context.Context, rule models.Rule) (uint64, error) {
+	var id uint64
+	err := r.db.QueryRow(ctx, AddRule,
+		rule.Name, rule.Enabled, rule.Target, rule.Host, rule.Match, rule.Replace, rule.Regex).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save rule: %w", err)
+	}
+	return id, nil
+}
+
+func (r *Repository) UpdateRule(ctx context.Context, rule models.Rule) error {
+	return r.execRule(ctx, UpdateRule,
+		rule.Id, rule.Name, rule.Enabled, rule.Target, rule.Host, rule.Match, rule.Replace, rule.Regex)
+}
+
+func (r *Repository) DeleteRule(ctx context.Context, id uint64) error {
+	return r.execRule(ctx, DeleteRule, id)
+}
+
+func (r *Repository) execRule(ctx context.Context, query string, args ...any) error {
+	tag, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to update rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("[repo] %w", &models.ErrRuleNotFound{})
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"proxy/internal/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	scopeRuleColumns = `id, project_id, kind, scheme, host, port, path, created_at, updated_at`
+
+	ScopeRulesAll   = `SELECT ` + scopeRuleColumns + ` FROM scope_rule WHERE project_id=$1 ORDER BY id`
+	ScopeRuleById   = `SELECT ` + scopeRuleColumns + ` FROM scope_rule WHERE id=$1 AND project_id=$2`
+	AddScopeRule    = `INSERT INTO scope_rule (project_id, kind, scheme, host, port, path) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	UpdateScopeRule = `UPDATE scope_rule SET kind=$3, scheme=$4, host=$5, port=$6, path=$7, updated_at=CURRENT_TIMESTAMP WHERE id=$1 AND project_id=$2`
+	DeleteScopeRule = `DELETE FROM scope_rule WHERE id=$1 AND project_id=$2`
+)
+
+func (r *Repository) ListScopeRules(ctx context.Context, projectId uint64) ([]models.ScopeRule, error) {
+	rows, err := r.db.Query(ctx, ScopeRulesAll, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query scope rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.ScopeRule{}
+	for rows.Next() {
+		rule, err := scanScopeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return rules, nil
+}
+
+func (r *Repository) GetScopeRule(ctx context.Context, projectId, id uint64) (*models.ScopeRule, error) {
+	rule, err := scanScopeRule(r.db.QueryRow(ctx, ScopeRuleById, id, projectId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrScopeRuleNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed scope rule db %w", err)
+	}
+	return rule, nil
+}
+
+func scanScopeRule(row pgx.Row) (*models.ScopeRule, error) {
+	var rule models.ScopeRule
+	if err := row.Scan(
+		&rule.Id,
+		&rule.ProjectId,
+		&rule.Kind,
+		&rule.Scheme,
+		&rule.Host,
+		&rule.Port,
+		&rule.Path,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *Repository) SaveScopeRule(ctx context.Context, rule models.ScopeRule) (uint64, error) {
+	var id uint64
+	err := r.db.QueryRow(ctx, AddScopeRule,
+		rule.ProjectId, rule.Kind, rule.Scheme, rule.Host, rule.Port, rule.Path).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save scope rule: %w", err)
+	}
+	return id, nil
+}
+
+func (r *Repository) UpdateScopeRule(ctx context.Context, rule models.ScopeRule) error {
+	return r.execScopeRule(ctx, UpdateScopeRule,
+		rule.Id, rule.ProjectId, rule.Kind, rule.Scheme, rule.Host, rule.Port, rule.Path)
+}
+
+func (r *Repository) DeleteScopeRule(ctx context.Context, projectId, id uint64) error {
+	return r.execScopeRule(ctx, DeleteScopeRule, id, projectId)
+}
+
+func (r *Repository) execScopeRule(ctx context.Context, query string, args ...any) error {
+	tag, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to update scope rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("[repo] %w", &models.ErrScopeRuleNotFound{})
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package requests
+
+import (
+	"context"
+	"fmt"
+
+	"proxy/internal/models"
+)
+
+// snippetContext is how many characters around a match Search returns.
+const snippetContext = 60
+
+// SearchExchanges runs a case-insensitive substring match over every indexed field,
+// each one backed by a trigram index. Only a window around the first match
+// of each field is fetched, Snippet holds that raw text.
+const SearchExchanges = `WITH hits AS (
+		SELECT req.id AS request_id, 'req.path' AS field, req.path AS content, req.created_at
+			FROM request req WHERE req.project_id = $5 AND req.path ILIKE $1
+		UNION ALL
+		SELECT req.id, 'req.query', req.query_params::text, req.created_at
+			FROM request req WHERE req.project_id = $5 AND req.query_params::text ILIKE $1
+		UNION ALL
+		SELECT req.id, 'req.header', req.headers::text, req.created_at
+			FROM request req WHERE req.project_id = $5 AND req.headers::text ILIKE $1
+		UNION ALL
+		SELECT req.id, 'req.body', req.decoded_body, req.created_at
+			FROM request req WHERE req.project_id = $5 AND req.decoded_body ILIKE $1
+		UNION ALL
+		SELECT req.id, 'resp.header', resp.headers::text, req.created_at
+			FROM response resp JOIN request req ON req.id = resp.request_id WHERE req.project_id = $5 AND resp.headers::text ILIKE $1
+		UNION ALL
+		SELECT req.id, 'resp.body', resp.decoded_body, req.created_at
+			FROM response resp JOIN request req ON req.id = resp.request_id WHERE req.project_id = $5 AND resp.decoded_body ILIKE $1
+	)
+	SELECT request_id, field, created_at,
+		substr(content, greatest(strpos(lower(content), lower($2)) - $3, 1), length($2) + 2 * $3)
+	FROM hits ORDER BY created_at, request_id, field LIMIT $4`
+
+func (r *Repository) Search(ctx context.Context, projectId uint64, text string, limit int) ([]models.SearchResult, error) {
+	rows, err := r.db.Query(ctx, SearchExchanges, "%"+escapeLike(text)+"%", text, snippetContext, limit, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var res models.SearchResult
+		if err := rows.Scan(&res.RequestId, &res.Field, &res.CreatedAt, &res.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+
+	return results, nil
+}
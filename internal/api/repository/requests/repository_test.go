@@ -0,0 +1,53 @@
+package requests
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/api/repository/conformance"
+	"proxy/internal/migrations"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// TestConformance runs against the scratch database named by
+// PROXY_TEST_POSTGRES_DSN. It is wiped back to the default project before
+// every test.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("PROXY_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("PROXY_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx := context.Background()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	db, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	migrator, err := migrations.NewMigrator(db, log)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	conformance.Run(t, func(t *testing.T) repository.Repository {
+		if _, err := db.Exec(ctx, `TRUNCATE project, request, response, websocket_message, note, scan_result, rule, passthrough RESTART IDENTITY CASCADE`); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		if _, err := db.Exec(ctx, `INSERT INTO project (name, active) VALUES ('default', TRUE)`); err != nil {
+			t.Fatalf("default project: %v", err)
+		}
+		return NewRepository(db, log)
+	})
+}
@@ -0,0 +1,90 @@
+package requests
+
+import (
+	"context"
+	"fmt"
+
+	"proxy/internal/models"
+)
+
+const (
+	WebSocketsAll = `SELECT r.id, r.host, r.path, count(m.id), r.created_at
+		FROM request r JOIN websocket_message m ON m.request_id = r.id
+		WHERE r.project_id=$1
+		GROUP BY r.id ORDER BY r.created_at`
+	WebSocketMessages = `SELECT m.id, m.request_id, m.direction, m.opcode, m.payload, m.created_at
+		FROM websocket_message m JOIN request r ON r.id = m.request_id
+		WHERE m.request_id=$1 AND r.project_id=$2 ORDER BY m.id`
+	AddWebSocketMessage = `INSERT INTO websocket_message (request_id, direction, opcode, payload, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+)
+
+func (r *Repository) GetWebSockets(ctx context.Context, projectId uint64) ([]models.WebSocket, error) {
+	rows, err := r.db.Query(ctx, WebSocketsAll, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query websockets: %w", err)
+	}
+	defer rows.Close()
+
+	var sockets []models.WebSocket
+	for rows.Next() {
+		var ws models.WebSocket
+		if err := rows.Scan(&ws.RequestId, &ws.Host, &ws.Path, &ws.MessageCount, &ws.CreatedAt); err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, ws)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+
+	if len(sockets) == 0 {
+		return nil, fmt.Errorf("[repo] Error no websockets found: %w", &models.ErrRequestNotFuound{})
+	}
+	return sockets, nil
+}
+
+func (r *Repository) GetWebSocketMessages(ctx context.Context, projectId, requestId uint64) ([]models.WebSocketMessage, error) {
+	rows, err := r.db.Query(ctx, WebSocketMessages, requestId, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query websocket messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.WebSocketMessage{}
+	for rows.Next() {
+		var msg models.WebSocketMessage
+		if err := rows.Scan(
+			&msg.Id,
+			&msg.RequestId,
+			&msg.Direction,
+			&msg.Opcode,
+			&msg.Payload,
+			&msg.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return messages, nil
+}
+
+func (r *Repository) SaveWebSocketMessage(ctx context.Context, message models.WebSocketMessage) error {
+	row := r.db.QueryRow(ctx, AddWebSocketMessage,
+		message.RequestId,
+		message.Direction,
+		message.Opcode,
+		message.Payload,
+		message.CreatedAt,
+	)
+
+	if err := row.Scan(&message.Id); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/models"
+	"proxy/pkg/filterexpr"
+)
+
+// sortKey is a column the history can be ordered by. cast turns the text
+// form kept in cursors back into the column's type.
+type sortKey struct {
+	expr string
+	cast string
+}
+
+var sortKeys = map[string]sortKey{
+	"created_at": {expr: "req.created_at", cast: "INTEGER"},
+	"host":       {expr: "req.host", cast: "TEXT"},
+	"status":     {expr: "COALESCE(resp.status_code, 0)", cast: "INTEGER"},
+	"size":       {expr: "COALESCE(resp.body_size, 0)", cast: "INTEGER"},
+}
+
+// queryBuilder collects WHERE conditions with numbered parameters.
+type queryBuilder struct {
+	where []string
+	args  []any
+}
+
+func (b *queryBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("?%d", len(b.args))
+}
+
+func (b *queryBuilder) cond(format string, args ...any) {
+	placeholders := make([]any, len(args))
+	for i, a := range args {
+		placeholders[i] = b.arg(a)
+	}
+	b.where = append(b.where, fmt.Sprintf(format, placeholders...))
+}
+
+// buildRequestQuery turns filter into a keyset paginated query. It selects
+// requestColumns plus the sort key and fetches one row more than the page
+// size to tell whether another page follows.
+func buildRequestQuery(filter models.RequestFilter) (string, []any, error) {
+	key, ok := sortKeys[filter.Sort]
+	if !ok {
+		return "", nil, &models.ErrBadFilter{Reason: fmt.Sprintf("unknown sort key %q", filter.Sort)}
+	}
+
+	b := &queryBuilder{}
+	b.cond("req.project_id = %s", filter.ProjectId)
+
+	if len(filter.Methods) > 0 {
+		placeholders := make([]string, len(filter.Methods))
+		for i, m := range filter.Methods {
+			placeholders[i] = b.arg(strings.ToUpper(m))
+		}
+		b.where = append(b.where, "req.method IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	if filter.Host != "" {
+		host := strings.ToLower(filter.Host)
+		if strings.Contains(host, "*") {
+			b.cond(`lower(req.host) LIKE %s ESCAPE '\'`, globToLike(host))
+		} else {
+			// Hosts are stored as sent, possibly with a port.
+			b.cond(`(lower(req.host) = %s OR lower(req.host) LIKE %s ESCAPE '\')`, host, escapeLike(host)+":%")
+		}
+	}
+
+	if filter.PathPrefix != "" {
+		// LIKE ignores case in SQLite, unlike the path prefix.
+		b.cond(`substr(req.path, 1, length(%[1]s)) = %[1]s`, filter.PathPrefix)
+	}
+
+	if filter.StatusMin > 0 {
+		b.cond("resp.status_code >= %s", filter.StatusMin)
+	}
+	if filter.StatusMax > 0 {
+		b.cond("resp.status_code <= %s", filter.StatusMax)
+	}
+
+	if filter.ContentType != "" {
+		b.cond(`instr(lower(resp.mime_type), lower(%s)) > 0`, filter.ContentType)
+	}
+
+	if !filter.From.IsZero() {
+		b.cond("req.created_at >= %s", toMicros(filter.From))
+	}
+	if !filter.To.IsZero() {
+		b.cond("req.created_at < %s", toMicros(filter.To))
+	}
+
+	if filter.MinSize != nil {
+		b.cond("COALESCE(resp.body_size, 0) >= %s", *filter.MinSize)
+	}
+	if filter.MaxSize != nil {
+		b.cond("COALESCE(resp.body_size, 0) <= %s", *filter.MaxSize)
+	}
+
+	if filter.Expression != "" {
+		node, err := filterexpr.Parse(filter.Expression)
+		if err != nil {
+			return "", nil, &models.ErrBadFilter{Reason: err.Error()}
+		}
+		b.where = append(b.where, compileFilter(b, node))
+	}
+
+	direction, op := "ASC", ">"
+	if filter.Desc {
+		direction, op = "DESC", "<"
+	}
+
+	if filter.Cursor != "" {
+		c, err := repository.DecodeCursor(filter.Cursor, filter)
+		if err != nil {
+			return "", nil, err
+		}
+		b.where = append(b.where, fmt.Sprintf("(%s, req.id) %s (CAST(%s AS %s), %s)",
+			key.expr, op, b.arg(c.Key), key.cast, b.arg(c.Id)))
+	}
+
+	var query strings.Builder
+	fmt.Fprintf(&query, "SELECT %s, CAST(%s AS TEXT) FROM request req LEFT JOIN response resp ON resp.request_id = req.id", requestColumns, key.expr)
+	query.WriteString(" WHERE ")
+	query.WriteString(strings.Join(b.where, " AND "))
+	fmt.Fprintf(&query, " ORDER BY %s %s, req.id %s LIMIT %s", key.expr, direction, direction, b.arg(filter.Limit+1))
+
+	return query.String(), b.args, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// globToLike converts a host glob such as *.example.com to a LIKE pattern.
+func globToLike(glob string) string {
+	return strings.ReplaceAll(escapeLike(glob), "*", "%")
+}
@@ -0,0 +1,204 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"proxy/pkg/logger"
+
+	"modernc.org/sqlite"
+)
+
+// The schema is sql/<version>_<name>.sql files applied in version order,
+// PRAGMA user_version records the last one. A project file only ever moves
+// forward, there are no down scripts.
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+func init() {
+	// X REGEXP Y calls regexp(Y, X), which SQLite leaves to the application.
+	// Filters use Go's syntax here, the same as the memory backend.
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, matchRegexp)
+}
+
+type Repository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewRepository(db *sql.DB, log logger.Logger) *Repository {
+	return &Repository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Open opens the project file at path, creating it if needed, and brings
+// its schema up to date. The api and the proxy may have the same file open:
+// writes wait for each other instead of failing.
+func Open(ctx context.Context, path string, log logger.Logger) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(1)")
+	// Transactions take the write lock up front, a deferred one would fail
+	// to upgrade once the other process has written.
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(ctx, db, log); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, log logger.Logger) error {
+	scripts, err := loadScripts()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[sqlite] failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current int
+	if err := tx.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&current); err != nil {
+		return fmt.Errorf("[sqlite] failed to read schema version: %w", err)
+	}
+
+	for _, s := range scripts {
+		if s.version <= current {
+			continue
+		}
+		log.Infof("applying sqlite schema %04d_%s", s.version, s.name)
+		if _, err := tx.ExecContext(ctx, s.script); err != nil {
+			return fmt.Errorf("[sqlite] %04d_%s: %w", s.version, s.name, err)
+		}
+		// PRAGMA takes no parameters.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, s.version)); err != nil {
+			return fmt.Errorf("[sqlite] failed to record schema version: %w", err)
+		}
+	}
+
+	if n := len(scripts); n > 0 && current > scripts[n-1].version {
+		log.Warnf("sqlite schema version %d is newer than this binary knows about", current)
+	}
+
+	return tx.Commit()
+}
+
+type script struct {
+	version int
+	name    string
+	script  string
+}
+
+func loadScripts() ([]script, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	scripts := make([]script, 0, len(entries))
+	for _, entry := range entries {
+		prefix, label, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("[sqlite] file %s is not named <version>_<name>.sql", entry.Name())
+		}
+
+		content, err := fs.ReadFile(files, "sql/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, script{version: version, name: label, script: string(content)})
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].version < scripts[j].version })
+
+	return scripts, nil
+}
+
+// maxPatterns bounds the compiled patterns kept. Past it the cache starts
+// over, the patterns of the filters running at the time come back at once.
+const maxPatterns = 256
+
+// patterns caches compiled regular expressions, a filter runs the same
+// one against every row.
+var patterns = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: make(map[string]*regexp.Regexp)}
+
+func matchRegexp(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := textArg(args[0])
+	if !ok {
+		return nil, nil
+	}
+	value, ok := textArg(args[1])
+	if !ok {
+		return nil, nil
+	}
+
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(value), nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patterns.Lock()
+	defer patterns.Unlock()
+
+	if re, ok := patterns.compiled[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns.compiled) >= maxPatterns {
+		clear(patterns.compiled)
+	}
+	patterns.compiled[pattern] = re
+	return re, nil
+}
+
+func textArg(v driver.Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+// Times are stored as microseconds since the Unix epoch.
+func toMicros(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func fromMicros(us int64) time.Time {
+	return time.UnixMicro(us)
+}
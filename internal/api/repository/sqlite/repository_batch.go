@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+// SaveExchanges stores a batch of exchanges in one transaction, which is
+// what makes SQLite keep up: every commit is a sync to disk. Exchanges
+// without a response only add a request row.
+func (r *Repository) SaveExchanges(ctx context.Context, exchanges []models.Exchange) error {
+	if len(exchanges) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, ex := range exchanges {
+		request := *ex.Request
+		request.Proto = defaultProto(request.Proto)
+		id, err := insertRequest(ctx, tx, request, capturedAt(request.CreatedAt, now))
+		if err != nil {
+			return err
+		}
+
+		if ex.Response != nil {
+			response := *ex.Response
+			response.RequestId = id
+			response.Proto = defaultProto(response.Proto)
+			if err := insertResponse(ctx, tx, response, capturedAt(response.CreatedAt, now)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("[repo] failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// defaultProto mirrors the column default, which an explicit empty value
+// would bypass.
+func defaultProto(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func defaultScheme(scheme string) string {
+	if scheme == "" {
+		return "http"
+	}
+	return scheme
+}
+
+func capturedAt(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t
+}
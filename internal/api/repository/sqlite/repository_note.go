@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+const (
+	noteColumns = `id, project_id, COALESCE(request_id, 0), "text", created_at, updated_at`
+
+	// A zero request id lists every note of the project.
+	NotesAll   = `SELECT ` + noteColumns + ` FROM note WHERE project_id=?1 AND (?2 = 0 OR request_id=?2) ORDER BY id`
+	NoteById   = `SELECT ` + noteColumns + ` FROM note WHERE id=?1 AND project_id=?2`
+	AddNote    = `INSERT INTO note (project_id, request_id, "text", created_at, updated_at) VALUES (?1, NULLIF(?2, 0), ?3, ?4, ?4)`
+	UpdateNote = `UPDATE note SET "text"=?3, updated_at=?4 WHERE id=?1 AND project_id=?2`
+	DeleteNote = `DELETE FROM note WHERE id=?1 AND project_id=?2`
+)
+
+func (r *Repository) ListNotes(ctx context.Context, projectId, requestId uint64) ([]models.Note, error) {
+	rows, err := r.db.QueryContext(ctx, NotesAll, projectId, requestId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return notes, nil
+}
+
+func (r *Repository) GetNote(ctx context.Context, projectId, id uint64) (*models.Note, error) {
+	note, err := scanNote(r.db.QueryRowContext(ctx, NoteById, id, projectId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrNoteNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query note: %w", err)
+	}
+	return note, nil
+}
+
+func scanNote(row row) (*models.Note, error) {
+	var note models.Note
+	var createdAt, updatedAt int64
+	if err := row.Scan(
+		&note.Id,
+		&note.ProjectId,
+		&note.RequestId,
+		&note.Text,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	note.CreatedAt = fromMicros(createdAt)
+	note.UpdatedAt = fromMicros(updatedAt)
+	return &note, nil
+}
+
+func (r *Repository) SaveNote(ctx context.Context, note models.Note) (uint64, error) {
+	res, err := r.db.ExecContext(ctx, AddNote, note.ProjectId, note.RequestId, note.Text, toMicros(time.Now()))
+	if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save note: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func (r *Repository) UpdateNote(ctx context.Context, note models.Note) error {
+	return execOne(ctx, r.db, &models.ErrNoteNotFound{}, UpdateNote, note.Id, note.ProjectId, note.Text, toMicros(time.Now()))
+}
+
+func (r *Repository) DeleteNote(ctx context.Context, projectId, id uint64) error {
+	return execOne(ctx, r.db, &models.ErrNoteNotFound{}, DeleteNote, id, projectId)
+}
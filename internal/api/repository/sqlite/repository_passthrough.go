@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	passthroughColumns = `id, host, enabled, learned, failures, last_error, created_at, updated_at`
+
+	PassthroughAll    = `SELECT ` + passthroughColumns + ` FROM passthrough ORDER BY id`
+	PassthroughById   = `SELECT ` + passthroughColumns + ` FROM passthrough WHERE id=?1`
+	AddPassthrough    = `INSERT INTO passthrough (host, enabled, created_at, updated_at) VALUES (?1, ?2, ?3, ?3)`
+	UpdatePassthrough = `UPDATE passthrough SET host=?2, enabled=?3, updated_at=?4 WHERE id=?1`
+	DeletePassthrough = `DELETE FROM passthrough WHERE id=?1`
+	LearnPassthrough  = `INSERT INTO passthrough (host, enabled, learned, failures, last_error, created_at, updated_at) VALUES (?1, 0, 1, 1, ?2, ?3, ?3)
+		ON CONFLICT (host) DO UPDATE SET failures=failures+1, last_error=?2, updated_at=?3`
+)
+
+func (r *Repository) ListPassthroughHosts(ctx context.Context) ([]models.PassthroughHost, error) {
+	rows, err := r.db.QueryContext(ctx, PassthroughAll)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query passthrough hosts: %w", err)
+	}
+	defer rows.Close()
+
+	hosts := []models.PassthroughHost{}
+	for rows.Next() {
+		host, err := scanPassthroughHost(rows)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, *host)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return hosts, nil
+}
+
+func (r *Repository) GetPassthroughHost(ctx context.Context, id uint64) (*models.PassthroughHost, error) {
+	host, err := scanPassthroughHost(r.db.QueryRowContext(ctx, PassthroughById, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrPassthroughNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query passthrough host: %w", err)
+	}
+	return host, nil
+}
+
+func scanPassthroughHost(row row) (*models.PassthroughHost, error) {
+	var host models.PassthroughHost
+	var createdAt, updatedAt int64
+	if err := row.Scan(
+		&host.Id,
+		&host.Host,
+		&host.Enabled,
+		&host.Learned,
+		&host.Failures,
+		&host.LastError,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	host.CreatedAt = fromMicros(createdAt)
+	host.UpdatedAt = fromMicros(updatedAt)
+	return &host, nil
+}
+
+func (r *Repository) SavePassthroughHost(ctx context.Context, host models.PassthroughHost) (uint64, error) {
+	res, err := r.db.ExecContext(ctx, AddPassthrough, host.Host, host.Enabled, toMicros(time.Now()))
+	if isUniqueViolation(err) {
+		return 0, fmt.Errorf("[repo] %w", &models.ErrPassthroughConflict{Host: host.Host})
+	} else if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save passthrough host: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func (r *Repository) UpdatePassthroughHost(ctx context.Context, host models.PassthroughHost) error {
+	err := execOne(ctx, r.db, &models.ErrPassthroughNotFound{}, UpdatePassthrough,
+		host.Id, host.Host, host.Enabled, toMicros(time.Now()))
+	if isUniqueViolation(err) {
+		return fmt.Errorf("[repo] %w", &models.ErrPassthroughConflict{Host: host.Host})
+	}
+	return err
+}
+
+func (r *Repository) DeletePassthroughHost(ctx context.Context, id uint64) error {
+	return execOne(ctx, r.db, &models.ErrPassthroughNotFound{}, DeletePassthrough, id)
+}
+
+func (r *Repository) RecordHandshakeFailure(ctx context.Context, host, reason string) error {
+	if _, err := r.db.ExecContext(ctx, LearnPassthrough, host, reason, toMicros(time.Now())); err != nil {
+		return fmt.Errorf("[repo] failed to record handshake failure: %w", err)
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	projectColumns = `id, name, description, active, archived, created_at`
+
+	ProjectsAll      = `SELECT ` + projectColumns + ` FROM project WHERE ?1 OR NOT archived ORDER BY id`
+	ProjectById      = `SELECT ` + projectColumns + ` FROM project WHERE id=?`
+	ProjectActive    = `SELECT ` + projectColumns + ` FROM project WHERE active`
+	AddProject       = `INSERT INTO project (name, description, created_at) VALUES (?, ?, ?)`
+	DeactivateOthers = `UPDATE project SET active = 0 WHERE active AND id <> ?`
+	ActivateProject  = `UPDATE project SET active = 1 WHERE id=?`
+	ArchiveProject   = `UPDATE project SET archived = ?2 WHERE id=?1`
+	DeleteProject    = `DELETE FROM project WHERE id=?`
+)
+
+func (r *Repository) ListProjects(ctx context.Context, archived bool) ([]models.Project, error) {
+	rows, err := r.db.QueryContext(ctx, ProjectsAll, archived)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, *project)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return projects, nil
+}
+
+func (r *Repository) GetProject(ctx context.Context, id uint64) (*models.Project, error) {
+	return r.getProject(ctx, ProjectById, id)
+}
+
+func (r *Repository) GetActiveProject(ctx context.Context) (*models.Project, error) {
+	return r.getProject(ctx, ProjectActive)
+}
+
+func (r *Repository) getProject(ctx context.Context, query string, args ...any) (*models.Project, error) {
+	project, err := scanProject(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrProjectNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query project: %w", err)
+	}
+	return project, nil
+}
+
+func scanProject(row row) (*models.Project, error) {
+	var project models.Project
+	var createdAt int64
+	if err := row.Scan(
+		&project.Id,
+		&project.Name,
+		&project.Description,
+		&project.Active,
+		&project.Archived,
+		&createdAt,
+	); err != nil {
+		return nil, err
+	}
+	project.CreatedAt = fromMicros(createdAt)
+	return &project, nil
+}
+
+func (r *Repository) SaveProject(ctx context.Context, project models.Project) (uint64, error) {
+	res, err := r.db.ExecContext(ctx, AddProject, project.Name, project.Description, toMicros(time.Now()))
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return 0, fmt.Errorf("[repo] %w", &models.ErrProjectConflict{Reason: fmt.Sprintf("name %q is taken", project.Name)})
+	} else if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save project: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// SetActiveProject makes id the one active project.
+func (r *Repository) SetActiveProject(ctx context.Context, id uint64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, DeactivateOthers, id); err != nil {
+		return fmt.Errorf("[repo] failed to deactivate projects: %w", err)
+	}
+	if err := execProject(ctx, tx, ActivateProject, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("[repo] failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) SetProjectArchived(ctx context.Context, id uint64, archived bool) error {
+	return execProject(ctx, r.db, ArchiveProject, id, archived)
+}
+
+// DeleteProject removes the project with everything captured in it.
+func (r *Repository) DeleteProject(ctx context.Context, id uint64) error {
+	return execProject(ctx, r.db, DeleteProject, id)
+}
+
+func execProject(ctx context.Context, db execer, query string, args ...any) error {
+	return execOne(ctx, db, &models.ErrProjectNotFound{}, query, args...)
+}
+
+// execOne runs a statement meant to change one row, notFound is returned
+// when it changed none.
+func execOne(ctx context.Context, db execer, notFound error, query string, args ...any) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("[repo] failed to update: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("[repo] %w", notFound)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/models"
+)
+
+const (
+	requestColumns = `req.id, req.project_id, req.method, req.host, req.path, req.headers, req.query_params, req.post_params, req.cookies, req.body, COALESCE(req.decoded_body, ''), req.encodings, req.mime_type, req.body_size, req.body_truncated, req.proto, req.created_at, req.fired_rules, req.scheme`
+
+	RequestById = `SELECT ` + requestColumns + ` FROM request req WHERE req.id=?1 AND req.project_id=?2`
+	AddRequest  = `INSERT INTO request (project_id, method, host, path, headers, query_params, post_params, cookies, body, decoded_body, encodings, mime_type, body_size, body_truncated, proto, created_at, fired_rules, scheme) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	AddResponse = `INSERT INTO response (request_id, status_code, headers, body, decoded_body, encodings, mime_type, body_size, body_truncated, proto, wait_ms, duration_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+)
+
+// execer is a *sql.DB or a *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ListRequests returns one page of the history matching filter, ordered by
+// the requested sort key with the id as tie breaker.
+func (r *Repository) ListRequests(ctx context.Context, filter models.RequestFilter) (*models.RequestPage, error) {
+	filter, err := repository.PageFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := buildRequestQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query requests: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.RequestPage{Requests: []models.Request{}}
+	var lastKey string
+	for rows.Next() {
+		var sortKey string
+		request, err := scanRequest(rows, &sortKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Requests) == filter.Limit {
+			// One row past the page only tells that there is more.
+			page.NextCursor = repository.EncodeCursor(repository.Cursor{
+				Sort: filter.Sort,
+				Desc: filter.Desc,
+				Key:  lastKey,
+				Id:   page.Requests[len(page.Requests)-1].Id,
+			})
+			break
+		}
+		page.Requests = append(page.Requests, *request)
+		lastKey = sortKey
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+
+	return page, nil
+}
+
+func (r *Repository) GetRequestById(ctx context.Context, projectId, id uint64) (*models.Request, error) {
+	req, err := scanRequest(r.db.QueryRowContext(ctx, RequestById, id, projectId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrRequestNotFuound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed request db %w", err)
+	}
+
+	return req, nil
+}
+
+// row is a *sql.Row or *sql.Rows.
+type row interface {
+	Scan(dest ...any) error
+}
+
+// scanRequest reads a row made of requestColumns followed by extra.
+func scanRequest(row row, extra ...any) (*models.Request, error) {
+	var req models.Request
+
+	var rawHeaders []byte
+	var rawCookies []byte
+	var rawGetParams []byte
+	var rawPostParams []byte
+	var rawEncodings []byte
+	var rawFiredRules []byte
+	var createdAt int64
+
+	dest := []any{
+		&req.Id,
+		&req.ProjectId,
+		&req.Method,
+		&req.Host,
+		&req.Path,
+		&rawHeaders,
+		&rawGetParams,
+		&rawPostParams,
+		&rawCookies,
+		&req.Body,
+		&req.DecodedBody,
+		&rawEncodings,
+		&req.MimeType,
+		&req.BodySize,
+		&req.Truncated,
+		&req.Proto,
+		&createdAt,
+		&rawFiredRules,
+		&req.Scheme,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	req.CreatedAt = fromMicros(createdAt)
+
+	if err := unmarshalColumn(rawHeaders, &req.Headers); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawGetParams, &req.Get_Params); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawPostParams, &req.Post_Params); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawCookies, &req.Cookies); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawEncodings, &req.Encodings); err != nil {
+		return nil, err
+	}
+	if err := unmarshalColumn(rawFiredRules, &req.FiredRules); err != nil {
+		return nil, err
+	}
+	req.FiredRules = firedRules(req)
+
+	return &req, nil
+}
+
+// firedRules returns the rules that fired for request, an empty list
+// rather than nil when none did.
+func firedRules(request models.Request) []uint64 {
+	if request.FiredRules == nil {
+		return []uint64{}
+	}
+	return request.FiredRules
+}
+
+// unmarshalColumn decodes a JSON column, leaving v untouched for NULL.
+func unmarshalColumn(raw []byte, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (r *Repository) SaveRequest(ctx context.Context, request models.Request) (uint64, error) {
+	return insertRequest(ctx, r.db, request, time.Now())
+}
+
+func (r *Repository) SaveResponse(ctx context.Context, response models.Response) error {
+	return insertResponse(ctx, r.db, response, time.Now())
+}
+
+// insertRequest adds a request created at createdAt and returns its id.
+func insertRequest(ctx context.Context, db execer, request models.Request, createdAt time.Time) (uint64, error) {
+	rawHeaders, err := json.Marshal(&request.Headers)
+	if err != nil {
+		return 0, err
+	}
+	rawCookies, err := json.Marshal(&request.Cookies)
+	if err != nil {
+		return 0, err
+	}
+	rawQuery, err := json.Marshal(&request.Get_Params)
+	if err != nil {
+		return 0, err
+	}
+	rawPostParams, err := json.Marshal(&request.Post_Params)
+	if err != nil {
+		return 0, err
+	}
+	rawEncodings, err := json.Marshal(&request.Encodings)
+	if err != nil {
+		return 0, err
+	}
+	rawFiredRules, err := json.Marshal(firedRules(request))
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := db.ExecContext(ctx, AddRequest,
+		request.ProjectId,
+		request.Method,
+		request.Host,
+		request.Path,
+		string(rawHeaders),
+		string(rawQuery),
+		string(rawPostParams),
+		string(rawCookies),
+		request.Body,
+		request.DecodedBody,
+		string(rawEncodings),
+		request.MimeType,
+		request.BodySize,
+		request.Truncated,
+		request.Proto,
+		toMicros(createdAt),
+		string(rawFiredRules),
+		defaultScheme(request.Scheme),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save request: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func insertResponse(ctx context.Context, db execer, response models.Response, createdAt time.Time) error {
+	rawHeaders, err := json.Marshal(&response.Headers)
+	if err != nil {
+		return err
+	}
+	rawEncodings, err := json.Marshal(&response.Encodings)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, AddResponse,
+		response.RequestId,
+		response.Code,
+		string(rawHeaders),
+		response.Body,
+		response.DecodedBody,
+		string(rawEncodings),
+		response.MimeType,
+		response.BodySize,
+		response.Truncated,
+		response.Proto,
+		response.WaitMs,
+		response.DurationMs,
+		toMicros(createdAt),
+	); err != nil {
+		return fmt.Errorf("[repo] failed to save response: %w", err)
+	}
+	return nil
+}
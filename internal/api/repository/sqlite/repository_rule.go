@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+const (
+	ruleColumns = `id, name, enabled, target, host, "match", "replace", regex, created_at, updated_at`
+
+	RulesAll   = `SELECT ` + ruleColumns + ` FROM rule ORDER BY id`
+	RuleById   = `SELECT ` + ruleColumns + ` FROM rule WHERE id=?1`
+	AddRule    = `INSERT INTO rule (name, enabled, target, host, "match", "replace", regex, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?8)`
+	UpdateRule = `UPDATE rule SET name=?2, enabled=?3, target=?4, host=?5, "match"=?6, "replace"=?7, regex=?8, updated_at=?9 WHERE id=?1`
+	DeleteRule = `DELETE FROM rule WHERE id=?1`
+)
+
+func (r *Repository) ListRules(ctx context.Context) ([]models.Rule, error) {
+	rows, err := r.db.QueryContext(ctx, RulesAll)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.Rule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return rules, nil
+}
+
+func (r *Repository) GetRule(ctx context.Context, id uint64) (*models.Rule, error) {
+	rule, err := scanRule(r.db.QueryRowContext(ctx, RuleById, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrRuleNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query rule: %w", err)
+	}
+	return rule, nil
+}
+
+func scanRule(row row) (*models.Rule, error) {
+	var rule models.Rule
+	var createdAt, updatedAt int64
+	if err := row.Scan(
+		&rule.Id,
+		&rule.Name,
+		&rule.Enabled,
+		&rule.Target,
+		&rule.Host,
+		&rule.Match,
+		&rule.Replace,
+		&rule.Regex,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	rule.CreatedAt = fromMicros(createdAt)
+	rule.UpdatedAt = fromMicros(updatedAt)
+	return &rule, nil
+}
+
+func (r *Repository) SaveRule(ctx context.Context, rule models.Rule) (uint64, error) {
+	res, err := r.db.ExecContext(ctx, AddRule,
+		rule.Name, rule.Enabled, rule.Target, rule.Host, rule.Match, rule.Replace, rule.Regex, toMicros(time.Now()))
+	if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save rule: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func (r *Repository) UpdateRule(ctx context.Context, rule models.Rule) error {
+	return execOne(ctx, r.db, &models.ErrRuleNotFound{}, UpdateRule,
+		rule.Id, rule.Name, rule.Enabled, rule.Target, rule.Host, rule.Match, rule.Replace, rule.Regex, toMicros(time.Now()))
+}
+
+func (r *Repository) DeleteRule(ctx context.Context, id uint64) error {
+	return execOne(ctx, r.db, &models.ErrRuleNotFound{}, DeleteRule, id)
+}
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+const (
+	// A zero request id lists every scan of the project.
+	ScanResultsAll = `SELECT id, project_id, request_id, hidden_params, created_at FROM scan_result
+		WHERE project_id=?1 AND (?2 = 0 OR request_id=?2) ORDER BY id`
+	AddScanResult = `INSERT INTO scan_result (project_id, request_id, hidden_params, created_at) VALUES (?, ?, ?, ?)`
+)
+
+func (r *Repository) ListScanResults(ctx context.Context, projectId, requestId uint64) ([]models.ScanResult, error) {
+	rows, err := r.db.QueryContext(ctx, ScanResultsAll, projectId, requestId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query scan results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.ScanResult{}
+	for rows.Next() {
+		var result models.ScanResult
+		var rawParams []byte
+		var createdAt int64
+		if err := rows.Scan(
+			&result.Id,
+			&result.ProjectId,
+			&result.RequestId,
+			&rawParams,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		result.CreatedAt = fromMicros(createdAt)
+		if err := json.Unmarshal(rawParams, &result.HiddenParams); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return results, nil
+}
+
+func (r *Repository) SaveScanResult(ctx context.Context, result models.ScanResult) (uint64, error) {
+	if result.HiddenParams == nil {
+		result.HiddenParams = []string{}
+	}
+	rawParams, err := json.Marshal(result.HiddenParams)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.db.ExecContext(ctx, AddScanResult, result.ProjectId, result.RequestId, string(rawParams), toMicros(time.Now()))
+	if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save scan result: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
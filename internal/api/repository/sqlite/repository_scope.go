@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"proxy/internal/models"
+)
+
+const (
+	scopeRuleColumns = `id, project_id, kind, scheme, host, port, path, created_at, updated_at`
+
+	ScopeRulesAll   = `SELECT ` + scopeRuleColumns + ` FROM scope_rule WHERE project_id=?1 ORDER BY id`
+	ScopeRuleById   = `SELECT ` + scopeRuleColumns + ` FROM scope_rule WHERE id=?1 AND project_id=?2`
+	AddScopeRule    = `INSERT INTO scope_rule (project_id, kind, scheme, host, port, path, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7)`
+	UpdateScopeRule = `UPDATE scope_rule SET kind=?3, scheme=?4, host=?5, port=?6, path=?7, updated_at=?8 WHERE id=?1 AND project_id=?2`
+	DeleteScopeRule = `DELETE FROM scope_rule WHERE id=?1 AND project_id=?2`
+)
+
+func (r *Repository) ListScopeRules(ctx context.Context, projectId uint64) ([]models.ScopeRule, error) {
+	rows, err := r.db.QueryContext(ctx, ScopeRulesAll, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query scope rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.ScopeRule{}
+	for rows.Next() {
+		rule, err := scanScopeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return rules, nil
+}
+
+func (r *Repository) GetScopeRule(ctx context.Context, projectId, id uint64) (*models.ScopeRule, error) {
+	rule, err := scanScopeRule(r.db.QueryRowContext(ctx, ScopeRuleById, id, projectId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("[repo] %w, %w", &models.ErrScopeRuleNotFound{}, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query scope rule: %w", err)
+	}
+	return rule, nil
+}
+
+func scanScopeRule(row row) (*models.ScopeRule, error) {
+	var rule models.ScopeRule
+	var createdAt, updatedAt int64
+	if err := row.Scan(
+		&rule.Id,
+		&rule.ProjectId,
+		&rule.Kind,
+		&rule.Scheme,
+		&rule.Host,
+		&rule.Port,
+		&rule.Path,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	rule.CreatedAt = fromMicros(createdAt)
+	rule.UpdatedAt = fromMicros(updatedAt)
+	return &rule, nil
+}
+
+func (r *Repository) SaveScopeRule(ctx context.Context, rule models.ScopeRule) (uint64, error) {
+	res, err := r.db.ExecContext(ctx, AddScopeRule,
+		rule.ProjectId, rule.Kind, rule.Scheme, rule.Host, rule.Port, rule.Path, toMicros(time.Now()))
+	if err != nil {
+		return 0, fmt.Errorf("[repo] failed to save scope rule: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func (r *Repository) UpdateScopeRule(ctx context.Context, rule models.ScopeRule) error {
+	return execOne(ctx, r.db, &models.ErrScopeRuleNotFound{}, UpdateScopeRule,
+		rule.Id, rule.ProjectId, rule.Kind, rule.Scheme, rule.Host, rule.Port, rule.Path, toMicros(time.Now()))
+}
+
+func (r *Repository) DeleteScopeRule(ctx context.Context, projectId, id uint64) error {
+	return execOne(ctx, r.db, &models.ErrScopeRuleNotFound{}, DeleteScopeRule, id, projectId)
+}
@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"proxy/internal/models"
+)
+
+// snippetContext is how many characters around a match Search returns.
+const snippetContext = 60
+
+// SearchExchanges runs a case-insensitive substring match over every
+// searchable field. SQLite's lower() only folds ASCII letters, the same
+// goes for the match. Snippet holds a window around the first match of
+// each field.
+const SearchExchanges = `WITH hits AS (
+		SELECT req.id AS request_id, 'req.path' AS field, req.path AS content, req.created_at AS created_at
+			FROM request req WHERE req.project_id = ?4 AND instr(lower(req.path), lower(?1)) > 0
+		UNION ALL
+		SELECT req.id, 'req.query', req.query_params, req.created_at
+			FROM request req WHERE req.project_id = ?4 AND instr(lower(req.query_params), lower(?1)) > 0
+		UNION ALL
+		SELECT req.id, 'req.header', req.headers, req.created_at
+			FROM request req WHERE req.project_id = ?4 AND instr(lower(req.headers), lower(?1)) > 0
+		UNION ALL
+		SELECT req.id, 'req.body', req.decoded_body, req.created_at
+			FROM request req WHERE req.project_id = ?4 AND instr(lower(req.decoded_body), lower(?1)) > 0
+		UNION ALL
+		SELECT req.id, 'resp.header', resp.headers, req.created_at
+			FROM response resp JOIN request req ON req.id = resp.request_id WHERE req.project_id = ?4 AND instr(lower(resp.headers), lower(?1)) > 0
+		UNION ALL
+		SELECT req.id, 'resp.body', resp.decoded_body, req.created_at
+			FROM response resp JOIN request req ON req.id = resp.request_id WHERE req.project_id = ?4 AND instr(lower(resp.decoded_body), lower(?1)) > 0
+	)
+	SELECT request_id, field, created_at,
+		substr(content, max(instr(lower(content), lower(?1)) - ?2, 1), length(?1) + 2 * ?2)
+	FROM hits ORDER BY created_at, request_id, field LIMIT ?3`
+
+func (r *Repository) Search(ctx context.Context, projectId uint64, text string, limit int) ([]models.SearchResult, error) {
+	rows, err := r.db.QueryContext(ctx, SearchExchanges, text, snippetContext, limit, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var res models.SearchResult
+		var createdAt int64
+		if err := rows.Scan(&res.RequestId, &res.Field, &createdAt, &res.Snippet); err != nil {
+			return nil, err
+		}
+		res.CreatedAt = fromMicros(createdAt)
+		results = append(results, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+
+	return results, nil
+}
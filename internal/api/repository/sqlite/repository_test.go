@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/api/repository/conformance"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestConformance(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	conformance.Run(t, func(t *testing.T) repository.Repository {
+		db, err := Open(context.Background(), filepath.Join(t.TempDir(), "proxy.db"), log)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return NewRepository(db, log)
+	})
+}
@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"proxy/internal/models"
+)
+
+const (
+	WebSocketsAll = `SELECT r.id, r.host, r.path, count(m.id), r.created_at
+		FROM request r JOIN websocket_message m ON m.request_id = r.id
+		WHERE r.project_id=?
+		GROUP BY r.id ORDER BY r.created_at, r.id`
+	WebSocketMessages = `SELECT m.id, m.request_id, m.direction, m.opcode, m.payload, m.created_at
+		FROM websocket_message m JOIN request r ON r.id = m.request_id
+		WHERE m.request_id=?1 AND r.project_id=?2 ORDER BY m.id`
+	AddWebSocketMessage = `INSERT INTO websocket_message (request_id, direction, opcode, payload, created_at) VALUES (?, ?, ?, ?, ?)`
+)
+
+func (r *Repository) GetWebSockets(ctx context.Context, projectId uint64) ([]models.WebSocket, error) {
+	rows, err := r.db.QueryContext(ctx, WebSocketsAll, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query websockets: %w", err)
+	}
+	defer rows.Close()
+
+	var sockets []models.WebSocket
+	for rows.Next() {
+		var ws models.WebSocket
+		var createdAt int64
+		if err := rows.Scan(&ws.RequestId, &ws.Host, &ws.Path, &ws.MessageCount, &createdAt); err != nil {
+			return nil, err
+		}
+		ws.CreatedAt = fromMicros(createdAt)
+		sockets = append(sockets, ws)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+
+	if len(sockets) == 0 {
+		return nil, fmt.Errorf("[repo] Error no websockets found: %w", &models.ErrRequestNotFuound{})
+	}
+	return sockets, nil
+}
+
+func (r *Repository) GetWebSocketMessages(ctx context.Context, projectId, requestId uint64) ([]models.WebSocketMessage, error) {
+	rows, err := r.db.QueryContext(ctx, WebSocketMessages, requestId, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("[repo] failed to query websocket messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.WebSocketMessage{}
+	for rows.Next() {
+		var msg models.WebSocketMessage
+		var createdAt int64
+		if err := rows.Scan(
+			&msg.Id,
+			&msg.RequestId,
+			&msg.Direction,
+			&msg.Opcode,
+			&msg.Payload,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		msg.CreatedAt = fromMicros(createdAt)
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[repo] Error rows error: %v", err)
+	}
+	return messages, nil
+}
+
+func (r *Repository) SaveWebSocketMessage(ctx context.Context, message models.WebSocketMessage) error {
+	if _, err := r.db.ExecContext(ctx, AddWebSocketMessage,
+		message.RequestId,
+		message.Direction,
+		message.Opcode,
+		message.Payload,
+		toMicros(message.CreatedAt),
+	); err != nil {
+		return fmt.Errorf("[repo] failed to save websocket message: %w", err)
+	}
+	return nil
+}
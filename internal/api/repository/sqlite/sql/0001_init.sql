@@ -0,0 +1,53 @@
+-- Times are microseconds since the Unix epoch, which keeps them sortable
+-- and as precise as the postgres backend.
+CREATE TABLE request (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	method			TEXT									NOT NULL,
+	"host"			TEXT									NOT NULL,
+	"path"			TEXT									NOT NULL,
+	headers			TEXT,
+	query_params	TEXT,
+	post_params		TEXT,
+	cookies			TEXT,
+	body			BLOB,
+	decoded_body	TEXT,
+	encodings		TEXT,
+	mime_type		TEXT		DEFAULT ''					NOT NULL,
+	body_size		INTEGER		DEFAULT 0					NOT NULL,
+	body_truncated	INTEGER		DEFAULT 0					NOT NULL,
+	proto			TEXT		DEFAULT 'HTTP/1.1'			NOT NULL,
+	created_at		INTEGER									NOT NULL
+);
+
+CREATE TABLE response (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	request_id		INTEGER									NOT NULL,
+	status_code		INTEGER									NOT NULL,
+	headers			TEXT,
+	body			BLOB,
+	decoded_body	TEXT,
+	encodings		TEXT,
+	mime_type		TEXT		DEFAULT ''					NOT NULL,
+	body_size		INTEGER		DEFAULT 0					NOT NULL,
+	body_truncated	INTEGER		DEFAULT 0					NOT NULL,
+	proto			TEXT		DEFAULT 'HTTP/1.1'			NOT NULL,
+	wait_ms			INTEGER,
+	duration_ms		INTEGER,
+	created_at		INTEGER									NOT NULL,
+	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
+);
+
+CREATE TABLE websocket_message (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	request_id		INTEGER									NOT NULL,
+	direction		TEXT		CHECK(direction IN ('client', 'server')) NOT NULL,
+	opcode			INTEGER									NOT NULL,
+	payload			BLOB,
+	created_at		INTEGER									NOT NULL,
+	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
+);
+
+CREATE INDEX request_created_at_id_idx ON request (created_at, id);
+CREATE INDEX request_host_idx ON request (lower("host"));
+CREATE INDEX response_request_id_idx ON response (request_id);
+CREATE INDEX websocket_message_request_id_idx ON websocket_message (request_id);
@@ -0,0 +1,44 @@
+CREATE TABLE project (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	name			TEXT		CHECK(length(name) < 200)	NOT NULL	UNIQUE,
+	description		TEXT		DEFAULT ''					NOT NULL,
+	active			INTEGER		DEFAULT 0					NOT NULL,
+	archived		INTEGER		DEFAULT 0					NOT NULL,
+	created_at		INTEGER									NOT NULL
+);
+
+-- The proxy captures into the one active project.
+CREATE UNIQUE INDEX project_active_idx ON project (active) WHERE active;
+
+-- Everything captured so far becomes the default project.
+INSERT INTO project (name, active, created_at) VALUES ('default', 1, CAST(unixepoch('subsec') * 1000000 AS INTEGER));
+
+-- SQLite can't add a NOT NULL column without a default, every insert sets it.
+ALTER TABLE request ADD COLUMN project_id INTEGER REFERENCES project(id) ON DELETE CASCADE;
+UPDATE request SET project_id = (SELECT id FROM project WHERE active);
+
+DROP INDEX request_created_at_id_idx;
+CREATE INDEX request_project_created_at_id_idx ON request (project_id, created_at, id);
+
+CREATE TABLE note (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	project_id		INTEGER									NOT NULL,
+	request_id		INTEGER,
+	"text"			TEXT									NOT NULL,
+	created_at		INTEGER									NOT NULL,
+	updated_at		INTEGER									NOT NULL,
+	FOREIGN KEY (project_id) REFERENCES project(id) ON DELETE CASCADE,
+	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
+);
+CREATE INDEX note_project_request_idx ON note (project_id, request_id);
+
+CREATE TABLE scan_result (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	project_id		INTEGER									NOT NULL,
+	request_id		INTEGER									NOT NULL,
+	hidden_params	TEXT									NOT NULL,
+	created_at		INTEGER									NOT NULL,
+	FOREIGN KEY (project_id) REFERENCES project(id) ON DELETE CASCADE,
+	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
+);
+CREATE INDEX scan_result_project_request_idx ON scan_result (project_id, request_id);
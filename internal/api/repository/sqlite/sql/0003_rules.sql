@@ -0,0 +1,15 @@
+CREATE TABLE rule (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	name			TEXT		DEFAULT ''					NOT NULL,
+	enabled			INTEGER		DEFAULT 1					NOT NULL,
+	target			TEXT									NOT NULL,
+	host			TEXT		DEFAULT ''					NOT NULL,
+	"match"			TEXT		DEFAULT ''					NOT NULL,
+	"replace"		TEXT		DEFAULT ''					NOT NULL,
+	regex			INTEGER		DEFAULT 0					NOT NULL,
+	created_at		INTEGER									NOT NULL,
+	updated_at		INTEGER									NOT NULL
+);
+
+-- Ids of the rules that rewrote an exchange.
+ALTER TABLE request ADD COLUMN fired_rules TEXT DEFAULT '[]' NOT NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE scope_rule (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	project_id		INTEGER									NOT NULL,
+	kind			TEXT									NOT NULL,
+	scheme			TEXT		DEFAULT ''					NOT NULL,
+	host			TEXT		DEFAULT ''					NOT NULL,
+	port			INTEGER		DEFAULT 0					NOT NULL,
+	path			TEXT		DEFAULT ''					NOT NULL,
+	created_at		INTEGER									NOT NULL,
+	updated_at		INTEGER									NOT NULL,
+	FOREIGN KEY (project_id) REFERENCES project(id) ON DELETE CASCADE
+);
+CREATE INDEX scope_rule_project_idx ON scope_rule (project_id);
@@ -0,0 +1,10 @@
+CREATE TABLE passthrough (
+	id				INTEGER		PRIMARY KEY AUTOINCREMENT	NOT NULL,
+	host			TEXT									NOT NULL	UNIQUE,
+	enabled			INTEGER		DEFAULT 1					NOT NULL,
+	learned			INTEGER		DEFAULT 0					NOT NULL,
+	failures		INTEGER		DEFAULT 0					NOT NULL,
+	last_error		TEXT		DEFAULT ''					NOT NULL,
+	created_at		INTEGER									NOT NULL,
+	updated_at		INTEGER									NOT NULL
+);
@@ -2,16 +2,76 @@ package usecase
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"proxy/internal/models"
+	"proxy/pkg/har"
+	"proxy/pkg/scope"
 )
 
 type Usecase interface {
-	GetAllRequests(ctx context.Context) ([]models.Request, error)
-	GetRequestById(ctx context.Context, id uint64) (*models.Request, error)
-	RepeatRequest(ctx context.Context, id uint64) (*http.Request, error)
+	ListRequests(ctx context.Context, filter models.RequestFilter) (*models.RequestPage, error)
+	GetRequestById(ctx context.Context, projectId, id uint64) (*models.Request, error)
+	GetExchange(ctx context.Context, projectId, id uint64) (*models.Exchange, error)
+	GetResponseById(ctx context.Context, projectId, id uint64) (*models.Response, error)
+	GetResponseByRequestId(ctx context.Context, projectId, requestId uint64) (*models.Response, error)
+	GetRequestBody(ctx context.Context, projectId, id uint64, decoded bool) (*models.Body, error)
+	GetResponseBody(ctx context.Context, projectId, id uint64, decoded bool) (*models.Body, error)
+	RepeatRequest(ctx context.Context, projectId, id uint64) (*http.Request, error)
+	SendRequest(req *http.Request) (*http.Response, error)
 	ScanRequest(ctx context.Context, param string, request *models.Request) (string, error)
 
+	Search(ctx context.Context, projectId uint64, text string, limit int) ([]models.SearchResult, error)
+
 	SaveRequest(ctx context.Context, request models.Request) (uint64, error)
 	SaveResponse(ctx context.Context, response models.Response) error
+	SaveExchanges(ctx context.Context, exchanges []models.Exchange) error
+
+	ExportHAR(ctx context.Context, filter models.RequestFilter) (*har.HAR, error)
+	ImportHAR(ctx context.Context, projectId uint64, archive *har.HAR) (int, error)
+	ExportJSONL(ctx context.Context, filter models.RequestFilter, w io.Writer) (int, error)
+	ImportJSONL(ctx context.Context, projectId uint64, r io.Reader) (*models.ImportResult, error)
+
+	GetWebSockets(ctx context.Context, projectId uint64) ([]models.WebSocket, error)
+	GetWebSocketMessages(ctx context.Context, projectId, requestId uint64) ([]models.WebSocketMessage, error)
+	SaveWebSocketMessage(ctx context.Context, message models.WebSocketMessage) error
+
+	ActiveProject(ctx context.Context) (uint64, error)
+	ListProjects(ctx context.Context, archived bool) ([]models.Project, error)
+	GetProject(ctx context.Context, id uint64) (*models.Project, error)
+	CreateProject(ctx context.Context, project models.Project) (*models.Project, error)
+	ActivateProject(ctx context.Context, id uint64) error
+	ArchiveProject(ctx context.Context, id uint64, archived bool) error
+	DeleteProject(ctx context.Context, id uint64) error
+
+	ListNotes(ctx context.Context, projectId, requestId uint64) ([]models.Note, error)
+	GetNote(ctx context.Context, projectId, id uint64) (*models.Note, error)
+	CreateNote(ctx context.Context, note models.Note) (*models.Note, error)
+	UpdateNote(ctx context.Context, note models.Note) (*models.Note, error)
+	DeleteNote(ctx context.Context, projectId, id uint64) error
+
+	ListScanResults(ctx context.Context, projectId, requestId uint64) ([]models.ScanResult, error)
+	SaveScanResult(ctx context.Context, result models.ScanResult) (uint64, error)
+
+	ListRules(ctx context.Context) ([]models.Rule, error)
+	GetRule(ctx context.Context, id uint64) (*models.Rule, error)
+	CreateRule(ctx context.Context, rule models.Rule) (*models.Rule, error)
+	UpdateRule(ctx context.Context, rule models.Rule) (*models.Rule, error)
+	DeleteRule(ctx context.Context, id uint64) error
+	EnabledRules(ctx context.Context) ([]models.Rule, error)
+
+	ListScopeRules(ctx context.Context, projectId uint64) ([]models.ScopeRule, error)
+	GetScopeRule(ctx context.Context, projectId, id uint64) (*models.ScopeRule, error)
+	CreateScopeRule(ctx context.Context, rule models.ScopeRule) (*models.ScopeRule, error)
+	UpdateScopeRule(ctx context.Context, rule models.ScopeRule) (*models.ScopeRule, error)
+	DeleteScopeRule(ctx context.Context, projectId, id uint64) error
+	ActiveScope(ctx context.Context) (*scope.Scope, error)
+
+	ListPassthroughHosts(ctx context.Context) ([]models.PassthroughHost, error)
+	GetPassthroughHost(ctx context.Context, id uint64) (*models.PassthroughHost, error)
+	CreatePassthroughHost(ctx context.Context, host models.PassthroughHost) (*models.PassthroughHost, error)
+	UpdatePassthroughHost(ctx context.Context, host models.PassthroughHost) (*models.PassthroughHost, error)
+	DeletePassthroughHost(ctx context.Context, id uint64) error
+	RecordHandshakeFailure(ctx context.Context, host, reason string) error
+	PassthroughHosts(ctx context.Context) ([]string, error)
 }
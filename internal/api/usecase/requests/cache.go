@@ -0,0 +1,50 @@
+package requests
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheTTL is how long settings the proxy consults on every exchange are
+// taken as known: the active project, its scope, the enabled rules and
+// passthrough hosts. Changes made through this usecase apply right away,
+// those made by another process sharing the database once it passes.
+const cacheTTL = time.Second
+
+// ttlCache keeps values loaded from the repository for cacheTTL.
+type ttlCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// get returns the value cached for key, calling load when there is none
+// or it expired. Errors are not cached.
+func (c *ttlCache[K, V]) get(key K, load func() (V, error)) (V, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+		return e.value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if c.entries == nil {
+		c.entries = make(map[K]ttlEntry[V])
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: time.Now().Add(cacheTTL)}
+	return value, nil
+}
+
+func (c *ttlCache[K, V]) forget(key K) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
@@ -0,0 +1,41 @@
+package requests
+
+import (
+	"context"
+
+	"proxy/internal/models"
+
+	reqUtils "proxy/pkg/http"
+)
+
+// maxDecodedBody bounds what a stored body may inflate to when served.
+const maxDecodedBody = 64 << 20
+
+func (u *Usecase) GetRequestBody(ctx context.Context, projectId, id uint64, decoded bool) (*models.Body, error) {
+	body, err := u.Repo.GetRequestBody(ctx, projectId, id)
+	if err != nil {
+		return nil, err
+	}
+	if decoded {
+		decodeBody(body)
+	}
+	return body, nil
+}
+
+func (u *Usecase) GetResponseBody(ctx context.Context, projectId, id uint64, decoded bool) (*models.Body, error) {
+	body, err := u.Repo.GetResponseBody(ctx, projectId, id)
+	if err != nil {
+		return nil, err
+	}
+	if decoded {
+		decodeBody(body)
+	}
+	return body, nil
+}
+
+// decodeBody replaces the raw bytes with their decoded form. Bodies that
+// only partially decode, e.g. because the capture was truncated, keep
+// whatever came out.
+func decodeBody(body *models.Body) {
+	body.Content, _, _ = reqUtils.DecodeBody(body.Content, body.Headers, maxDecodedBody)
+}
@@ -0,0 +1,43 @@
+package requests
+
+import (
+	"context"
+	"fmt"
+
+	"proxy/internal/models"
+	"proxy/pkg/har"
+)
+
+// ExportHAR archives every exchange of the history matching filter, in the
+// filter's order. Its limit and cursor are ignored, the export is never
+// paged.
+func (u *Usecase) ExportHAR(ctx context.Context, filter models.RequestFilter) (*har.HAR, error) {
+	entries := []har.Entry{}
+	err := u.eachExchange(ctx, filter, func(ex models.Exchange) error {
+		entries = append(entries, har.FromExchange(ex))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return har.New(entries), nil
+}
+
+// ImportHAR adds the entries of an archive to a project's history and
+// returns how many were stored. Nothing is stored if any entry is invalid.
+func (u *Usecase) ImportHAR(ctx context.Context, projectId uint64, archive *har.HAR) (int, error) {
+	exchanges := make([]models.Exchange, 0, len(archive.Log.Entries))
+	for i, entry := range archive.Log.Entries {
+		ex, err := har.ToExchange(entry)
+		if err != nil {
+			return 0, &models.ErrBadImport{At: fmt.Sprintf("entry %d", i+1), Reason: err.Error()}
+		}
+		ex.Request.ProjectId = projectId
+		exchanges = append(exchanges, ex)
+	}
+	if len(exchanges) == 0 {
+		return 0, nil
+	}
+
+	return savedCount(len(exchanges), u.Repo.SaveExchanges(ctx, exchanges))
+}
@@ -0,0 +1,212 @@
+package requests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/models"
+)
+
+const (
+	// importBatch is how many exchanges ImportJSONL stores at once.
+	importBatch = 500
+	// maxLineSize bounds one exchange of a JSON lines file.
+	maxLineSize = 256 << 20
+)
+
+// ExportJSONL writes every exchange of the history matching filter to w as
+// JSON lines, one models.Exchange per line, and returns how many there
+// were. Like ExportHAR it ignores the filter's limit and cursor.
+func (u *Usecase) ExportJSONL(ctx context.Context, filter models.RequestFilter, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	n := 0
+	err := u.eachExchange(ctx, filter, func(ex models.Exchange) error {
+		if err := enc.Encode(ex); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// ImportJSONL adds the exchanges of a JSON lines file, as written by
+// ExportJSONL, to a project's history. Ids in the file are dropped.
+// Exchanges already in the project, the same request sent at the same
+// time, are skipped, so importing a file twice adds it once.
+//
+// Exchanges are stored in batches as they are read, each checked against
+// the history with one lookup. A malformed line stops the import, those
+// before it stay imported.
+func (u *Usecase) ImportJSONL(ctx context.Context, projectId uint64, r io.Reader) (*models.ImportResult, error) {
+	result := &models.ImportResult{}
+	seen := make(map[[sha256.Size]byte]bool)
+	batch := make([]models.Exchange, 0, importBatch)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		fresh, err := u.dropExisting(ctx, projectId, batch)
+		if err != nil {
+			return err
+		}
+		result.Skipped += len(batch) - len(fresh)
+		if len(fresh) > 0 {
+			saved, err := savedCount(len(fresh), u.Repo.SaveExchanges(ctx, fresh))
+			if err != nil {
+				return err
+			}
+			result.Imported += saved
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		ex, err := parseExchange(scanner.Bytes())
+		if err != nil {
+			if err := flush(); err != nil {
+				return result, err
+			}
+			return result, &models.ErrBadImport{At: fmt.Sprintf("line %d", line), Reason: err.Error()}
+		}
+		ex.Request.ProjectId = projectId
+
+		key := exchangeKey(ex.Request)
+		if seen[key] {
+			result.Skipped++
+			continue
+		}
+		seen[key] = true
+
+		batch = append(batch, ex)
+		if len(batch) == importBatch {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err := flush(); err != nil {
+			return result, err
+		}
+		if errors.Is(err, bufio.ErrTooLong) {
+			return result, &models.ErrBadImport{At: fmt.Sprintf("line %d", line+1), Reason: "line too long"}
+		}
+		return result, err
+	}
+	return result, flush()
+}
+
+func parseExchange(line []byte) (models.Exchange, error) {
+	var ex models.Exchange
+	if err := json.Unmarshal(line, &ex); err != nil {
+		return ex, err
+	}
+
+	req := ex.Request
+	if req == nil {
+		return ex, fmt.Errorf("missing request")
+	}
+	if req.Method == "" || req.Host == "" {
+		return ex, fmt.Errorf("request without method or host")
+	}
+	if req.CreatedAt.IsZero() {
+		return ex, fmt.Errorf("request without created_at")
+	}
+	req.Id = 0
+	// Rule ids of the database the line came from mean nothing here.
+	req.FiredRules = nil
+	req.Method = strings.ToUpper(req.Method)
+	if req.Path == "" {
+		req.Path = "/"
+	}
+
+	if resp := ex.Response; resp != nil {
+		resp.Id = 0
+		resp.RequestId = 0
+		if resp.CreatedAt.IsZero() {
+			resp.CreatedAt = req.CreatedAt
+		}
+	}
+	return ex, nil
+}
+
+// exchangeKey identifies a request for deduplication. Times are compared
+// to the microsecond, which is what the databases keep.
+func exchangeKey(req *models.Request) [sha256.Size]byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%d\x00",
+		req.Method, req.Host, req.Path, url.Values(req.Get_Params).Encode(), req.CreatedAt.UnixMicro())
+	h.Write(req.Body)
+
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// dropExisting filters out of batch the exchanges the project already
+// holds. Exports come in time order, so the history is listed once over
+// the time span of the batch.
+func (u *Usecase) dropExisting(ctx context.Context, projectId uint64, batch []models.Exchange) ([]models.Exchange, error) {
+	from, to := batch[0].Request.CreatedAt, batch[0].Request.CreatedAt
+	for _, ex := range batch[1:] {
+		if at := ex.Request.CreatedAt; at.Before(from) {
+			from = at
+		} else if at.After(to) {
+			to = at
+		}
+	}
+	filter := models.RequestFilter{
+		ProjectId: projectId,
+		From:      from.Truncate(time.Microsecond),
+		To:        to.Truncate(time.Microsecond).Add(time.Microsecond),
+		Limit:     repository.MaxPageSize,
+	}
+
+	existing := make(map[[sha256.Size]byte]bool)
+	for {
+		page, err := u.Repo.ListRequests(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Requests {
+			existing[exchangeKey(&page.Requests[i])] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	fresh := batch[:0]
+	for _, ex := range batch {
+		if !existing[exchangeKey(ex.Request)] {
+			fresh = append(fresh, ex)
+		}
+	}
+	return fresh, nil
+}
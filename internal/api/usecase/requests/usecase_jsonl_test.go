@@ -0,0 +1,108 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"proxy/internal/api/repository"
+	"proxy/internal/api/repository/memory"
+	"proxy/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// countingRepo counts the history lookups an import makes.
+type countingRepo struct {
+	repository.Repository
+	lists int
+}
+
+func (r *countingRepo) ListRequests(ctx context.Context, filter models.RequestFilter) (*models.RequestPage, error) {
+	r.lists++
+	return r.Repository.ListRequests(ctx, filter)
+}
+
+func importFile(t *testing.T, u *Usecase, project uint64, name string) *models.ImportResult {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	result, err := u.ImportJSONL(context.Background(), project, f)
+	if err != nil {
+		t.Fatalf("ImportJSONL: %v", err)
+	}
+	return result
+}
+
+func TestImportJSONL(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	repo := &countingRepo{Repository: memory.NewRepository()}
+	u := NewUsecase(repo, log, nil)
+
+	project, err := u.ActiveProject(ctx)
+	if err != nil {
+		t.Fatalf("ActiveProject: %v", err)
+	}
+
+	result := importFile(t, u, project, "testdata/history.jsonl")
+	if result.Imported != 3 || result.Skipped != 1 {
+		t.Errorf("first import = %+v, want 3 imported and the repeated line skipped", *result)
+	}
+	if repo.lists != 1 {
+		t.Errorf("first import listed the history %d times, want once for its one batch", repo.lists)
+	}
+
+	result = importFile(t, u, project, "testdata/history.jsonl")
+	if result.Imported != 0 || result.Skipped != 4 {
+		t.Errorf("second import = %+v, want everything skipped", *result)
+	}
+
+	page, err := u.Repo.ListRequests(ctx, models.RequestFilter{ProjectId: project, Host: "api.example"})
+	if err != nil {
+		t.Fatalf("ListRequests: %v", err)
+	}
+	if len(page.Requests) != 2 {
+		t.Fatalf("imported %d api.example requests, want 2", len(page.Requests))
+	}
+	var login *models.Request
+	for i := range page.Requests {
+		if page.Requests[i].Path == "/login" {
+			login = &page.Requests[i]
+		}
+	}
+	if login == nil || login.Method != "POST" || string(login.Body) != "user=a" || len(login.FiredRules) != 0 {
+		t.Fatalf("imported login = %+v", login)
+	}
+	resp, err := u.Repo.GetResponseByRequestId(ctx, project, login.Id)
+	if err != nil {
+		t.Fatalf("GetResponseByRequestId: %v", err)
+	}
+	if resp.Code != 302 || resp.WaitMs != 12 || !resp.CreatedAt.Equal(login.CreatedAt) {
+		t.Errorf("imported login response = %+v", *resp)
+	}
+}
+
+func TestImportJSONLBadLine(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	u := NewUsecase(memory.NewRepository(), log, nil)
+
+	body := `{"request": {"method": "GET", "host": "a.example", "created_at": "2026-10-01T10:00:00Z"}}` + "\n" + `{"response": {"code": 200}}` + "\n"
+	result, err := u.ImportJSONL(context.Background(), 1, strings.NewReader(body))
+	var errBadImport *models.ErrBadImport
+	if !errors.As(err, &errBadImport) || errBadImport.At != "line 2" {
+		t.Fatalf("err = %v, want a bad import at line 2", err)
+	}
+	if result.Imported != 1 {
+		t.Errorf("imported %d before the bad line, want the line before it kept", result.Imported)
+	}
+}
@@ -0,0 +1,50 @@
+package requests
+
+import (
+	"context"
+
+	"proxy/internal/models"
+)
+
+// ListNotes returns the notes of a project, only those about requestId
+// unless it is zero.
+func (u *Usecase) ListNotes(ctx context.Context, projectId, requestId uint64) ([]models.Note, error) {
+	if requestId != 0 {
+		if _, err := u.Repo.GetRequestById(ctx, projectId, requestId); err != nil {
+			return nil, err
+		}
+	}
+	return u.Repo.ListNotes(ctx, projectId, requestId)
+}
+
+func (u *Usecase) GetNote(ctx context.Context, projectId, id uint64) (*models.Note, error) {
+	return u.Repo.GetNote(ctx, projectId, id)
+}
+
+// CreateNote adds a note to its project. A note about a request must be
+// about one of the project's.
+func (u *Usecase) CreateNote(ctx context.Context, note models.Note) (*models.Note, error) {
+	if note.RequestId != 0 {
+		if _, err := u.Repo.GetRequestById(ctx, note.ProjectId, note.RequestId); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := u.Repo.SaveNote(ctx, note)
+	if err != nil {
+		return nil, err
+	}
+	return u.Repo.GetNote(ctx, note.ProjectId, id)
+}
+
+// UpdateNote replaces the text of a note.
+func (u *Usecase) UpdateNote(ctx context.Context, note models.Note) (*models.Note, error) {
+	if err := u.Repo.UpdateNote(ctx, note); err != nil {
+		return nil, err
+	}
+	return u.Repo.GetNote(ctx, note.ProjectId, note.Id)
+}
+
+func (u *Usecase) DeleteNote(ctx context.Context, projectId, id uint64) error {
+	return u.Repo.DeleteNote(ctx, projectId, id)
+}
@@ -0,0 +1,93 @@
+package requests
+
+import (
+	"context"
+	"strings"
+
+	"proxy/internal/models"
+	"proxy/pkg/scope"
+)
+
+func (u *Usecase) ListPassthroughHosts(ctx context.Context) ([]models.PassthroughHost, error) {
+	return u.Repo.ListPassthroughHosts(ctx)
+}
+
+func (u *Usecase) GetPassthroughHost(ctx context.Context, id uint64) (*models.PassthroughHost, error) {
+	return u.Repo.GetPassthroughHost(ctx, id)
+}
+
+// CreatePassthroughHost checks a host glob and lists it.
+func (u *Usecase) CreatePassthroughHost(ctx context.Context, host models.PassthroughHost) (*models.PassthroughHost, error) {
+	host, err := checkPassthroughHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := u.Repo.SavePassthroughHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	u.forgetPassthrough()
+	return u.Repo.GetPassthroughHost(ctx, id)
+}
+
+// UpdatePassthroughHost changes the glob of a listed host or turns it on
+// or off, which is how a learned candidate is taken up.
+func (u *Usecase) UpdatePassthroughHost(ctx context.Context, host models.PassthroughHost) (*models.PassthroughHost, error) {
+	host, err := checkPassthroughHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Repo.UpdatePassthroughHost(ctx, host); err != nil {
+		return nil, err
+	}
+	u.forgetPassthrough()
+	return u.Repo.GetPassthroughHost(ctx, host.Id)
+}
+
+func (u *Usecase) DeletePassthroughHost(ctx context.Context, id uint64) error {
+	if err := u.Repo.DeletePassthroughHost(ctx, id); err != nil {
+		return err
+	}
+	u.forgetPassthrough()
+	return nil
+}
+
+// RecordHandshakeFailure notes that a client refused the certificate made
+// up for host, making the host a passthrough candidate.
+func (u *Usecase) RecordHandshakeFailure(ctx context.Context, host, reason string) error {
+	return u.Repo.RecordHandshakeFailure(ctx, strings.ToLower(host), reason)
+}
+
+// PassthroughHosts returns the globs of the enabled passthrough hosts.
+func (u *Usecase) PassthroughHosts(ctx context.Context) ([]string, error) {
+	return u.passthrough.get(struct{}{}, func() ([]string, error) {
+		all, err := u.Repo.ListPassthroughHosts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		enabled := []string{}
+		for _, host := range all {
+			if host.Enabled {
+				enabled = append(enabled, host.Host)
+			}
+		}
+		return enabled, nil
+	})
+}
+
+func (u *Usecase) forgetPassthrough() {
+	u.passthrough.forget(struct{}{})
+}
+
+func checkPassthroughHost(host models.PassthroughHost) (models.PassthroughHost, error) {
+	host.Host = strings.ToLower(strings.TrimSpace(host.Host))
+	if host.Host == "" {
+		return host, &models.ErrBadPassthrough{Reason: "host is required"}
+	}
+	if _, err := scope.HostPattern(host.Host); err != nil {
+		return host, &models.ErrBadPassthrough{Reason: err.Error()}
+	}
+	return host, nil
+}
@@ -0,0 +1,88 @@
+package requests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"proxy/internal/models"
+)
+
+// ActiveProject returns the id of the project captures go to.
+func (u *Usecase) ActiveProject(ctx context.Context) (uint64, error) {
+	return u.active.get(struct{}{}, func() (uint64, error) {
+		project, err := u.Repo.GetActiveProject(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return project.Id, nil
+	})
+}
+
+func (u *Usecase) forgetActiveProject() {
+	u.active.forget(struct{}{})
+}
+
+func (u *Usecase) ListProjects(ctx context.Context, archived bool) ([]models.Project, error) {
+	return u.Repo.ListProjects(ctx, archived)
+}
+
+func (u *Usecase) GetProject(ctx context.Context, id uint64) (*models.Project, error) {
+	return u.Repo.GetProject(ctx, id)
+}
+
+// CreateProject adds an inactive project, names are unique.
+func (u *Usecase) CreateProject(ctx context.Context, project models.Project) (*models.Project, error) {
+	project.Name = strings.TrimSpace(project.Name)
+	id, err := u.Repo.SaveProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return u.Repo.GetProject(ctx, id)
+}
+
+// ActivateProject switches the proxy over to capturing into project id.
+func (u *Usecase) ActivateProject(ctx context.Context, id uint64) error {
+	project, err := u.Repo.GetProject(ctx, id)
+	if err != nil {
+		return err
+	}
+	if project.Archived {
+		return &models.ErrProjectConflict{Reason: fmt.Sprintf("project %d is archived", id)}
+	}
+
+	if err := u.Repo.SetActiveProject(ctx, id); err != nil {
+		return err
+	}
+	u.forgetActiveProject()
+	return nil
+}
+
+func (u *Usecase) ArchiveProject(ctx context.Context, id uint64, archived bool) error {
+	if archived {
+		if err := u.refuseActive(ctx, id, "archive"); err != nil {
+			return err
+		}
+	}
+	return u.Repo.SetProjectArchived(ctx, id, archived)
+}
+
+// DeleteProject removes a project with its history, notes and scans.
+func (u *Usecase) DeleteProject(ctx context.Context, id uint64) error {
+	if err := u.refuseActive(ctx, id, "delete"); err != nil {
+		return err
+	}
+	return u.Repo.DeleteProject(ctx, id)
+}
+
+// refuseActive keeps the proxy from losing the project it captures into.
+func (u *Usecase) refuseActive(ctx context.Context, id uint64, action string) error {
+	project, err := u.Repo.GetProject(ctx, id)
+	if err != nil {
+		return err
+	}
+	if project.Active {
+		return &models.ErrProjectConflict{Reason: fmt.Sprintf("can't %s the active project, activate another one first", action)}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -14,40 +15,86 @@ import (
 	"proxy/internal/api/repository"
 	"proxy/internal/models"
 	"proxy/pkg/logger"
+	"proxy/pkg/scope"
+	"proxy/pkg/upstream"
 
 	reqUtils "proxy/pkg/http"
 )
 
 type Usecase struct {
-	Repo repository.Repository
-	log  logger.Logger
+	Repo   repository.Repository
+	log    logger.Logger
+	client *http.Client
+
+	active      ttlCache[struct{}, uint64]
+	rules       ttlCache[struct{}, []models.Rule]
+	scopes      ttlCache[uint64, *scope.Scope]
+	passthrough ttlCache[struct{}, []string]
 }
 
-func NewUsecase(r repository.Repository, log logger.Logger) *Usecase {
+// NewUsecase returns a Usecase whose tools reach their targets through
+// upstreams. Redirects are returned rather than followed: only the first
+// hop is checked against the scope, and the location may point anywhere.
+func NewUsecase(r repository.Repository, log logger.Logger, upstreams *upstream.Router) *Usecase {
 	return &Usecase{
 		Repo: r,
 		log:  log,
+		client: &http.Client{
+			Transport: upstreams.Transport(),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
 	}
 }
 
-func (u *Usecase) GetAllRequests(ctx context.Context) ([]models.Request, error) {
-	requests, err := u.Repo.GetAllRequests(ctx)
+func (u *Usecase) ListRequests(ctx context.Context, filter models.RequestFilter) (*models.RequestPage, error) {
+	page, err := u.Repo.ListRequests(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
-	return requests, nil
+	return page, nil
 }
 
-func (u *Usecase) GetRequestById(ctx context.Context, id uint64) (*models.Request, error) {
-	request, err := u.Repo.GetRequestById(ctx, id)
+// eachExchange calls fn with every exchange of the history matching filter,
+// in the filter's order. The filter's limit and cursor are ignored.
+func (u *Usecase) eachExchange(ctx context.Context, filter models.RequestFilter, fn func(models.Exchange) error) error {
+	filter.Limit = repository.MaxPageSize
+	filter.Cursor = ""
+
+	for {
+		page, err := u.Repo.ListRequests(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for i := range page.Requests {
+			req := &page.Requests[i]
+			resp, err := u.Repo.GetResponseByRequestId(ctx, filter.ProjectId, req.Id)
+			var errNoResponse *models.ErrResponseNotFound
+			if err != nil && !errors.As(err, &errNoResponse) {
+				return err
+			}
+			if err := fn(models.Exchange{Request: req, Response: resp}); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func (u *Usecase) GetRequestById(ctx context.Context, projectId, id uint64) (*models.Request, error) {
+	request, err := u.Repo.GetRequestById(ctx, projectId, id)
 	if err != nil {
 		return &models.Request{}, fmt.Errorf("%w", err)
 	}
 	return request, nil
 }
 
-func (u *Usecase) RepeatRequest(ctx context.Context, id uint64) (*http.Request, error) {
-	req, err := u.Repo.GetRequestById(ctx, id)
+func (u *Usecase) RepeatRequest(ctx context.Context, projectId, id uint64) (*http.Request, error) {
+	req, err := u.Repo.GetRequestById(ctx, projectId, id)
 	if err != nil {
 		return &http.Request{}, err
 	}
@@ -55,10 +102,18 @@ func (u *Usecase) RepeatRequest(ctx context.Context, id uint64) (*http.Request,
 	if err != nil {
 		return &http.Request{}, err
 	}
+	if err := u.checkScope(ctx, projectId, ri.URL); err != nil {
+		return &http.Request{}, err
+	}
 
 	return ri, nil
 }
 
+// SendRequest sends a request made by one of the tools to its target.
+func (u *Usecase) SendRequest(req *http.Request) (*http.Response, error) {
+	return u.client.Do(req)
+}
+
 func (u *Usecase) ScanRequest(ctx context.Context, param string, request *models.Request) (string, error) {
 	paramVal, _ := generateRandomString(generateRandomNumber())
 
@@ -74,8 +129,11 @@ func (u *Usecase) ScanRequest(ctx context.Context, param string, request *models
 	newURL.RawQuery = query.Encode()
 
 	ri.URL = newURL
+	if err := u.checkScope(ctx, request.ProjectId, ri.URL); err != nil {
+		return "", err
+	}
 
-	resp, err := http.DefaultClient.Do(ri)
+	resp, err := u.client.Do(ri)
 	if err != nil {
 		u.log.Errorf("[usecase] error on scan: %v\n", err)
 		return "", err
@@ -111,7 +169,17 @@ func generateRandomString(length int) (string, error) {
 	return randomString[:length], nil
 }
 
+// SaveRequest stores a request in its project, the active one if it has
+// none.
 func (u *Usecase) SaveRequest(ctx context.Context, request models.Request) (uint64, error) {
+	if request.ProjectId == 0 {
+		projectId, err := u.ActiveProject(ctx)
+		if err != nil {
+			return 0, err
+		}
+		request.ProjectId = projectId
+	}
+
 	id, err := u.Repo.SaveRequest(ctx, request)
 	if err != nil {
 		return 0, err
@@ -125,3 +193,33 @@ func (u *Usecase) SaveResponse(ctx context.Context, response models.Response) er
 	}
 	return nil
 }
+
+// SaveExchanges stores a batch of exchanges. Requests without a project
+// go to the active one.
+func (u *Usecase) SaveExchanges(ctx context.Context, exchanges []models.Exchange) error {
+	for _, ex := range exchanges {
+		if ex.Request.ProjectId != 0 {
+			continue
+		}
+		projectId, err := u.ActiveProject(ctx)
+		if err != nil {
+			return err
+		}
+		ex.Request.ProjectId = projectId
+	}
+	return u.Repo.SaveExchanges(ctx, exchanges)
+}
+
+// savedCount returns how many of a batch of n exchanges were stored by a
+// save that returned err. A partly saved batch is not an error to
+// imports, the refused exchanges are only left out of the count.
+func savedCount(n int, err error) (int, error) {
+	var partial *models.ErrPartialSave
+	if errors.As(err, &partial) {
+		return n - partial.Dropped, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,34 @@
+package requests
+
+import (
+	"context"
+	"errors"
+
+	"proxy/internal/models"
+)
+
+// GetExchange returns a request with its response. Requests that never got
+// one, because the origin was unreachable for example, come back with a
+// nil Response.
+func (u *Usecase) GetExchange(ctx context.Context, projectId, id uint64) (*models.Exchange, error) {
+	request, err := u.Repo.GetRequestById(ctx, projectId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := u.Repo.GetResponseByRequestId(ctx, projectId, id)
+	var errNoResponse *models.ErrResponseNotFound
+	if err != nil && !errors.As(err, &errNoResponse) {
+		return nil, err
+	}
+
+	return &models.Exchange{Request: request, Response: response}, nil
+}
+
+func (u *Usecase) GetResponseById(ctx context.Context, projectId, id uint64) (*models.Response, error) {
+	return u.Repo.GetResponseById(ctx, projectId, id)
+}
+
+func (u *Usecase) GetResponseByRequestId(ctx context.Context, projectId, requestId uint64) (*models.Response, error) {
+	return u.Repo.GetResponseByRequestId(ctx, projectId, requestId)
+}
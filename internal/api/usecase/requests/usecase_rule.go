@@ -0,0 +1,85 @@
+package requests
+
+import (
+	"context"
+	"strings"
+
+	"proxy/internal/models"
+	"proxy/pkg/rewrite"
+)
+
+func (u *Usecase) ListRules(ctx context.Context) ([]models.Rule, error) {
+	return u.Repo.ListRules(ctx)
+}
+
+func (u *Usecase) GetRule(ctx context.Context, id uint64) (*models.Rule, error) {
+	return u.Repo.GetRule(ctx, id)
+}
+
+// CreateRule checks a rule and adds it.
+func (u *Usecase) CreateRule(ctx context.Context, rule models.Rule) (*models.Rule, error) {
+	rule, err := checkRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := u.Repo.SaveRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	u.forgetRules()
+	return u.Repo.GetRule(ctx, id)
+}
+
+// UpdateRule checks a rule and replaces the stored one.
+func (u *Usecase) UpdateRule(ctx context.Context, rule models.Rule) (*models.Rule, error) {
+	rule, err := checkRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Repo.UpdateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	u.forgetRules()
+	return u.Repo.GetRule(ctx, rule.Id)
+}
+
+func (u *Usecase) DeleteRule(ctx context.Context, id uint64) error {
+	if err := u.Repo.DeleteRule(ctx, id); err != nil {
+		return err
+	}
+	u.forgetRules()
+	return nil
+}
+
+// EnabledRules returns the rules the proxy applies, in the order it
+// applies them.
+func (u *Usecase) EnabledRules(ctx context.Context) ([]models.Rule, error) {
+	return u.rules.get(struct{}{}, func() ([]models.Rule, error) {
+		all, err := u.Repo.ListRules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		enabled := []models.Rule{}
+		for _, rule := range all {
+			if rule.Enabled {
+				enabled = append(enabled, rule)
+			}
+		}
+		return enabled, nil
+	})
+}
+
+func (u *Usecase) forgetRules() {
+	u.rules.forget(struct{}{})
+}
+
+func checkRule(rule models.Rule) (models.Rule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Host = strings.ToLower(strings.TrimSpace(rule.Host))
+	if _, err := rewrite.Compile(rule); err != nil {
+		return rule, &models.ErrBadRule{Reason: err.Error()}
+	}
+	return rule, nil
+}
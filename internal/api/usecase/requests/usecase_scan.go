@@ -0,0 +1,23 @@
+package requests
+
+import (
+	"context"
+
+	"proxy/internal/models"
+)
+
+// ListScanResults returns the scans of a project, only those of requestId
+// unless it is zero.
+func (u *Usecase) ListScanResults(ctx context.Context, projectId, requestId uint64) ([]models.ScanResult, error) {
+	if requestId != 0 {
+		if _, err := u.Repo.GetRequestById(ctx, projectId, requestId); err != nil {
+			return nil, err
+		}
+	}
+	return u.Repo.ListScanResults(ctx, projectId, requestId)
+}
+
+// SaveScanResult records what a scan of a request found.
+func (u *Usecase) SaveScanResult(ctx context.Context, result models.ScanResult) (uint64, error) {
+	return u.Repo.SaveScanResult(ctx, result)
+}
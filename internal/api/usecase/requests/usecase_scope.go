@@ -0,0 +1,105 @@
+package requests
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"proxy/internal/models"
+	"proxy/pkg/scope"
+)
+
+func (u *Usecase) ListScopeRules(ctx context.Context, projectId uint64) ([]models.ScopeRule, error) {
+	return u.Repo.ListScopeRules(ctx, projectId)
+}
+
+func (u *Usecase) GetScopeRule(ctx context.Context, projectId, id uint64) (*models.ScopeRule, error) {
+	return u.Repo.GetScopeRule(ctx, projectId, id)
+}
+
+// CreateScopeRule checks a scope rule and adds it to its project.
+func (u *Usecase) CreateScopeRule(ctx context.Context, rule models.ScopeRule) (*models.ScopeRule, error) {
+	rule, err := checkScopeRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := u.Repo.SaveScopeRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	u.forgetScope(rule.ProjectId)
+	return u.Repo.GetScopeRule(ctx, rule.ProjectId, id)
+}
+
+// UpdateScopeRule checks a scope rule and replaces the stored one.
+func (u *Usecase) UpdateScopeRule(ctx context.Context, rule models.ScopeRule) (*models.ScopeRule, error) {
+	rule, err := checkScopeRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Repo.UpdateScopeRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	u.forgetScope(rule.ProjectId)
+	return u.Repo.GetScopeRule(ctx, rule.ProjectId, rule.Id)
+}
+
+func (u *Usecase) DeleteScopeRule(ctx context.Context, projectId, id uint64) error {
+	if err := u.Repo.DeleteScopeRule(ctx, projectId, id); err != nil {
+		return err
+	}
+	u.forgetScope(projectId)
+	return nil
+}
+
+// ActiveScope returns the scope of the project captures go to.
+func (u *Usecase) ActiveScope(ctx context.Context) (*scope.Scope, error) {
+	projectId, err := u.ActiveProject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return u.scope(ctx, projectId)
+}
+
+// checkScope refuses tool traffic to target unless it is in the scope of
+// the project.
+func (u *Usecase) checkScope(ctx context.Context, projectId uint64, target *url.URL) error {
+	s, err := u.scope(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	if !s.Contains(target) {
+		return &models.ErrOutOfScope{URL: target.Scheme + "://" + target.Host + target.Path}
+	}
+	return nil
+}
+
+func (u *Usecase) scope(ctx context.Context, projectId uint64) (*scope.Scope, error) {
+	return u.scopes.get(projectId, func() (*scope.Scope, error) {
+		rules, err := u.Repo.ListScopeRules(ctx, projectId)
+		if err != nil {
+			return nil, err
+		}
+		s, err := scope.Compile(rules)
+		if err != nil {
+			return nil, &models.ErrBadScopeRule{Reason: err.Error()}
+		}
+		return s, nil
+	})
+}
+
+func (u *Usecase) forgetScope(projectId uint64) {
+	u.scopes.forget(projectId)
+}
+
+func checkScopeRule(rule models.ScopeRule) (models.ScopeRule, error) {
+	rule.Kind = strings.ToLower(strings.TrimSpace(rule.Kind))
+	rule.Scheme = strings.ToLower(strings.TrimSpace(rule.Scheme))
+	rule.Host = strings.ToLower(strings.TrimSpace(rule.Host))
+	if _, err := scope.Compile([]models.ScopeRule{rule}); err != nil {
+		return rule, &models.ErrBadScopeRule{Reason: err.Error()}
+	}
+	return rule, nil
+}
@@ -0,0 +1,100 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proxy/internal/api/repository/memory"
+	"proxy/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRepeatChecksScopeWithCapturedScheme(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	u := NewUsecase(memory.NewRepository(), log, nil)
+
+	project, err := u.ActiveProject(ctx)
+	if err != nil {
+		t.Fatalf("ActiveProject: %v", err)
+	}
+	if _, err := u.CreateScopeRule(ctx, models.ScopeRule{ProjectId: project, Kind: models.ScopeInclude, Scheme: "https", Host: "shop.example"}); err != nil {
+		t.Fatalf("CreateScopeRule: %v", err)
+	}
+
+	secure, err := u.SaveRequest(ctx, models.Request{ProjectId: project, Method: "GET", Scheme: "https", Host: "shop.example", Path: "/cart"})
+	if err != nil {
+		t.Fatalf("SaveRequest: %v", err)
+	}
+	plain, err := u.SaveRequest(ctx, models.Request{ProjectId: project, Method: "GET", Scheme: "http", Host: "shop.example", Path: "/cart"})
+	if err != nil {
+		t.Fatalf("SaveRequest: %v", err)
+	}
+
+	req, err := u.RepeatRequest(ctx, project, secure)
+	if err != nil {
+		t.Fatalf("RepeatRequest of an https capture in scope: %v", err)
+	}
+	if got := req.URL.String(); got != "https://shop.example/cart" {
+		t.Errorf("repeated URL = %s, want https://shop.example/cart", got)
+	}
+
+	if _, err := u.RepeatRequest(ctx, project, plain); !errors.As(err, new(*models.ErrOutOfScope)) {
+		t.Errorf("RepeatRequest of an http capture: err = %v, want out of scope", err)
+	}
+}
+
+func TestRepeatDoesNotFollowRedirectOutOfScope(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	u := NewUsecase(memory.NewRepository(), log, nil)
+
+	outside := make(chan struct{}, 1)
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outside <- struct{}{}
+	}))
+	defer elsewhere.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(elsewhere.URL, "127.0.0.1", "localhost", 1)+"/steal", http.StatusFound)
+	}))
+	defer target.Close()
+
+	project, err := u.ActiveProject(ctx)
+	if err != nil {
+		t.Fatalf("ActiveProject: %v", err)
+	}
+	if _, err := u.CreateScopeRule(ctx, models.ScopeRule{ProjectId: project, Kind: models.ScopeInclude, Host: "127.0.0.1"}); err != nil {
+		t.Fatalf("CreateScopeRule: %v", err)
+	}
+	id, err := u.SaveRequest(ctx, models.Request{ProjectId: project, Method: "GET", Scheme: "http", Host: strings.TrimPrefix(target.URL, "http://"), Path: "/login"})
+	if err != nil {
+		t.Fatalf("SaveRequest: %v", err)
+	}
+
+	req, err := u.RepeatRequest(ctx, project, id)
+	if err != nil {
+		t.Fatalf("RepeatRequest: %v", err)
+	}
+	resp, err := u.SendRequest(req)
+	if err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("repeat answered %d, want the redirect itself", resp.StatusCode)
+	}
+	select {
+	case <-outside:
+		t.Error("the redirect to a host out of scope was followed")
+	default:
+	}
+}
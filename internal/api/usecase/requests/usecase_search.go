@@ -0,0 +1,38 @@
+package requests
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"strings"
+
+	"proxy/internal/models"
+)
+
+func (u *Usecase) Search(ctx context.Context, projectId uint64, text string, limit int) ([]models.SearchResult, error) {
+	results, err := u.Repo.Search(ctx, projectId, text, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(text))
+	for i := range results {
+		results[i].Snippet = highlight(results[i].Snippet, re)
+	}
+	return results, nil
+}
+
+// highlight escapes snippet as HTML and wraps every match of re in <mark>.
+func highlight(snippet string, re *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(snippet, -1) {
+		b.WriteString(html.EscapeString(snippet[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(snippet[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(snippet[last:]))
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package requests
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"proxy/internal/models"
+)
+
+const wsOpcodeText = 1
+
+func (u *Usecase) GetWebSockets(ctx context.Context, projectId uint64) ([]models.WebSocket, error) {
+	return u.Repo.GetWebSockets(ctx, projectId)
+}
+
+func (u *Usecase) GetWebSocketMessages(ctx context.Context, projectId, requestId uint64) ([]models.WebSocketMessage, error) {
+	messages, err := u.Repo.GetWebSocketMessages(ctx, projectId, requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		if messages[i].Opcode == wsOpcodeText && utf8.Valid(messages[i].Payload) {
+			messages[i].Text = string(messages[i].Payload)
+		}
+	}
+	return messages, nil
+}
+
+func (u *Usecase) SaveWebSocketMessage(ctx context.Context, message models.WebSocketMessage) error {
+	return u.Repo.SaveWebSocketMessage(ctx, message)
+}
@@ -0,0 +1,327 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxy/pkg/logger"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Migrations are sql/<version>_<name>.up.sql files with a matching
+// .down.sql, applied in version order.
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockId is the pg_advisory_lock key shared by every binary that migrates,
+// so the api and the proxy starting together apply each migration once.
+const lockId = 0x70726f7879
+
+const lockRetryInterval = 200 * time.Millisecond
+
+const (
+	CreateMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version		INTEGER		PRIMARY KEY					NOT NULL,
+		name		TEXT									NOT NULL,
+		applied_at	TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL
+	)`
+	AppliedMigrations = `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`
+	AddMigration      = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+	DeleteMigration   = `DELETE FROM schema_migrations WHERE version=$1`
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Status describes one migration, known to this binary or only recorded in
+// the database.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	db         *pgxpool.Pool
+	log        logger.Logger
+	migrations []Migration
+}
+
+func NewMigrator(db *pgxpool.Pool, log logger.Logger) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		log:        log,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			m.log.Infof("applying migration %04d_%s", mig.Version, mig.Name)
+			if err := m.apply(ctx, conn, mig.up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, AddMigration, mig.Version, mig.Name)
+				return err
+			}); err != nil {
+				return fmt.Errorf("[migrate] %04d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			count++
+		}
+
+		for version := range applied {
+			if m.find(version) == nil {
+				m.log.Warnf("database has migration %04d which this binary does not know about", version)
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the last steps applied migrations, newest first. A negative
+// steps reverts all of them.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, version := range versions {
+			if count == steps {
+				break
+			}
+
+			mig := m.find(version)
+			if mig == nil || mig.down == "" {
+				return fmt.Errorf("[migrate] no down migration for version %04d", version)
+			}
+
+			m.log.Infof("reverting migration %04d_%s", mig.Version, mig.Name)
+			if err := m.apply(ctx, conn, mig.down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, DeleteMigration, mig.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("[migrate] %04d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status lists known and recorded migrations in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			status := Status{Version: mig.Version, Name: mig.Name}
+			if s, ok := applied[mig.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = s.AppliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		for version, s := range applied {
+			if m.find(version) == nil {
+				statuses = append(statuses, s)
+			}
+		}
+		return nil
+	})
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// withLock runs fn on a dedicated connection holding the migration lock,
+// with the bookkeeping table in place.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("[migrate] failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	// Polling instead of pg_advisory_lock keeps the wait clear of the
+	// server's lock_timeout while the other binary is migrating.
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, lockId).Scan(&locked); err != nil {
+			return fmt.Errorf("[migrate] failed to take migration lock: %w", err)
+		}
+		if locked {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("[migrate] waiting for migration lock: %w", ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+	defer func() {
+		// The context may be gone by now, the lock must still be released
+		// since the connection goes back to the pool.
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockId); err != nil {
+			m.log.Errorf("failed to release migration lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, CreateMigrationsTable); err != nil {
+		return fmt.Errorf("[migrate] failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// apply runs a migration script and its bookkeeping in one transaction.
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, script string, record func(tx pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int]Status, error) {
+	rows, err := conn.Query(ctx, AppliedMigrations)
+	if err != nil {
+		return nil, fmt.Errorf("[migrate] failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]Status)
+	for rows.Next() {
+		var s Status
+		var appliedAt time.Time
+		if err := rows.Scan(&s.Version, &s.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		s.Applied = true
+		s.AppliedAt = &appliedAt
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("[migrate] unexpected file %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, label, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("[migrate] file %s is not named <version>_<name>.%s.sql", name, direction)
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: label}
+			byVersion[version] = mig
+		} else if mig.Name != label {
+			return nil, fmt.Errorf("[migrate] version %d is used by both %s and %s", version, mig.Name, label)
+		}
+
+		if direction == "up" {
+			mig.up = string(script)
+		} else {
+			mig.down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.up == "" {
+			return nil, fmt.Errorf("[migrate] version %d has no up migration", mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
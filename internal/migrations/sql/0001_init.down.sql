@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS response;
+DROP TABLE IF EXISTS request;
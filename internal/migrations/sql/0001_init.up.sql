@@ -1,4 +1,3 @@
-
 CREATE TABLE IF NOT EXISTS request (
 	id 		   		SERIAL 		  PRIMARY KEY			   	  NOT NULL,
 	method 	   		TEXT 		  CHECK(length(method) < 10)  NOT NULL,
@@ -20,4 +19,4 @@ CREATE TABLE IF NOT EXISTS response (
 	body 			TEXT,
 	created_at 		TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP   NOT NULL,
 	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
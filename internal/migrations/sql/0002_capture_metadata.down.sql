@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS websocket_message;
+
+-- Binary bodies do not survive the way back to TEXT, they are kept in
+-- escaped form.
+ALTER TABLE response
+	ALTER COLUMN body TYPE TEXT USING encode(body, 'escape'),
+	DROP COLUMN IF EXISTS proto,
+	DROP COLUMN IF EXISTS body_truncated,
+	DROP COLUMN IF EXISTS body_size,
+	DROP COLUMN IF EXISTS mime_type,
+	DROP COLUMN IF EXISTS encodings,
+	DROP COLUMN IF EXISTS decoded_body;
+
+ALTER TABLE request
+	ALTER COLUMN body TYPE TEXT USING encode(body, 'escape'),
+	DROP COLUMN IF EXISTS proto,
+	DROP COLUMN IF EXISTS body_truncated,
+	DROP COLUMN IF EXISTS body_size,
+	DROP COLUMN IF EXISTS mime_type,
+	DROP COLUMN IF EXISTS encodings,
+	DROP COLUMN IF EXISTS decoded_body;
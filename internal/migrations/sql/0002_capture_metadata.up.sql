@@ -0,0 +1,42 @@
+ALTER TABLE request
+	ADD COLUMN IF NOT EXISTS decoded_body	TEXT,
+	ADD COLUMN IF NOT EXISTS encodings		JSONB,
+	ADD COLUMN IF NOT EXISTS mime_type		TEXT		DEFAULT ''			NOT NULL,
+	ADD COLUMN IF NOT EXISTS body_size		BIGINT		DEFAULT 0			NOT NULL,
+	ADD COLUMN IF NOT EXISTS body_truncated	BOOLEAN		DEFAULT FALSE		NOT NULL,
+	ADD COLUMN IF NOT EXISTS proto			TEXT		DEFAULT 'HTTP/1.1'	NOT NULL;
+
+ALTER TABLE response
+	ADD COLUMN IF NOT EXISTS decoded_body	TEXT,
+	ADD COLUMN IF NOT EXISTS encodings		JSONB,
+	ADD COLUMN IF NOT EXISTS mime_type		TEXT		DEFAULT ''			NOT NULL,
+	ADD COLUMN IF NOT EXISTS body_size		BIGINT		DEFAULT 0			NOT NULL,
+	ADD COLUMN IF NOT EXISTS body_truncated	BOOLEAN		DEFAULT FALSE		NOT NULL,
+	ADD COLUMN IF NOT EXISTS proto			TEXT		DEFAULT 'HTTP/1.1'	NOT NULL;
+
+-- Databases created from the old initdb.sql may already have BYTEA bodies.
+DO $$
+BEGIN
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'request' AND column_name = 'body') = 'text' THEN
+		ALTER TABLE request ALTER COLUMN body TYPE BYTEA USING convert_to(body, 'UTF8');
+	END IF;
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'response' AND column_name = 'body') = 'text' THEN
+		ALTER TABLE response ALTER COLUMN body TYPE BYTEA USING convert_to(body, 'UTF8');
+	END IF;
+END
+$$;
+
+UPDATE request SET body_size = length(body) WHERE body IS NOT NULL AND body_size = 0;
+UPDATE response SET body_size = length(body) WHERE body IS NOT NULL AND body_size = 0;
+
+CREATE TABLE IF NOT EXISTS websocket_message (
+	id 				SERIAL 		PRIMARY KEY 				NOT NULL,
+	request_id		INTEGER									NOT NULL,
+	direction		TEXT		CHECK(direction IN ('client', 'server')) NOT NULL,
+	opcode			INTEGER									NOT NULL,
+	payload			BYTEA,
+	created_at 		TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
+);
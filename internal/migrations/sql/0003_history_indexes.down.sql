@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS response_request_id_idx;
+DROP INDEX IF EXISTS request_host_idx;
+DROP INDEX IF EXISTS request_created_at_id_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS request_created_at_id_idx ON request (created_at, id);
+CREATE INDEX IF NOT EXISTS request_host_idx ON request (lower("host"));
+CREATE INDEX IF NOT EXISTS response_request_id_idx ON response (request_id);
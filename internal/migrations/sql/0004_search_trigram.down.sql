@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS response_body_trgm_idx;
+DROP INDEX IF EXISTS response_headers_trgm_idx;
+DROP INDEX IF EXISTS request_body_trgm_idx;
+DROP INDEX IF EXISTS request_headers_trgm_idx;
+DROP INDEX IF EXISTS request_query_trgm_idx;
+DROP INDEX IF EXISTS request_path_trgm_idx;
@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS request_path_trgm_idx ON request USING GIN ("path" gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS request_query_trgm_idx ON request USING GIN ((query_params::text) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS request_headers_trgm_idx ON request USING GIN ((headers::text) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS request_body_trgm_idx ON request USING GIN (decoded_body gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS response_headers_trgm_idx ON response USING GIN ((headers::text) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS response_body_trgm_idx ON response USING GIN (decoded_body gin_trgm_ops);
@@ -0,0 +1,3 @@
+ALTER TABLE response
+	DROP COLUMN IF EXISTS duration_ms,
+	DROP COLUMN IF EXISTS wait_ms;
@@ -0,0 +1,3 @@
+ALTER TABLE response
+	ADD COLUMN IF NOT EXISTS wait_ms		BIGINT,
+	ADD COLUMN IF NOT EXISTS duration_ms	BIGINT;
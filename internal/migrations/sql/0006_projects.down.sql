@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS scan_result;
+DROP TABLE IF EXISTS note;
+
+DROP INDEX IF EXISTS request_project_created_at_id_idx;
+CREATE INDEX IF NOT EXISTS request_created_at_id_idx ON request (created_at, id);
+
+ALTER TABLE request DROP COLUMN IF EXISTS project_id;
+
+DROP TABLE IF EXISTS project;
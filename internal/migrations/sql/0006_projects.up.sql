@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS project (
+	id				SERIAL		PRIMARY KEY					NOT NULL,
+	name			TEXT		CHECK(length(name) < 200)	NOT NULL	UNIQUE,
+	description		TEXT		DEFAULT ''					NOT NULL,
+	active			BOOLEAN		DEFAULT FALSE				NOT NULL,
+	archived		BOOLEAN		DEFAULT FALSE				NOT NULL,
+	created_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL
+);
+
+-- The proxy captures into the one active project.
+CREATE UNIQUE INDEX IF NOT EXISTS project_active_idx ON project (active) WHERE active;
+
+-- Everything captured so far becomes the default project.
+INSERT INTO project (name, active) VALUES ('default', TRUE);
+
+ALTER TABLE request ADD COLUMN IF NOT EXISTS project_id INTEGER REFERENCES project(id) ON DELETE CASCADE;
+UPDATE request SET project_id = (SELECT id FROM project WHERE active) WHERE project_id IS NULL;
+ALTER TABLE request ALTER COLUMN project_id SET NOT NULL;
+
+DROP INDEX IF EXISTS request_created_at_id_idx;
+CREATE INDEX IF NOT EXISTS request_project_created_at_id_idx ON request (project_id, created_at, id);
+
+CREATE TABLE IF NOT EXISTS note (
+	id				SERIAL		PRIMARY KEY					NOT NULL,
+	project_id		INTEGER									NOT NULL,
+	request_id		INTEGER,
+	"text"			TEXT									NOT NULL,
+	created_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL,
+	updated_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL,
+	FOREIGN KEY (project_id) REFERENCES project(id) ON DELETE CASCADE,
+	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS note_project_request_idx ON note (project_id, request_id);
+
+CREATE TABLE IF NOT EXISTS scan_result (
+	id				SERIAL		PRIMARY KEY					NOT NULL,
+	project_id		INTEGER									NOT NULL,
+	request_id		INTEGER									NOT NULL,
+	hidden_params	JSONB									NOT NULL,
+	created_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL,
+	FOREIGN KEY (project_id) REFERENCES project(id) ON DELETE CASCADE,
+	FOREIGN KEY (request_id) REFERENCES request(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS scan_result_project_request_idx ON scan_result (project_id, request_id);
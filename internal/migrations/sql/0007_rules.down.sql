@@ -0,0 +1,3 @@
+ALTER TABLE request DROP COLUMN IF EXISTS fired_rules;
+
+DROP TABLE IF EXISTS rule;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS rule (
+	id				SERIAL		PRIMARY KEY					NOT NULL,
+	name			TEXT		DEFAULT ''					NOT NULL,
+	enabled			BOOLEAN		DEFAULT TRUE				NOT NULL,
+	target			TEXT									NOT NULL,
+	host			TEXT		DEFAULT ''					NOT NULL,
+	match			TEXT		DEFAULT ''					NOT NULL,
+	replace			TEXT		DEFAULT ''					NOT NULL,
+	regex			BOOLEAN		DEFAULT FALSE				NOT NULL,
+	created_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL,
+	updated_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL
+);
+
+-- Ids of the rules that rewrote an exchange.
+ALTER TABLE request ADD COLUMN IF NOT EXISTS fired_rules JSONB DEFAULT '[]' NOT NULL;
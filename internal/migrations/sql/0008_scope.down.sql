@@ -0,0 +1 @@
+DROP TABLE IF EXISTS scope_rule;
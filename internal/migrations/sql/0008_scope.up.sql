@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS scope_rule (
+	id				SERIAL		PRIMARY KEY					NOT NULL,
+	project_id		INTEGER									NOT NULL,
+	kind			TEXT									NOT NULL,
+	scheme			TEXT		DEFAULT ''					NOT NULL,
+	host			TEXT		DEFAULT ''					NOT NULL,
+	port			INTEGER		DEFAULT 0					NOT NULL,
+	path			TEXT		DEFAULT ''					NOT NULL,
+	created_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL,
+	updated_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL,
+	FOREIGN KEY (project_id) REFERENCES project(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS scope_rule_project_idx ON scope_rule (project_id);
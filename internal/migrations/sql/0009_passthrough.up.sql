@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS passthrough (
+	id				SERIAL		PRIMARY KEY					NOT NULL,
+	host			TEXT									NOT NULL	UNIQUE,
+	enabled			BOOLEAN		DEFAULT TRUE				NOT NULL,
+	learned			BOOLEAN		DEFAULT FALSE				NOT NULL,
+	failures		INTEGER		DEFAULT 0					NOT NULL,
+	last_error		TEXT		DEFAULT ''					NOT NULL,
+	created_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL,
+	updated_at		TIMESTAMPTZ	DEFAULT CURRENT_TIMESTAMP	NOT NULL
+);
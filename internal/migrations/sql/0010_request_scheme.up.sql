@@ -0,0 +1,4 @@
+-- Requests captured before the scheme was kept are taken as plain http
+-- unless they went to the default https port.
+ALTER TABLE request ADD COLUMN IF NOT EXISTS scheme TEXT DEFAULT 'http' NOT NULL;
+UPDATE request SET scheme = 'https' WHERE host LIKE '%:443';
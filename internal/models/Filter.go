@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// RequestFilter narrows and orders the request history of a project. Zero
+// values leave a field unconstrained. Status, content type and size refer to the
+// response the request got. Expression is written in the filter language
+// of pkg/filterexpr and combines with the other fields.
+type RequestFilter struct {
+	ProjectId   uint64
+	Methods     []string
+	Host        string
+	PathPrefix  string
+	StatusMin   int
+	StatusMax   int
+	ContentType string
+	From        time.Time
+	To          time.Time
+	MinSize     *int64
+	MaxSize     *int64
+	Expression  string
+
+	Sort   string
+	Desc   bool
+	Limit  int
+	Cursor string
+}
+
+// RequestPage is one page of the request history. NextCursor is empty on
+// the last page.
+type RequestPage struct {
+	Requests   []Request `json:"requests"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type ErrBadFilter struct {
+	Reason string
+}
+
+func (e *ErrBadFilter) Error() string {
+	return "bad filter: " + e.Reason
+}
+
+// SearchResult is one field of a captured exchange containing the searched
+// text. Snippet is HTML with the matches wrapped in <mark>.
+type SearchResult struct {
+	RequestId uint64    `json:"request_id"`
+	Field     string    `json:"field"`
+	Snippet   string    `json:"snippet"`
+	CreatedAt time.Time `json:"created_at"`
+}
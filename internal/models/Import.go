@@ -0,0 +1,19 @@
+package models
+
+// ErrBadImport rejects a file that can't be imported. At locates the
+// offending part, such as "entry 3" or "line 12".
+type ErrBadImport struct {
+	At     string
+	Reason string
+}
+
+func (e *ErrBadImport) Error() string {
+	return "bad import: " + e.At + ": " + e.Reason
+}
+
+// ImportResult counts the exchanges of an import. Skipped ones were
+// already in the history.
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Phases in which the proxy holds a message.
+const (
+	InterceptRequest  = "request"
+	InterceptResponse = "response"
+)
+
+// InterceptSettings decide which traffic the proxy holds for the operator.
+// Filter is a history filter expression, empty to hold everything, and
+// Responses holds responses as well as requests. Messages nobody acts on
+// are forwarded unchanged after TimeoutMs.
+type InterceptSettings struct {
+	Enabled   bool   `json:"enabled"`
+	Responses bool   `json:"responses"`
+	Filter    string `json:"filter"`
+	TimeoutMs int64  `json:"timeout_ms"`
+}
+
+// InterceptedMessage is a request the proxy holds before sending it on,
+// or a response it holds before passing it back, shown with its request.
+type InterceptedMessage struct {
+	Id        uint64    `json:"intercept_id"`
+	Phase     string    `json:"phase"`
+	Request   *Request  `json:"request"`
+	Response  *Response `json:"response,omitempty"`
+	HeldAt    time.Time `json:"held_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InterceptEdit changes a held message before it is forwarded, zero
+// fields leave it as it is. Method, path and query apply to requests and
+// code to responses. The body is given either base64 encoded in Body or
+// as Text, and is sent as given.
+type InterceptEdit struct {
+	Method  string              `json:"method,omitempty"`
+	Path    string              `json:"path,omitempty"`
+	Query   map[string][]string `json:"query,omitempty"`
+	Code    int                 `json:"code,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    []byte              `json:"body,omitempty"`
+	Text    *string             `json:"text,omitempty"`
+}
+
+// NewBody returns the replacement body, nil to keep the message's own.
+func (e *InterceptEdit) NewBody() []byte {
+	if e.Text != nil {
+		return append([]byte{}, *e.Text...)
+	}
+	return e.Body
+}
+
+type ErrInterceptNotFound struct{}
+
+func (e *ErrInterceptNotFound) Error() string {
+	return "intercepted message not found"
+}
+
+type ErrBadInterceptSettings struct {
+	Reason string
+}
+
+func (e *ErrBadInterceptSettings) Error() string {
+	return "bad intercept settings: " + e.Reason
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// PassthroughHost names a host, or a glob such as *.example.com, whose
+// CONNECT tunnels the proxy relays without decrypting them. Hosts whose
+// clients refused the proxy's certificate are recorded as Learned
+// candidates, disabled until someone enables them. Failures counts the
+// refused handshakes and LastError holds the latest reason.
+type PassthroughHost struct {
+	Id        uint64    `json:"passthrough_id"`
+	Host      string    `json:"host"`
+	Enabled   bool      `json:"enabled"`
+	Learned   bool      `json:"learned"`
+	Failures  int       `json:"failures"`
+	LastError string    `json:"last_error"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ErrPassthroughNotFound struct{}
+
+func (e *ErrPassthroughNotFound) Error() string {
+	return "passthrough host not found"
+}
+
+type ErrBadPassthrough struct {
+	Reason string
+}
+
+func (e *ErrBadPassthrough) Error() string {
+	return "bad passthrough host: " + e.Reason
+}
+
+// ErrPassthroughConflict refuses to list a host twice.
+type ErrPassthroughConflict struct {
+	Host string
+}
+
+func (e *ErrPassthroughConflict) Error() string {
+	return "passthrough host " + e.Host + " is already listed"
+}
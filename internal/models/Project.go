@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Project is one engagement. Its history, notes and scan results are kept
+// apart from every other project's. Exactly one project is active: the
+// proxy captures into it and the unscoped /api routes read from it.
+// Archived projects stay readable but can't be made active.
+type Project struct {
+	Id          uint64    `json:"project_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	Archived    bool      `json:"archived"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Note is a free text note in a project, about one request when RequestId
+// is set.
+type Note struct {
+	Id        uint64    `json:"note_id"`
+	ProjectId uint64    `json:"project_id"`
+	RequestId uint64    `json:"request_id,omitempty"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ScanResult is the outcome of a parameter scan of a request.
+type ScanResult struct {
+	Id           uint64    `json:"scan_id"`
+	ProjectId    uint64    `json:"project_id"`
+	RequestId    uint64    `json:"request_id"`
+	HiddenParams []string  `json:"hidden_params"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ErrProjectNotFound struct{}
+
+func (e *ErrProjectNotFound) Error() string {
+	return "project not found"
+}
+
+// ErrProjectConflict refuses a change the project's state doesn't allow,
+// such as archiving the active project or reusing a name.
+type ErrProjectConflict struct {
+	Reason string
+}
+
+func (e *ErrProjectConflict) Error() string {
+	return "project conflict: " + e.Reason
+}
+
+type ErrNoteNotFound struct{}
+
+func (e *ErrNoteNotFound) Error() string {
+	return "note not found"
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Header struct {
 	Name  string
@@ -9,24 +12,74 @@ type Header struct {
 
 type Request struct {
 	Id          uint64              `json:"request_id"`
+	ProjectId   uint64              `json:"project_id"`
 	Method      string              `json:"method"`
+	Scheme      string              `json:"scheme"`
 	Path        string              `json:"path"`
 	Host        string              `json:"host"`
 	Get_Params  map[string][]string `json:"query"`
 	Headers     map[string][]string `json:"headers"`
 	Cookies     map[string]string   `json:"cookies"`
 	Post_Params map[string][]string `json:"post_params"`
-	Body        string              `json:"body"`
+	Body        []byte              `json:"body"`
+	DecodedBody string              `json:"decoded_body"`
+	Encodings   []string            `json:"encodings"`
+	MimeType    string              `json:"mime_type"`
+	BodySize    int64               `json:"body_size"`
+	Truncated   bool                `json:"body_truncated"`
+	Proto       string              `json:"proto"`
 	CreatedAt   time.Time           `json:"created_at"`
+	FiredRules  []uint64            `json:"fired_rules"`
 }
 
 type Response struct {
-	Id        uint64              `json:"response_id"`
-	RequestId uint64              `json:"request_id"`
-	Code      int                 `json:"code"`
-	Headers   map[string][]string `json:"headers"`
-	Body      string              `json:"body"`
-	CreatedAt time.Time           `json:"created_at"`
+	Id          uint64              `json:"response_id"`
+	RequestId   uint64              `json:"request_id"`
+	Code        int                 `json:"code"`
+	Headers     map[string][]string `json:"headers"`
+	Body        []byte              `json:"body"`
+	DecodedBody string              `json:"decoded_body"`
+	Encodings   []string            `json:"encodings"`
+	MimeType    string              `json:"mime_type"`
+	BodySize    int64               `json:"body_size"`
+	Truncated   bool                `json:"body_truncated"`
+	Proto       string              `json:"proto"`
+	WaitMs      int64               `json:"wait_ms"`
+	DurationMs  int64               `json:"duration_ms"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// Exchange is a request together with the response it got, if any.
+type Exchange struct {
+	Request  *Request  `json:"request"`
+	Response *Response `json:"response"`
+}
+
+// ErrPartialSave reports a batch of exchanges of which only some were
+// saved. Dropped of Total were refused, Err being the last refusal.
+type ErrPartialSave struct {
+	Dropped int
+	Total   int
+	Err     error
+}
+
+func (e *ErrPartialSave) Error() string {
+	return fmt.Sprintf("%d of %d exchanges not saved: %v", e.Dropped, e.Total, e.Err)
+}
+
+func (e *ErrPartialSave) Unwrap() error {
+	return e.Err
+}
+
+// Body is a stored request or response body on its own, as served by the
+// body download endpoints. Size counts every byte seen on the wire, even
+// those beyond the capture limit.
+type Body struct {
+	Content   []byte              `json:"body"`
+	MimeType  string              `json:"mime_type"`
+	Size      int64               `json:"body_size"`
+	Truncated bool                `json:"body_truncated"`
+	Headers   map[string][]string `json:"-"`
 }
 
 type ErrRequestNotFuound struct{}
@@ -34,3 +87,27 @@ type ErrRequestNotFuound struct{}
 func (e *ErrRequestNotFuound) Error() string {
 	return "request not found"
 }
+
+type ErrResponseNotFound struct{}
+
+func (e *ErrResponseNotFound) Error() string {
+	return "response not found"
+}
+
+type WebSocketMessage struct {
+	Id        uint64    `json:"message_id"`
+	RequestId uint64    `json:"request_id"`
+	Direction string    `json:"direction"`
+	Opcode    int       `json:"opcode"`
+	Payload   []byte    `json:"payload"`
+	Text      string    `json:"text,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebSocket struct {
+	RequestId    uint64    `json:"request_id"`
+	Host         string    `json:"host"`
+	Path         string    `json:"path"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
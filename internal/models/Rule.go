@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Parts of an exchange a rule rewrites.
+const (
+	RuleRequestLine    = "request_line"
+	RuleRequestHeader  = "request_header"
+	RuleRequestBody    = "request_body"
+	RuleResponseStatus = "response_status"
+	RuleResponseHeader = "response_header"
+	RuleResponseBody   = "response_body"
+)
+
+// Rule rewrites traffic passing through the proxy by replacing Match with
+// Replace in one part of each exchange to Host, a host or a glob such as
+// *.example.com, or to every host when it is empty. Match is a regular
+// expression when Regex is set, Replace may then refer to its groups as
+// $1. Header rules work on each "Name: value" line: an empty Match adds
+// Replace as a header, a line replaced by nothing removes the header.
+// Captured requests list the rules that rewrote them in FiredRules.
+type Rule struct {
+	Id        uint64    `json:"rule_id"`
+	Name      string    `json:"name"`
+	Enabled   bool      `json:"enabled"`
+	Target    string    `json:"target"`
+	Host      string    `json:"host"`
+	Match     string    `json:"match"`
+	Replace   string    `json:"replace"`
+	Regex     bool      `json:"regex"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ErrRuleNotFound struct{}
+
+func (e *ErrRuleNotFound) Error() string {
+	return "rule not found"
+}
+
+type ErrBadRule struct {
+	Reason string
+}
+
+func (e *ErrBadRule) Error() string {
+	return "bad rule: " + e.Reason
+}
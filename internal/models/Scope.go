@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Kinds of scope rule.
+const (
+	ScopeInclude = "include"
+	ScopeExclude = "exclude"
+)
+
+// ScopeRule puts traffic into the target scope of a project or takes it
+// out. Scheme, Host, a host or a glob such as *.example.com, Port and
+// Path, a regular expression searched in the path, narrow what the rule
+// covers and cover everything when left empty. Traffic is in scope when
+// an include rule covers it and no exclude rule does. A project without
+// include rules has everything in scope that isn't excluded.
+type ScopeRule struct {
+	Id        uint64    `json:"scope_rule_id"`
+	ProjectId uint64    `json:"project_id"`
+	Kind      string    `json:"kind"`
+	Scheme    string    `json:"scheme"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ErrScopeRuleNotFound struct{}
+
+func (e *ErrScopeRuleNotFound) Error() string {
+	return "scope rule not found"
+}
+
+type ErrBadScopeRule struct {
+	Reason string
+}
+
+func (e *ErrBadScopeRule) Error() string {
+	return "bad scope rule: " + e.Reason
+}
+
+// ErrOutOfScope refuses to send tool traffic to a target outside the
+// project's scope.
+type ErrOutOfScope struct {
+	URL string
+}
+
+func (e *ErrOutOfScope) Error() string {
+	return e.URL + " is out of scope"
+}
@@ -8,22 +8,34 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
-	"io/ioutil"
-	"log"
+	"fmt"
 	"math/big"
+	"net"
 	"time"
 )
 
-func createCert(dnsNames []string, parent *x509.Certificate, parentKey crypto.PrivateKey, hoursValid int) (cert []byte, priv []byte) {
+// createCert mints a leaf for hosts; IP literals become IP SANs, the rest
+// DNS SANs.
+func createCert(hosts []string, parent *x509.Certificate, parentKey crypto.PrivateKey, hoursValid int) (cert []byte, priv []byte, err error) {
 	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		log.Fatalf("Failed to generate private key: %v", err)
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
 	}
 
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
-		log.Fatalf("Failed to generate serial number: %v", err)
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	var dnsNames []string
+	var ipAddresses []net.IP
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			ipAddresses = append(ipAddresses, ip)
+		} else {
+			dnsNames = append(dnsNames, h)
+		}
 	}
 
 	template := x509.Certificate{
@@ -31,9 +43,10 @@ func createCert(dnsNames []string, parent *x509.Certificate, parentKey crypto.Pr
 		Subject: pkix.Name{
 			Organization: []string{"Sample MITM proxy"},
 		},
-		DNSNames:  dnsNames,
-		NotBefore: time.Now(),
-		NotAfter:  time.Now().Add(time.Duration(hoursValid) * time.Hour),
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+		NotBefore:   time.Now(),
+		NotAfter:    time.Now().Add(time.Duration(hoursValid) * time.Hour),
 
 		KeyUsage:              x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
@@ -42,46 +55,21 @@ func createCert(dnsNames []string, parent *x509.Certificate, parentKey crypto.Pr
 
 	derBytes, err := x509.CreateCertificate(rand.Reader, &template, parent, &privateKey.PublicKey, parentKey)
 	if err != nil {
-		log.Fatalf("Failed to create certificate: %v", err)
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
 	}
 	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
 	if pemCert == nil {
-		log.Fatal("failed to encode certificate to PEM")
+		return nil, nil, fmt.Errorf("failed to encode certificate to PEM")
 	}
 
 	privBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
 	if err != nil {
-		log.Fatalf("Unable to marshal private key: %v", err)
+		return nil, nil, fmt.Errorf("unable to marshal private key: %w", err)
 	}
 	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes})
-	if pemCert == nil {
-		log.Fatal("failed to encode key to PEM")
-	}
-
-	return pemCert, pemKey
-}
-
-func loadX509KeyPair(certFile, keyFile string) (cert *x509.Certificate, key any, err error) {
-	cf, err := ioutil.ReadFile(certFile)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	kf, err := ioutil.ReadFile(keyFile)
-	if err != nil {
-		return nil, nil, err
-	}
-	certBlock, _ := pem.Decode(cf)
-	cert, err = x509.ParseCertificate(certBlock.Bytes)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	keyBlock, _ := pem.Decode(kf)
-	key, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
-	if err != nil {
-		return nil, nil, err
+	if pemKey == nil {
+		return nil, nil, fmt.Errorf("failed to encode key to PEM")
 	}
 
-	return cert, key, nil
+	return pemCert, pemKey, nil
 }
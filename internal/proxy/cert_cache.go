@@ -0,0 +1,299 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"proxy/pkg/logger"
+
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultCertCacheSize = 1024
+	// certRenewBefore is how long before NotAfter a cached leaf is
+	// considered stale and gets minted again.
+	certRenewBefore = time.Hour
+)
+
+// CertCacheStats is a snapshot of the certificate cache counters.
+type CertCacheStats struct {
+	Hits     uint64 `json:"hits"`
+	DiskHits uint64 `json:"disk_hits"`
+	Misses   uint64 `json:"misses"`
+	Entries  int    `json:"entries"`
+}
+
+type certEntry struct {
+	key  string
+	cert *tls.Certificate
+}
+
+// certCache is a bounded LRU of leaf certificates signed by the proxy CA.
+// Certificates for subdomains are minted with a sibling wildcard SAN and
+// stored under the wildcard key, so a.example.com and b.example.com share
+// one certificate. When dir is set, minted certificates are also written
+// to disk and picked up again after a restart.
+type certCache struct {
+	caCert     *x509.Certificate
+	caKey      any
+	hoursValid int
+	capacity   int
+	dir        string
+	log        logger.Logger
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	group   singleflight.Group
+
+	hits     atomic.Uint64
+	diskHits atomic.Uint64
+	misses   atomic.Uint64
+}
+
+func newCertCache(caCert *x509.Certificate, caKey any, capacity int, dir string, log logger.Logger) *certCache {
+	if capacity <= 0 {
+		capacity = defaultCertCacheSize
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			log.Errorf("cert cache dir %s is unusable, persistence disabled: %v", dir, err)
+			dir = ""
+		}
+	}
+
+	return &certCache{
+		caCert:     caCert,
+		caKey:      caKey,
+		hoursValid: 240,
+		capacity:   capacity,
+		dir:        dir,
+		log:        log,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns a certificate valid for host, minting one if needed.
+func (c *certCache) Get(host string) (*tls.Certificate, error) {
+	host = normalizeHost(host)
+	if host == "" {
+		return nil, fmt.Errorf("empty host")
+	}
+
+	key, names := certKey(host)
+	if cert := c.lookup(host, key); cert != nil {
+		c.hits.Add(1)
+		return cert, nil
+	}
+
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		if cert := c.lookup(host, key); cert != nil {
+			c.hits.Add(1)
+			return cert, nil
+		}
+
+		if cert := c.loadFromDisk(key); cert != nil {
+			c.diskHits.Add(1)
+			c.store(key, cert)
+			return cert, nil
+		}
+
+		c.misses.Add(1)
+		pemCert, pemKey, err := createCert(names, c.caCert, c.caKey, c.hoursValid)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := parseLeaf(pemCert, pemKey)
+		if err != nil {
+			return nil, err
+		}
+
+		c.store(key, cert)
+		c.saveToDisk(key, pemCert, pemKey)
+		return cert, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*tls.Certificate), nil
+}
+
+// Stats returns the current counters.
+func (c *certCache) Stats() CertCacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return CertCacheStats{
+		Hits:     c.hits.Load(),
+		DiskHits: c.diskHits.Load(),
+		Misses:   c.misses.Load(),
+		Entries:  entries,
+	}
+}
+
+func (c *certCache) lookup(host, key string) *tls.Certificate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := []string{host}
+	if key != host {
+		keys = append(keys, key)
+	}
+
+	for _, k := range keys {
+		el, ok := c.entries[k]
+		if !ok {
+			continue
+		}
+
+		entry := el.Value.(*certEntry)
+		if !certFresh(entry.cert.Leaf) {
+			c.lru.Remove(el)
+			delete(c.entries, k)
+			continue
+		}
+
+		c.lru.MoveToFront(el)
+		return entry.cert
+	}
+
+	return nil
+}
+
+func (c *certCache) store(key string, cert *tls.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*certEntry).cert = cert
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&certEntry{key: key, cert: cert})
+
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*certEntry).key)
+	}
+}
+
+func (c *certCache) certPath(key string) string {
+	name := strings.NewReplacer("*", "_wildcard", ":", "_", "/", "_").Replace(key)
+	return filepath.Join(c.dir, name+".pem")
+}
+
+func (c *certCache) loadFromDisk(key string) *tls.Certificate {
+	if c.dir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.certPath(key))
+	if err != nil {
+		return nil
+	}
+
+	var pemCert, pemKey []byte
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			pemCert = pem.EncodeToMemory(block)
+		case "PRIVATE KEY":
+			pemKey = pem.EncodeToMemory(block)
+		}
+	}
+
+	cert, err := parseLeaf(pemCert, pemKey)
+	if err != nil {
+		c.log.Warnf("ignoring cached certificate for %s: %v", key, err)
+		return nil
+	}
+
+	// Leaves signed by a previous CA or close to expiry are minted again.
+	if !certFresh(cert.Leaf) || cert.Leaf.CheckSignatureFrom(c.caCert) != nil {
+		return nil
+	}
+
+	return cert
+}
+
+func (c *certCache) saveToDisk(key string, pemCert, pemKey []byte) {
+	if c.dir == "" {
+		return
+	}
+
+	data := append(append([]byte{}, pemCert...), pemKey...)
+	if err := os.WriteFile(c.certPath(key), data, 0o600); err != nil {
+		c.log.Errorf("failed to persist certificate for %s: %v", key, err)
+	}
+}
+
+func parseLeaf(pemCert, pemKey []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(pemCert, pemKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cert, nil
+}
+
+func certFresh(leaf *x509.Certificate) bool {
+	return leaf != nil && time.Now().Add(certRenewBefore).Before(leaf.NotAfter)
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// certKey returns the cache key for host together with the names the
+// certificate should be minted for. Hosts with at least three labels get
+// a wildcard for their parent domain so siblings can reuse the leaf,
+// unless the parent is a public suffix such as co.uk: clients refuse
+// wildcards over those.
+func certKey(host string) (string, []string) {
+	if net.ParseIP(host) != nil {
+		return host, []string{host}
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 {
+		return host, []string{host}
+	}
+
+	parent := strings.Join(labels[1:], ".")
+	if suffix, _ := publicsuffix.PublicSuffix(parent); suffix == parent {
+		return host, []string{host}
+	}
+
+	wildcard := "*." + parent
+	return wildcard, []string{host, wildcard}
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCertKey(t *testing.T) {
+	tests := []struct {
+		host  string
+		key   string
+		names []string
+	}{
+		{"example.com", "example.com", []string{"example.com"}},
+		{"www.example.com", "*.example.com", []string{"www.example.com", "*.example.com"}},
+		{"a.b.example.com", "*.b.example.com", []string{"a.b.example.com", "*.b.example.com"}},
+		{"foo.co.uk", "foo.co.uk", []string{"foo.co.uk"}},
+		{"www.foo.co.uk", "*.foo.co.uk", []string{"www.foo.co.uk", "*.foo.co.uk"}},
+		{"user.github.io", "user.github.io", []string{"user.github.io"}},
+		{"192.0.2.1", "192.0.2.1", []string{"192.0.2.1"}},
+	}
+	for _, tt := range tests {
+		key, names := certKey(tt.host)
+		if key != tt.key || !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("certKey(%q) = %q, %v, want %q, %v", tt.host, key, names, tt.key, tt.names)
+		}
+	}
+}
+
+func TestCertCacheEviction(t *testing.T) {
+	caCert, caKey := testCA(t)
+	c := newCertCache(caCert, caKey, 2, "", testLogger())
+
+	for _, host := range []string{"a.test", "b.test", "a.test", "c.test"} {
+		if _, err := c.Get(host); err != nil {
+			t.Fatalf("Get(%s): %v", host, err)
+		}
+	}
+	if got := c.Stats(); got.Entries != 2 || got.Misses != 3 || got.Hits != 1 {
+		t.Fatalf("stats = %+v, want 2 entries after 3 misses and 1 hit", got)
+	}
+
+	// a.test was used after b.test, so b.test went out.
+	c.Get("a.test")
+	c.Get("b.test")
+	if got := c.Stats(); got.Hits != 2 || got.Misses != 4 {
+		t.Errorf("stats = %+v, want a.test kept and b.test minted again", got)
+	}
+}
+
+func TestCertCacheDisk(t *testing.T) {
+	caCert, caKey := testCA(t)
+	dir := t.TempDir()
+
+	minted, err := newCertCache(caCert, caKey, 0, dir, testLogger()).Get("www.example.com")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	restarted := newCertCache(caCert, caKey, 0, dir, testLogger())
+	reloaded, err := restarted.Get("api.example.com")
+	if err != nil {
+		t.Fatalf("Get after restart: %v", err)
+	}
+	if got := restarted.Stats(); got.DiskHits != 1 || got.Misses != 0 {
+		t.Errorf("stats after restart = %+v, want the sibling's leaf from disk", got)
+	}
+	if reloaded.Leaf.SerialNumber.Cmp(minted.Leaf.SerialNumber) != 0 {
+		t.Errorf("reloaded serial %v, want %v", reloaded.Leaf.SerialNumber, minted.Leaf.SerialNumber)
+	}
+
+	// After a CA rotation the leaves on disk no longer verify.
+	newCert, newKey := testCA(t)
+	rotated := newCertCache(newCert, newKey, 0, dir, testLogger())
+	leaf, err := rotated.Get("www.example.com")
+	if err != nil {
+		t.Fatalf("Get with another CA: %v", err)
+	}
+	if got := rotated.Stats(); got.DiskHits != 0 || got.Misses != 1 {
+		t.Errorf("stats with another CA = %+v, want the old leaf refused", got)
+	}
+	if err := leaf.Leaf.CheckSignatureFrom(newCert); err != nil {
+		t.Errorf("leaf is not signed by the current CA: %v", err)
+	}
+}
+
+func TestCertCacheSingleflight(t *testing.T) {
+	caCert, caKey := testCA(t)
+	c := newCertCache(caCert, caKey, 0, "", testLogger())
+
+	const callers = 16
+	leaves := make([]*tls.Certificate, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cert, err := c.Get("shared.example.com")
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			leaves[i] = cert
+		}(i)
+	}
+	wg.Wait()
+
+	if got := c.Stats(); got.Misses != 1 {
+		t.Errorf("%d concurrent Gets minted %d leaves, want 1", callers, got.Misses)
+	}
+	for i, leaf := range leaves {
+		if leaf != leaves[0] {
+			t.Fatalf("caller %d got another certificate", i)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"slices"
+	"sync"
+
+	"proxy/pkg/logger"
+)
+
+// compiledSet holds settings from the database compiled for the proxy,
+// such as the enabled rules. They are compiled again only when the stored
+// settings change, and kept as they were last seen while those can't be
+// loaded.
+type compiledSet[S comparable, C any] struct {
+	mu       sync.Mutex
+	source   []S
+	compiled []C
+}
+
+// get loads the settings, what names them in the log, and returns them
+// compiled. Those that don't compile are logged and left out.
+func (c *compiledSet[S, C]) get(log logger.Logger, what string, load func() ([]S, error), compile func(S) (C, error)) []C {
+	source, err := load()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		log.Errorf("failed to load %s: %v", what, err)
+		return c.compiled
+	}
+	if slices.Equal(source, c.source) {
+		return c.compiled
+	}
+
+	c.source = source
+	c.compiled = c.compiled[:0:0]
+	for _, s := range source {
+		compiled, err := compile(s)
+		if err != nil {
+			log.Errorf("skipping one of the %s: %v", what, err)
+			continue
+		}
+		c.compiled = append(c.compiled, compiled)
+	}
+	return c.compiled
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	requestUtils "proxy/pkg/http"
+
+	"golang.org/x/net/http2"
+)
+
+// handleH2Connection serves an intercepted connection on which the client
+// negotiated h2. Every stream is forwarded and recorded on its own.
+func (p *Proxy) handleH2Connection(tlsConn *tls.Conn, proxyReq *http.Request, client *http.Client) {
+	server := &http2.Server{}
+	server.ServeConn(tlsConn, &http2.ServeConnOpts{
+		Context: proxyReq.Context(),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p.handleH2Stream(w, r, proxyReq, client)
+		}),
+	})
+}
+
+func (p *Proxy) handleH2Stream(w http.ResponseWriter, r *http.Request, proxyReq *http.Request, client *http.Client) {
+	if b, err := httputil.DumpRequest(r, false); err == nil {
+		p.Logger.Infof("incoming h2 request:\n%s\n", string(b))
+	}
+
+	changeRequestToTarget(r, proxyReq.Host, true)
+	fired := p.rewriteRequest(r)
+	record := p.inScope(r.URL)
+	if record {
+		if err := p.interceptRequest(r); err != nil {
+			http.Error(w, "Failed to proxy: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	cpReq := *r
+
+	out := r.Clone(r.Context())
+	removeHopHeaders(out.Header)
+
+	var reqBody *requestUtils.BodyCapture
+	out.Body, reqBody = requestUtils.TeeBody(out.Body, p.maxBodySize)
+
+	start := time.Now()
+	resp, err := client.Do(out)
+	headersAt := time.Now()
+	if err != nil {
+		p.Logger.Errorf("error sending request to target: %v", err)
+		http.Error(w, "Failed to proxy", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	fired = append(fired, p.rewriteResponse(out, resp)...)
+	if record {
+		if err := p.interceptResponse(out, reqBody, resp); err != nil {
+			http.Error(w, "Failed to proxy: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	var respBody *requestUtils.BodyCapture
+	resp.Body, respBody = requestUtils.TeeBody(resp.Body, p.maxBodySize)
+
+	cpResp := *resp
+	if err := writeResponse(w, resp); err != nil {
+		p.Logger.Errorf("error writing response back: %v", err)
+		return
+	}
+
+	if record {
+		p.saveExchange(requestUtils.ParseRequest(cpReq, reqBody), requestUtils.ParseResponse(cpResp, respBody), start, headersAt, fired)
+	}
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const defaultInfoHost = "proxy.local"
+
+var infoPage = template.Must(template.New("info").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Host}}</title></head>
+<body>
+<h1>MITM proxy</h1>
+<p>Install the CA certificate below as a trusted root to browse HTTPS sites through this proxy.</p>
+<table>
+<tr><td>Subject</td><td>{{.Subject}}</td></tr>
+<tr><td>Valid until</td><td>{{.NotAfter}}</td></tr>
+</table>
+<ul>
+<li><a href="/ca.crt">CA certificate (PEM)</a> &mdash; Firefox, Linux, macOS</li>
+<li><a href="/ca.der">CA certificate (DER)</a> &mdash; Windows, Android, iOS</li>
+<li><a href="/proxy.pac">Proxy auto-config (PAC)</a> &mdash; {{.ProxyAddr}}</li>
+</ul>
+<p>Leaf certificate cache: {{.Stats.Hits}} hits, {{.Stats.DiskHits}} disk hits, {{.Stats.Misses}} misses, {{.Stats.Entries}} entries.</p>
+<p>History recorder: {{.Recorder.Saved}} saved, {{.Recorder.Pending}} pending, {{.Recorder.Dropped}} dropped, {{.Recorder.Failed}} failed.</p>
+</body>
+</html>
+`))
+
+// isInfoRequest reports whether r is addressed to the proxy itself rather
+// than to an upstream host.
+func (p *Proxy) isInfoRequest(r *http.Request) bool {
+	return strings.EqualFold(hostOnly(r.Host), p.infoHost)
+}
+
+// serveInfo answers requests to the magic info host with the onboarding
+// page, the CA certificate and a PAC file.
+func (p *Proxy) serveInfo(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/", "/index.html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := infoPage.Execute(w, map[string]any{
+			"Host":      p.infoHost,
+			"Subject":   p.caCert.Subject.String(),
+			"NotAfter":  p.caCert.NotAfter.Format("2006-01-02"),
+			"ProxyAddr": p.pacAddr(r),
+			"Stats":     p.certs.Stats(),
+			"Recorder":  p.recorder.Stats(),
+		})
+		if err != nil {
+			p.Logger.Errorf("failed to render info page: %v", err)
+		}
+	case "/ca.crt", "/ca.pem":
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="proxy-ca.crt"`)
+		w.Write(p.caCertPEM())
+	case "/ca.der", "/ca.cer":
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="proxy-ca.cer"`)
+		w.Write(p.caCert.Raw)
+	case "/proxy.pac":
+		w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+		fmt.Fprintf(w, `function FindProxyForURL(url, host) {
+	if (isPlainHostName(host) || host === "localhost" || host === "127.0.0.1") {
+		return "DIRECT";
+	}
+	return "PROXY %s";
+}
+`, p.pacAddr(r))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// pacAddr is the address clients should use to reach the proxy: the
+// configured public address or the local address the request came in on.
+func (p *Proxy) pacAddr(r *http.Request) string {
+	if p.publicAddr != "" {
+		return p.publicAddr
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return addr.String()
+	}
+	return r.Host
+}
@@ -0,0 +1,357 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"proxy/internal/models"
+	"proxy/pkg/config"
+	"proxy/pkg/filterexpr"
+
+	requestUtils "proxy/pkg/http"
+)
+
+const defaultInterceptTimeout = time.Minute
+
+// errDropped fails an exchange the operator dropped.
+var errDropped = errors.New("dropped by the operator")
+
+// Interceptor holds requests and responses that match its settings until
+// the operator forwards or drops them, or their timeout passes.
+type Interceptor struct {
+	mu       sync.Mutex
+	settings models.InterceptSettings
+	rule     filterexpr.Node
+	nextId   uint64
+	// respBody is set when the filter looks at response bodies, which
+	// have to be read before a response can be matched.
+	respBody bool
+	held     map[uint64]*heldMessage
+}
+
+type heldMessage struct {
+	msg      models.InterceptedMessage
+	decision chan interceptDecision
+}
+
+type interceptDecision struct {
+	drop bool
+	edit models.InterceptEdit
+}
+
+func newInterceptor(cfg config.Intercept) (*Interceptor, error) {
+	i := &Interceptor{held: make(map[uint64]*heldMessage)}
+	err := i.SetSettings(models.InterceptSettings{
+		Enabled:   cfg.Enabled,
+		Responses: cfg.Responses,
+		Filter:    cfg.Filter,
+		TimeoutMs: cfg.Timeout.Milliseconds(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("intercept: %w", err)
+	}
+	return i, nil
+}
+
+func (i *Interceptor) Settings() models.InterceptSettings {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.settings
+}
+
+// SetSettings replaces the settings. Turning interception off forwards
+// every held message unchanged. A zero timeout means the default one.
+func (i *Interceptor) SetSettings(s models.InterceptSettings) error {
+	if s.TimeoutMs < 0 {
+		return &models.ErrBadInterceptSettings{Reason: "timeout_ms can't be negative"}
+	}
+	var rule filterexpr.Node
+	if s.Filter != "" {
+		var err error
+		if rule, err = filterexpr.Parse(s.Filter); err != nil {
+			return &models.ErrBadFilter{Reason: err.Error()}
+		}
+	}
+	if s.TimeoutMs == 0 {
+		s.TimeoutMs = defaultInterceptTimeout.Milliseconds()
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.settings = s
+	i.rule = rule
+	i.respBody = rule != nil && filterexpr.Uses(rule, "resp.body", "resp.size", "resp.mime")
+	if !s.Enabled {
+		for id, h := range i.held {
+			delete(i.held, id)
+			h.decision <- interceptDecision{}
+		}
+	}
+	return nil
+}
+
+// List returns the held messages, oldest first.
+func (i *Interceptor) List() []models.InterceptedMessage {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	out := make([]models.InterceptedMessage, 0, len(i.held))
+	for _, h := range i.held {
+		out = append(out, h.msg)
+	}
+	slices.SortFunc(out, func(a, b models.InterceptedMessage) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return out
+}
+
+func (i *Interceptor) Get(id uint64) (*models.InterceptedMessage, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	h, ok := i.held[id]
+	if !ok {
+		return nil, &models.ErrInterceptNotFound{}
+	}
+	msg := h.msg
+	return &msg, nil
+}
+
+// Forward releases a held message with edit applied.
+func (i *Interceptor) Forward(id uint64, edit models.InterceptEdit) error {
+	return i.decide(id, interceptDecision{edit: edit})
+}
+
+// Drop releases a held message without sending it on. The client gets a
+// 502 instead.
+func (i *Interceptor) Drop(id uint64) error {
+	return i.decide(id, interceptDecision{drop: true})
+}
+
+func (i *Interceptor) decide(id uint64, d interceptDecision) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	h, ok := i.held[id]
+	if !ok {
+		return &models.ErrInterceptNotFound{}
+	}
+	delete(i.held, id)
+	h.decision <- d
+	return nil
+}
+
+// active tells whether messages of phase may be held at all, before
+// anything is read to match them against the filter.
+func (i *Interceptor) active(phase string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.settings.Enabled && (phase == models.InterceptRequest || i.settings.Responses)
+}
+
+func (i *Interceptor) matches(ex filterexpr.Exchange) bool {
+	i.mu.Lock()
+	rule := i.rule
+	i.mu.Unlock()
+	return rule == nil || filterexpr.Match(rule, ex)
+}
+
+// matchesHead tells whether a response may match the filter judging by
+// its request and headers, before its body is read.
+func (i *Interceptor) matchesHead(ex filterexpr.Exchange) bool {
+	i.mu.Lock()
+	rule, respBody := i.rule, i.respBody
+	i.mu.Unlock()
+	return rule == nil || respBody || filterexpr.Match(rule, ex)
+}
+
+// hold queues msg and waits for the operator. Once the timeout passes or
+// ctx is done the message goes on unchanged.
+func (i *Interceptor) hold(ctx context.Context, msg models.InterceptedMessage) interceptDecision {
+	h := &heldMessage{decision: make(chan interceptDecision, 1)}
+
+	i.mu.Lock()
+	i.nextId++
+	timeout := time.Duration(i.settings.TimeoutMs) * time.Millisecond
+	msg.Id = i.nextId
+	msg.HeldAt = time.Now()
+	msg.ExpiresAt = msg.HeldAt.Add(timeout)
+	h.msg = msg
+	i.held[msg.Id] = h
+	i.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case d := <-h.decision:
+		return d
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	i.mu.Lock()
+	delete(i.held, msg.Id)
+	i.mu.Unlock()
+
+	// The operator may have decided just as the wait ended.
+	select {
+	case d := <-h.decision:
+		return d
+	default:
+		return interceptDecision{}
+	}
+}
+
+// Interceptor returns the queue of held traffic, which the intercept api
+// manages.
+func (p *Proxy) Interceptor() *Interceptor {
+	return p.intercept
+}
+
+// interceptRequest holds r for the operator when it matches the intercept
+// settings and applies the operator's edits to it. It returns errDropped
+// if r must not be sent.
+func (p *Proxy) interceptRequest(r *http.Request) error {
+	if !p.intercept.active(models.InterceptRequest) {
+		return nil
+	}
+	body, ok := readHeldBody(&r.Body, p.maxBodySize)
+	if !ok {
+		return nil
+	}
+
+	req := requestUtils.ParseRequest(*r, captured(body, p.maxBodySize))
+	if !p.intercept.matches(filterexpr.Exchange{Request: req}) {
+		return nil
+	}
+
+	d := p.intercept.hold(r.Context(), models.InterceptedMessage{Phase: models.InterceptRequest, Request: req})
+	if d.drop {
+		return errDropped
+	}
+	editRequest(r, d.edit)
+	return nil
+}
+
+// interceptResponse holds resp, the answer to r, for the operator when
+// it matches the intercept settings and applies the operator's edits to
+// it. It returns errDropped, after closing the body, if resp must not be
+// passed back. Only responses that may match and can be held whole are
+// read up front, the others stream on untouched.
+func (p *Proxy) interceptResponse(r *http.Request, reqBody *requestUtils.BodyCapture, resp *http.Response) error {
+	if !p.intercept.active(models.InterceptResponse) {
+		return nil
+	}
+	req := requestUtils.ParseRequest(*r, reqBody)
+	head := requestUtils.ParseResponse(*resp, captured(nil, p.maxBodySize))
+	if !p.intercept.matchesHead(filterexpr.Exchange{Request: req, Response: &head}) || !holdableResponse(resp, p.maxBodySize) {
+		return nil
+	}
+
+	body, ok := readHeldBody(&resp.Body, p.maxBodySize)
+	if !ok {
+		return nil
+	}
+
+	respSave := requestUtils.ParseResponse(*resp, captured(body, p.maxBodySize))
+	if !p.intercept.matches(filterexpr.Exchange{Request: req, Response: &respSave}) {
+		return nil
+	}
+
+	d := p.intercept.hold(r.Context(), models.InterceptedMessage{Phase: models.InterceptResponse, Request: req, Response: &respSave})
+	if d.drop {
+		resp.Body.Close()
+		return errDropped
+	}
+	editResponse(resp, d.edit)
+	return nil
+}
+
+// readHeldBody reads a body that is about to be held, leaving *body to
+// replay it. Bodies beyond max are too large to hold and are passed on as
+// they stream, ok is false then.
+func readHeldBody(body *io.ReadCloser, max int64) (data []byte, ok bool) {
+	if *body == nil || *body == http.NoBody {
+		return nil, true
+	}
+	if max <= 0 {
+		max = requestUtils.DefaultMaxCapture
+	}
+
+	orig := *body
+	data, err := io.ReadAll(io.LimitReader(orig, max+1))
+	*body = replayBody{Reader: io.MultiReader(bytes.NewReader(data), orig), Closer: orig}
+	return data, err == nil && int64(len(data)) <= max
+}
+
+// holdableResponse tells whether the body of resp may be read in full
+// before it is passed on: its length is known and no more than max. Event
+// streams and bodies of unknown length are passed on as they come, holding
+// them would stall the client until the origin ends them.
+func holdableResponse(resp *http.Response, max int64) bool {
+	if max <= 0 {
+		max = requestUtils.DefaultMaxCapture
+	}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == "text/event-stream" {
+		return false
+	}
+	return resp.ContentLength >= 0 && resp.ContentLength <= max
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+func captured(body []byte, max int64) *requestUtils.BodyCapture {
+	capture := requestUtils.NewBodyCapture(max)
+	capture.Write(body)
+	return capture
+}
+
+func editRequest(r *http.Request, edit models.InterceptEdit) {
+	if edit.Method != "" {
+		r.Method = strings.ToUpper(edit.Method)
+	}
+	if edit.Path != "" {
+		r.URL.Path = edit.Path
+		r.URL.RawPath = ""
+	}
+	if edit.Query != nil {
+		r.URL.RawQuery = url.Values(edit.Query).Encode()
+	}
+	if edit.Headers != nil {
+		r.Header = http.Header(edit.Headers).Clone()
+		if host := r.Header.Get("Host"); host != "" {
+			r.Host = host
+			r.Header.Del("Host")
+		}
+	}
+	if body := edit.NewBody(); body != nil {
+		setRequestBody(r, body)
+	}
+}
+
+func editResponse(resp *http.Response, edit models.InterceptEdit) {
+	if edit.Code != 0 {
+		resp.StatusCode = edit.Code
+		resp.Status = fmt.Sprintf("%d %s", edit.Code, http.StatusText(edit.Code))
+	}
+	if edit.Headers != nil {
+		resp.Header = http.Header(edit.Headers).Clone()
+	}
+	if body := edit.NewBody(); body != nil {
+		setResponseBody(resp, body)
+	}
+}
@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy/internal/models"
+	"proxy/pkg/config"
+)
+
+func interceptingProxy(t *testing.T, settings models.InterceptSettings) *Proxy {
+	t.Helper()
+	i, err := newInterceptor(config.Intercept{})
+	if err != nil {
+		t.Fatalf("newInterceptor: %v", err)
+	}
+	if err := i.SetSettings(settings); err != nil {
+		t.Fatalf("SetSettings: %v", err)
+	}
+	return &Proxy{intercept: i}
+}
+
+// waitHeld returns the message the interceptor holds once there is one.
+func waitHeld(t *testing.T, i *Interceptor) models.InterceptedMessage {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if held := i.List(); len(held) > 0 {
+			return held[0]
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("nothing was held")
+	return models.InterceptedMessage{}
+}
+
+// interceptAsync runs interceptRequest in the background, the result
+// comes on the channel once the request is released.
+func interceptAsync(p *Proxy, r *http.Request) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- p.interceptRequest(r) }()
+	return done
+}
+
+func cartRequest() *http.Request {
+	return httptest.NewRequest(http.MethodPost, "http://shop.example/cart?x=1", strings.NewReader("qty=1"))
+}
+
+func body(t *testing.T, r io.Reader) string {
+	t.Helper()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestInterceptForwardWithEdits(t *testing.T) {
+	p := interceptingProxy(t, models.InterceptSettings{Enabled: true, Filter: `method == "POST"`})
+	r := cartRequest()
+	done := interceptAsync(p, r)
+
+	msg := waitHeld(t, p.intercept)
+	if msg.Phase != models.InterceptRequest || msg.Request.Path != "/cart" || string(msg.Request.Body) != "qty=1" {
+		t.Errorf("held %s %s %q", msg.Phase, msg.Request.Path, msg.Request.Body)
+	}
+
+	text := "qty=2"
+	edit := models.InterceptEdit{Method: "put", Path: "/basket", Headers: map[string][]string{"X-Edited": {"1"}}, Text: &text}
+	if err := p.intercept.Forward(msg.Id, edit); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("interceptRequest: %v", err)
+	}
+	if r.Method != http.MethodPut || r.URL.Path != "/basket" || r.URL.RawQuery != "x=1" || r.Header.Get("X-Edited") != "1" {
+		t.Errorf("forwarded %s %s?%s %v", r.Method, r.URL.Path, r.URL.RawQuery, r.Header)
+	}
+	if got := body(t, r.Body); got != text || r.ContentLength != int64(len(text)) {
+		t.Errorf("forwarded body %q with length %d, want %q", got, r.ContentLength, text)
+	}
+	if held := p.intercept.List(); len(held) != 0 {
+		t.Errorf("still held after forwarding: %v", held)
+	}
+}
+
+func TestInterceptDrop(t *testing.T) {
+	p := interceptingProxy(t, models.InterceptSettings{Enabled: true})
+	done := interceptAsync(p, cartRequest())
+
+	msg := waitHeld(t, p.intercept)
+	if err := p.intercept.Drop(msg.Id); err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	if err := <-done; !errors.Is(err, errDropped) {
+		t.Errorf("interceptRequest = %v, want errDropped", err)
+	}
+	if _, err := p.intercept.Get(msg.Id); !errors.As(err, new(*models.ErrInterceptNotFound)) {
+		t.Errorf("Get after drop: err = %v, want not found", err)
+	}
+	if err := p.intercept.Forward(msg.Id, models.InterceptEdit{}); !errors.As(err, new(*models.ErrInterceptNotFound)) {
+		t.Errorf("Forward after drop: err = %v, want not found", err)
+	}
+}
+
+func TestInterceptTimeout(t *testing.T) {
+	p := interceptingProxy(t, models.InterceptSettings{Enabled: true, TimeoutMs: 20})
+	r := cartRequest()
+
+	select {
+	case err := <-interceptAsync(p, r):
+		if err != nil {
+			t.Fatalf("interceptRequest: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("held past the timeout")
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/cart" || body(t, r.Body) != "qty=1" {
+		t.Errorf("request changed by the timeout: %s %s", r.Method, r.URL.Path)
+	}
+	if held := p.intercept.List(); len(held) != 0 {
+		t.Errorf("still held after the timeout: %v", held)
+	}
+}
+
+func TestInterceptResponseEdit(t *testing.T) {
+	p := interceptingProxy(t, models.InterceptSettings{Enabled: true, Responses: true, Filter: `resp.status == 500`})
+	r := httptest.NewRequest(http.MethodGet, "http://shop.example/", nil)
+	resp := &http.Response{StatusCode: 500, Status: "500 Internal Server Error", Header: http.Header{}, ContentLength: 4, Body: io.NopCloser(strings.NewReader("oops"))}
+
+	done := make(chan error, 1)
+	go func() { done <- p.interceptResponse(r, nil, resp) }()
+
+	msg := waitHeld(t, p.intercept)
+	if msg.Phase != models.InterceptResponse || msg.Response.Code != 500 {
+		t.Fatalf("held %s with status %d", msg.Phase, msg.Response.Code)
+	}
+	if err := p.intercept.Forward(msg.Id, models.InterceptEdit{Code: 200}); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("interceptResponse: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Status != "200 OK" || body(t, resp.Body) != "oops" {
+		t.Errorf("forwarded response %s", resp.Status)
+	}
+}
+
+// TestInterceptResponseStreams checks that responses which won't be held
+// are passed on without waiting for their bodies.
+func TestInterceptResponseStreams(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://shop.example/events", nil)
+	for _, tt := range []struct {
+		name   string
+		filter string
+		header http.Header
+	}{
+		{"not matching the filter", `resp.status == 500`, http.Header{"Content-Type": {"application/json"}}},
+		{"event stream", "", http.Header{"Content-Type": {"text/event-stream"}}},
+		{"unknown length", `resp.body contains "x"`, http.Header{"Content-Type": {"text/plain"}}},
+	} {
+		p := interceptingProxy(t, models.InterceptSettings{Enabled: true, Responses: true, Filter: tt.filter})
+		stream, origin := io.Pipe()
+		resp := &http.Response{StatusCode: 200, Status: "200 OK", Header: tt.header, ContentLength: -1, Body: stream}
+
+		done := make(chan error, 1)
+		go func() { done <- p.interceptResponse(r, nil, resp) }()
+		go origin.Write([]byte("data: 1\n\n"))
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%s: interceptResponse: %v", tt.name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: response waited for the end of its body", tt.name)
+		}
+		if held := p.intercept.List(); len(held) != 0 {
+			t.Errorf("%s: held %v", tt.name, held)
+		}
+
+		chunk := make([]byte, 64)
+		if n, _ := resp.Body.Read(chunk); string(chunk[:n]) != "data: 1\n\n" {
+			t.Errorf("%s: passed on %q", tt.name, chunk[:n])
+		}
+		origin.Close()
+	}
+}
+
+func TestInterceptSettings(t *testing.T) {
+	p := interceptingProxy(t, models.InterceptSettings{Enabled: true, Filter: `method == "GET"`})
+	if err := <-interceptAsync(p, cartRequest()); err != nil || len(p.intercept.List()) != 0 {
+		t.Errorf("a request the filter doesn't match was held")
+	}
+
+	if err := p.intercept.SetSettings(models.InterceptSettings{Enabled: true, TimeoutMs: -1}); !errors.As(err, new(*models.ErrBadInterceptSettings)) {
+		t.Errorf("negative timeout: err = %v, want bad settings", err)
+	}
+	if got := p.intercept.Settings().TimeoutMs; got != defaultInterceptTimeout.Milliseconds() {
+		t.Errorf("refused settings changed the timeout to %d", got)
+	}
+}
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"proxy/pkg/scope"
+)
+
+// passthroughSet holds the host globs whose tunnels are relayed without
+// being decrypted: those from the config and the enabled ones from the
+// database.
+type passthroughSet struct {
+	configured []*regexp.Regexp
+	stored     compiledSet[string, *regexp.Regexp]
+}
+
+func newPassthroughSet(globs []string) (*passthroughSet, error) {
+	s := &passthroughSet{}
+	for _, glob := range globs {
+		re, err := scope.HostPattern(glob)
+		if err != nil {
+			return nil, fmt.Errorf("passthrough: %w", err)
+		}
+		s.configured = append(s.configured, re)
+	}
+	return s, nil
+}
+
+// passthrough tells whether CONNECT tunnels to host are to be relayed as
+// they are. Pinned and mutual TLS clients can't talk through a made up
+// certificate. Host globs are lowercase, so is the host they match.
+func (p *Proxy) passthrough(host string) bool {
+	host = strings.ToLower(host)
+	for _, re := range p.passthroughs.configured {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+
+	stored := p.passthroughs.stored.get(p.Logger, "passthrough hosts", func() ([]string, error) {
+		return p.Usecase.PassthroughHosts(context.Background())
+	}, func(glob string) (*regexp.Regexp, error) {
+		re, err := scope.HostPattern(glob)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", glob, err)
+		}
+		return re, nil
+	})
+	for _, re := range stored {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// unclearFailures is how many handshakes in a row have to break off
+// without a certificate alert before the host is taken to refuse the
+// certificate. Browsers drop connections they opened speculatively, so a
+// single one proves nothing.
+const unclearFailures = 3
+
+// maxStreaks bounds the hosts with a streak going. Past it the streaks
+// start over rather than grow with every host that ever broke off once.
+const maxStreaks = 4096
+
+// certificateAlerts are the alerts clients send when they refuse the
+// certificate itself.
+var certificateAlerts = map[string]bool{
+	"tls: bad certificate":                 true,
+	"tls: unsupported certificate":         true,
+	"tls: revoked certificate":             true,
+	"tls: expired certificate":             true,
+	"tls: unknown certificate":             true,
+	"tls: unknown certificate authority":   true,
+	"tls: bad certificate hash value":      true,
+	"tls: bad certificate status response": true,
+}
+
+// handshakeStreaks counts per host the handshakes that broke off without
+// a certificate alert since one last completed.
+type handshakeStreaks struct {
+	mu     sync.Mutex
+	byHost map[string]int
+}
+
+// refusedCertificate tells whether err is a client alert refusing the
+// certificate it was sent.
+func refusedCertificate(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "remote error" && certificateAlerts[opErr.Err.Error()]
+}
+
+// learnPassthrough records host as a passthrough candidate after the
+// client gave up on a handshake in which it had been sent the certificate
+// made up for it. A certificate alert is enough. Clients that close the
+// connection instead, or send an alert under keys the proxy can't read,
+// are only taken to refuse it after unclearFailures such handshakes in a
+// row. A client that went quiet is not taken as refusing anything.
+func (p *Proxy) learnPassthrough(host string, err error) {
+	host = strings.ToLower(host)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return
+	}
+	if !refusedCertificate(err) && !p.handshakes.broke(host) {
+		return
+	}
+
+	if err := p.Usecase.RecordHandshakeFailure(context.Background(), host, err.Error()); err != nil {
+		p.Logger.Errorf("failed to record handshake failure for %s: %v", host, err)
+	}
+}
+
+// broke counts a handshake with host that broke off and tells whether
+// that makes unclearFailures in a row.
+func (s *handshakeStreaks) broke(host string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byHost == nil || len(s.byHost) >= maxStreaks {
+		s.byHost = make(map[string]int)
+	}
+	s.byHost[host]++
+	if s.byHost[host] < unclearFailures {
+		return false
+	}
+	delete(s.byHost, host)
+	return true
+}
+
+// completed ends the streak of host.
+func (s *handshakeStreaks) completed(host string) {
+	s.mu.Lock()
+	delete(s.byHost, strings.ToLower(host))
+	s.mu.Unlock()
+}
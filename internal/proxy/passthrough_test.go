@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"proxy/internal/api/usecase"
+)
+
+// refusingClientError runs a handshake against a leaf of an unknown CA
+// with a client that checks it, and returns the error the proxy side got.
+func refusingClientError(t *testing.T) error {
+	t.Helper()
+	caCert, caKey := testCA(t)
+	leaf, err := newCertCache(caCert, caKey, 0, "", testLogger()).Get("pinned.example")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	go func() {
+		client := tls.Client(clientConn, &tls.Config{ServerName: "pinned.example"})
+		client.Handshake()
+		client.Close()
+	}()
+	server := tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{*leaf}})
+	return server.Handshake()
+}
+
+func TestRefusedCertificate(t *testing.T) {
+	alert := refusingClientError(t)
+	if !refusedCertificate(alert) {
+		t.Errorf("refusedCertificate(%v) = false, want the alert taken as a refusal", alert)
+	}
+
+	for _, err := range []error{
+		io.EOF,
+		&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+		&net.OpError{Op: "local error", Err: errors.New("tls: bad record MAC")},
+		&net.OpError{Op: "remote error", Err: errors.New("tls: protocol version not supported")},
+	} {
+		if refusedCertificate(err) {
+			t.Errorf("refusedCertificate(%v) = true, want false", err)
+		}
+	}
+}
+
+type recordingUsecase struct {
+	usecase.Usecase
+	failures []string
+}
+
+func (u *recordingUsecase) RecordHandshakeFailure(ctx context.Context, host, reason string) error {
+	u.failures = append(u.failures, host)
+	return nil
+}
+
+func TestLearnPassthrough(t *testing.T) {
+	u := &recordingUsecase{}
+	p := &Proxy{Usecase: u, Logger: testLogger(), handshakes: &handshakeStreaks{}}
+
+	p.learnPassthrough("pinned.example", refusingClientError(t))
+	if len(u.failures) != 1 {
+		t.Fatalf("a certificate alert recorded %v, want pinned.example", u.failures)
+	}
+
+	// Speculative connections that are dropped don't make a candidate,
+	// unless nothing but dropped handshakes come in.
+	u.failures = nil
+	for i := 0; i < unclearFailures-1; i++ {
+		p.learnPassthrough("busy.example", io.EOF)
+	}
+	p.handshakes.completed("busy.example")
+	p.learnPassthrough("busy.example", io.EOF)
+	if len(u.failures) != 0 {
+		t.Errorf("dropped handshakes between completed ones recorded %v", u.failures)
+	}
+
+	for i := 0; i < unclearFailures; i++ {
+		p.learnPassthrough("dropping.example", io.EOF)
+	}
+	if len(u.failures) != 1 || u.failures[0] != "dropping.example" {
+		t.Errorf("%d dropped handshakes in a row recorded %v, want dropping.example", unclearFailures, u.failures)
+	}
+
+	// One host however the CONNECTs spell it.
+	u.failures = nil
+	for _, host := range []string{"Mixed.Example", "mixed.example", "MIXED.example"} {
+		p.learnPassthrough(host, io.EOF)
+	}
+	if len(u.failures) != 1 || u.failures[0] != "mixed.example" {
+		t.Errorf("dropped handshakes to one host in mixed case recorded %v, want mixed.example", u.failures)
+	}
+
+	u.failures = nil
+	for i := 0; i < unclearFailures; i++ {
+		p.learnPassthrough("slow.example", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded})
+	}
+	if len(u.failures) != 0 {
+		t.Errorf("timed out handshakes recorded %v", u.failures)
+	}
+}
+
+type passthroughUsecase struct {
+	usecase.Usecase
+	hosts []string
+}
+
+func (u *passthroughUsecase) PassthroughHosts(ctx context.Context) ([]string, error) {
+	return u.hosts, nil
+}
+
+func TestPassthroughIgnoresCase(t *testing.T) {
+	set, err := newPassthroughSet([]string{"pinned.example.com"})
+	if err != nil {
+		t.Fatalf("newPassthroughSet: %v", err)
+	}
+	p := &Proxy{passthroughs: set, Usecase: &passthroughUsecase{hosts: []string{"*.Bank.example"}}, Logger: testLogger()}
+
+	for _, host := range []string{"Pinned.Example.com", "PINNED.EXAMPLE.COM", "login.bank.example", "Login.BANK.example"} {
+		if !p.passthrough(host) {
+			t.Errorf("passthrough(%q) = false, want true", host)
+		}
+	}
+	if p.passthrough("Other.Example.com") {
+		t.Error("passthrough(Other.Example.com) = true, want false")
+	}
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	requestUtils "proxy/pkg/http"
+)
+
+// maxPipelined bounds how many requests of one connection may be in
+// flight before reading further requests blocks.
+const maxPipelined = 16
+
+const badGatewayResponse = "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n"
+
+// pipelinedExchange is one request read from an intercepted connection,
+// waiting for its upstream response.
+type pipelinedExchange struct {
+	cpReq    http.Request
+	req      *http.Request
+	reqBody  *requestUtils.BodyCapture
+	resp     *http.Response
+	respBody *requestUtils.BodyCapture
+	err      error
+	fired    []uint64
+	record   bool
+	done     chan struct{}
+
+	start     time.Time
+	headersAt time.Time
+}
+
+func (p *Proxy) handleHTTPRequest(ex *pipelinedExchange, client *http.Client) {
+	defer close(ex.done)
+	ex.fired = p.rewriteRequest(ex.req)
+	if ex.record = p.inScope(ex.req.URL); ex.record {
+		if ex.err = p.interceptRequest(ex.req); ex.err != nil {
+			return
+		}
+	}
+
+	ex.cpReq = *ex.req
+	ex.req.Body, ex.reqBody = requestUtils.TeeBody(ex.req.Body, p.maxBodySize)
+
+	ex.start = time.Now()
+	ex.resp, ex.err = client.Do(ex.req)
+	ex.headersAt = time.Now()
+	if ex.err != nil {
+		return
+	}
+	ex.fired = append(ex.fired, p.rewriteResponse(ex.req, ex.resp)...)
+	if ex.record {
+		if ex.err = p.interceptResponse(ex.req, ex.reqBody, ex.resp); ex.err != nil {
+			ex.resp = nil
+			return
+		}
+	}
+	ex.resp.Body, ex.respBody = requestUtils.TeeBody(ex.resp.Body, p.maxBodySize)
+}
+
+// writeResponses writes the responses of queued exchanges in the order
+// the requests arrived. After a response that closes the connection the
+// remaining exchanges are only drained.
+func (p *Proxy) writeResponses(conn net.Conn, queue <-chan *pipelinedExchange) {
+	closed := false
+	closeConn := func() {
+		closed = true
+		conn.Close()
+	}
+
+	for ex := range queue {
+		<-ex.done
+
+		if closed {
+			if ex.resp != nil {
+				ex.resp.Body.Close()
+			}
+			continue
+		}
+
+		if ex.err != nil {
+			p.Logger.Errorf("error sending request to target: %v", ex.err)
+			if _, err := io.WriteString(conn, badGatewayResponse); err != nil || ex.req.Close {
+				closeConn()
+			}
+			continue
+		}
+
+		resp := ex.resp
+		cpResp := *resp
+		// The origin may have answered over h2, the client still speaks 1.1.
+		resp.ProtoMajor, resp.ProtoMinor = 1, 1
+		if ex.req.Close {
+			resp.Close = true
+		}
+		// Without a length the body would be delimited by closing the
+		// connection, chunk it instead so it streams and keep-alive works.
+		if resp.ContentLength < 0 && len(resp.TransferEncoding) == 0 && !resp.Close {
+			resp.TransferEncoding = []string{"chunked"}
+		}
+
+		err := resp.Write(conn)
+		resp.Body.Close()
+		if err != nil {
+			p.Logger.Errorf("error writing response back: %v", err)
+			closeConn()
+			continue
+		}
+
+		if ex.record {
+			p.saveExchange(requestUtils.ParseRequest(ex.cpReq, ex.reqBody), requestUtils.ParseResponse(cpResp, ex.respBody), ex.start, ex.headersAt, ex.fired)
+		}
+
+		if resp.Close {
+			closeConn()
+		}
+	}
+}
+
+// trackBody returns a channel that is closed once the body of r has been
+// read to the end or closed, which for a request read off a connection
+// means the next request can be parsed.
+func trackBody(r *http.Request) <-chan struct{} {
+	done := make(chan struct{})
+	if r.Body == nil || r.Body == http.NoBody {
+		close(done)
+		return done
+	}
+
+	r.Body = &bodyTracker{ReadCloser: r.Body, done: done}
+	return done
+}
+
+type bodyTracker struct {
+	io.ReadCloser
+	once sync.Once
+	done chan struct{}
+}
+
+func (b *bodyTracker) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(func() { close(b.done) })
+	}
+	return n, err
+}
+
+// Close drains whatever the transport did not read, so the connection
+// stays in sync, before releasing the reader.
+func (b *bodyTracker) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { close(b.done) })
+	return err
+}
@@ -2,8 +2,10 @@ package proxy
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"io"
 	"net"
@@ -11,44 +13,113 @@ import (
 	"net/http/httputil"
 	"sync"
 	"syscall"
+	"time"
 
 	"proxy/internal/api/usecase"
+	"proxy/internal/models"
+	"proxy/pkg/config"
+	"proxy/pkg/rewrite"
+	"proxy/pkg/upstream"
 
 	requestUtils "proxy/pkg/http"
 	"proxy/pkg/logger"
+
+	"golang.org/x/net/http2"
 )
 
 type Proxy struct {
-	caCert  *x509.Certificate
-	caKey   any
-	Usecase usecase.Usecase
-	Logger  logger.Logger
+	caCert       *x509.Certificate
+	caKey        any
+	certs        *certCache
+	infoHost     string
+	publicAddr   string
+	maxBodySize  int64
+	recorder     *recorder
+	intercept    *Interceptor
+	rules        *compiledSet[models.Rule, *rewrite.Rule]
+	passthroughs *passthroughSet
+	handshakes   *handshakeStreaks
+	upstream     *upstream.Router
+	clients      *clientCache
+	Usecase      usecase.Usecase
+	Logger       logger.Logger
 }
 
-func NewProxy(caCertFile, caKeyFile string, u usecase.Usecase, l logger.Logger) (*Proxy, error) {
-	caCert, caKey, err := loadX509KeyPair(caCertFile, caKeyFile)
+// NewProxy loads the CA and returns a proxy that reaches origins through
+// upstreams.
+func NewProxy(cfg config.Proxy, caCertFile, caKeyFile string, u usecase.Usecase, l logger.Logger, upstreams *upstream.Router) (*Proxy, error) {
+	caCert, caKey, err := LoadCA(caCertFile, caKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	intercept, err := newInterceptor(cfg.Intercept)
+	if err != nil {
+		return nil, err
+	}
+
+	passthroughs, err := newPassthroughSet(cfg.Passthrough)
 	if err != nil {
 		return nil, err
 	}
 
+	infoHost := cfg.InfoHost
+	if infoHost == "" {
+		infoHost = defaultInfoHost
+	}
+
 	return &Proxy{
-		caCert:  caCert,
-		caKey:   caKey,
-		Usecase: u,
-		Logger:  l,
+		caCert:       caCert,
+		caKey:        caKey,
+		certs:        newCertCache(caCert, caKey, cfg.CertCache.Size, cfg.CertCache.Dir, l),
+		infoHost:     infoHost,
+		publicAddr:   cfg.PublicAddr,
+		maxBodySize:  cfg.MaxBodySize,
+		recorder:     newRecorder(cfg.Recorder, u.SaveExchanges, l),
+		intercept:    intercept,
+		rules:        &compiledSet[models.Rule, *rewrite.Rule]{},
+		passthroughs: passthroughs,
+		handshakes:   &handshakeStreaks{},
+		upstream:     upstreams,
+		clients:      &clientCache{},
+		Usecase:      u,
+		Logger:       l,
 	}, nil
 }
 
+// CertCacheStats reports how often leaf certificates were reused.
+func (p *Proxy) CertCacheStats() CertCacheStats {
+	return p.certs.Stats()
+}
+
+// RecorderStats reports how many captured exchanges were saved or lost.
+func (p *Proxy) RecorderStats() RecorderStats {
+	return p.recorder.Stats()
+}
+
+// Close flushes captured traffic that is still queued for saving.
+func (p *Proxy) Close(ctx context.Context) error {
+	return p.recorder.Close(ctx)
+}
+
+// caCertPEM returns the CA certificate in PEM form.
+func (p *Proxy) caCertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.caCert.Raw})
+}
+
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodConnect {
+	switch {
+	case r.Method == http.MethodConnect:
 		p.handleHTTPS(w, r)
+	case p.isInfoRequest(r):
+		p.serveInfo(w, r)
+	default:
+		p.handleHTTP(w, r)
 	}
-
-	p.handleHTTP(w, r)
 }
 
 type ProxyTransport struct {
-	http.Transport
+	*http.Transport
 }
 
 func (pt *ProxyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -57,59 +128,78 @@ func (pt *ProxyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 }
 
 func (p *Proxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
-	if bytes, err := httputil.DumpRequest(r, true); err == nil {
+	if bytes, err := httputil.DumpRequest(r, false); err == nil {
 		p.Logger.Infof("incoming request:\n%s\n", string(bytes))
 	}
+
+	if isWebSocketUpgrade(r) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "websocket upgrade not supported", http.StatusInternalServerError)
+			return
+		}
+
+		clientConn, buf, err := hj.Hijack()
+		if err != nil {
+			p.Logger.Errorf("http hijacking failed: %v", err)
+			return
+		}
+
+		target := r.URL.Host
+		if r.URL.Port() == "" {
+			target = net.JoinHostPort(r.URL.Hostname(), "80")
+		}
+		p.handleWebSocket(clientConn, buf.Reader, r, target, false)
+		return
+	}
 	r.RequestURI = ""
 
-	client := &http.Client{
-		Transport: &ProxyTransport{},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+	client := p.upstreamClient(r.URL.Hostname(), "")
+	fired := p.rewriteRequest(r)
+	record := p.inScope(r.URL)
+	if record {
+		if err := p.interceptRequest(r); err != nil {
+			http.Error(w, "Failed to proxy: "+err.Error(), http.StatusBadGateway)
+			return
+		}
 	}
 
 	cpReq := *r
+	var reqBody *requestUtils.BodyCapture
+	r.Body, reqBody = requestUtils.TeeBody(r.Body, p.maxBodySize)
 
+	start := time.Now()
 	resp, err := client.Do(r)
+	headersAt := time.Now()
 	if err != nil {
 		p.Logger.Errorf("client error: %v", err)
-		http.Error(w, "Failed to proxy %v", http.StatusBadRequest)
+		http.Error(w, "Failed to proxy", http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	if bytes, err := httputil.DumpResponse(resp, false); err == nil {
-		p.Logger.Infof("target response:\n%s\n", string(bytes))
+	fired = append(fired, p.rewriteResponse(r, resp)...)
+	if record {
+		if err := p.interceptResponse(r, reqBody, resp); err != nil {
+			http.Error(w, "Failed to proxy: "+err.Error(), http.StatusBadGateway)
+			return
+		}
 	}
 
-	hj, ok := w.(http.Hijacker)
-	if !ok {
-		p.Logger.Fatal("http server doesn't support hijacking connection")
+	if bytes, err := httputil.DumpResponse(resp, false); err == nil {
+		p.Logger.Infof("target response:\n%s\n", string(bytes))
 	}
 
-	clientConn, _, err := hj.Hijack()
-	if err != nil {
-		p.Logger.Fatal("http hijacking failed")
-	}
-	defer clientConn.Close()
+	var respBody *requestUtils.BodyCapture
+	resp.Body, respBody = requestUtils.TeeBody(resp.Body, p.maxBodySize)
 
 	cpResp := *resp
-	if err := resp.Write(clientConn); err != nil {
+	if err := writeResponse(w, resp); err != nil {
 		p.Logger.Errorf("error writing response back: %v", err)
 	}
 
-	reqSave := requestUtils.ParseRequest(cpReq)
-	respSave := requestUtils.ParseResponse(cpResp)
-
-	id, err := p.Usecase.SaveRequest(r.Context(), *reqSave)
-	if err != nil {
-		p.Logger.Errorf("failed to insert request: %v", err)
-	}
-
-	respSave.RequestId = id
-
-	if err := p.Usecase.SaveResponse(r.Context(), respSave); err != nil {
-		p.Logger.Errorf("error while saving response: %v", err)
+	if record {
+		p.saveExchange(requestUtils.ParseRequest(cpReq, reqBody), requestUtils.ParseResponse(cpResp, respBody), start, headersAt, fired)
 	}
 }
 
@@ -128,45 +218,153 @@ func (p *Proxy) handleHTTPS(w http.ResponseWriter, proxyReq *http.Request) {
 	}
 	defer clientConn.Close()
 
-	host, _, err := net.SplitHostPort(proxyReq.Host)
-	if err != nil {
-		p.Logger.Fatal("error splitting host/port:", err)
+	connectHost := hostOnly(proxyReq.Host)
+
+	if !p.hostInScope(proxyReq.Host) || p.passthrough(connectHost) {
+		p.tunnel(clientConn, proxyReq.Host)
 		return
 	}
 
-	pemCert, pemKey := createCert([]string{host}, p.caCert, p.caKey, 240)
-	tlsCert, err := tls.X509KeyPair(pemCert, pemKey)
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n")); err != nil {
+		p.Logger.Errorf("error writing status to client: %v", err)
+		return
+	}
+
+	// Browsers tunnel ws:// through CONNECT as well, so only a ClientHello
+	// leads to interception, anything else is plain HTTP inside the tunnel.
+	connReader := bufio.NewReader(clientConn)
+	first, err := connReader.Peek(1)
 	if err != nil {
-		p.Logger.Fatal(err)
+		return
 	}
+	if first[0] != tlsRecordHandshake {
+		client := p.upstreamClient(connectHost, "")
 
-	if _, err := clientConn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n")); err != nil {
-		p.Logger.Fatal("error writing status to client:", err)
+		p.handleTLSConnection(&bufferedConn{Conn: clientConn, r: connReader}, proxyReq, client, false)
+		return
 	}
 
+	// Set once a certificate went out, a handshake failing after that is
+	// the client refusing it.
+	certServed := false
 	tlsConfig := &tls.Config{
 		PreferServerCipherSuites: true,
 		CurvePreferences:         []tls.CurveID{tls.X25519, tls.CurveP256},
 		MinVersion:               tls.VersionTLS13,
-		Certificates:             []tls.Certificate{tlsCert},
+		NextProtos:               []string{http2.NextProtoTLS, "http/1.1"},
+		// The leaf is picked from the ClientHello: SNI when present, the
+		// CONNECT host otherwise (IP-only targets, clients without SNI).
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = connectHost
+			}
+			cert, err := p.certs.Get(name)
+			certServed = err == nil
+			return cert, err
+		},
 	}
 
-	tlsConn := tls.Server(clientConn, tlsConfig)
+	tlsConn := tls.Server(&bufferedConn{Conn: clientConn, r: connReader}, tlsConfig)
 	defer tlsConn.Close()
 
+	if err := tlsConn.Handshake(); err != nil {
+		p.Logger.Errorf("TLS handshake with client for %s failed: %v", proxyReq.Host, err)
+		if certServed {
+			p.learnPassthrough(connectHost, err)
+		}
+		return
+	}
+	p.handshakes.completed(connectHost)
+
+	client := p.upstreamClient(connectHost, tlsConn.ConnectionState().ServerName)
+
+	if tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+		p.handleH2Connection(tlsConn, proxyReq, client)
+		return
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 
 	go func() {
 		defer wg.Done()
-		p.handleTLSConnection(tlsConn, proxyReq)
+		p.handleTLSConnection(tlsConn, proxyReq, client, true)
 	}()
 
 	// Wait for the goroutine to finish before closing tlsConn
 	wg.Wait()
 }
 
-func (p *Proxy) handleTLSConnection(tlsConn net.Conn, proxyReq *http.Request) {
+// maxClients bounds the cached upstream clients. Past it they are
+// dropped and made again as needed.
+const maxClients = 256
+
+// clientCache holds one client per upstream proxy and server name, so
+// connections to origins are kept alive across client connections.
+type clientCache struct {
+	mu      sync.Mutex
+	clients map[clientKey]*http.Client
+}
+
+type clientKey struct {
+	upstream   string
+	serverName string
+}
+
+// upstreamClient returns the client used to reach the origin of a request
+// or intercepted connection, through the upstream proxy for its host.
+// When the client connected to an IP literal but named a host in SNI, the
+// same name is sent upstream so the origin picks and validates the right
+// certificate.
+func (p *Proxy) upstreamClient(connectHost, serverName string) *http.Client {
+	if net.ParseIP(connectHost) == nil {
+		serverName = ""
+	}
+	key := clientKey{serverName: serverName}
+	if proxyURL := p.upstream.For(connectHost); proxyURL != nil {
+		key.upstream = proxyURL.String()
+	}
+
+	p.clients.mu.Lock()
+	defer p.clients.mu.Unlock()
+	if client, ok := p.clients.clients[key]; ok {
+		return client
+	}
+	if p.clients.clients == nil || len(p.clients.clients) >= maxClients {
+		for _, client := range p.clients.clients {
+			client.CloseIdleConnections()
+		}
+		p.clients.clients = make(map[clientKey]*http.Client)
+	}
+
+	// Compression is left to the client: the origin's bytes are passed on
+	// as they are and only decoded for the history.
+	transport := p.upstream.Transport()
+	transport.DisableCompression = true
+	if serverName != "" {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.ServerName = serverName
+	}
+
+	client := &http.Client{
+		Transport: &ProxyTransport{transport},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	p.clients.clients[key] = client
+	return client
+}
+
+// handleTLSConnection reads HTTP/1.x requests from a CONNECT tunnel, which
+// is a decrypted TLS connection when secure is set and plain HTTP otherwise.
+// Requests are forwarded concurrently, but responses go back strictly in
+// request order as HTTP/1.1 pipelining requires, and the function only
+// returns once every in-flight response has been written.
+func (p *Proxy) handleTLSConnection(tlsConn net.Conn, proxyReq *http.Request, client *http.Client, secure bool) {
 	connReader := bufio.NewReader(tlsConn)
 
 	defer func() {
@@ -175,6 +373,19 @@ func (p *Proxy) handleTLSConnection(tlsConn net.Conn, proxyReq *http.Request) {
 		}
 	}()
 
+	queue := make(chan *pipelinedExchange, maxPipelined)
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		p.writeResponses(tlsConn, queue)
+	}()
+
+	drain := sync.OnceFunc(func() {
+		close(queue)
+		<-writerDone
+	})
+	defer drain()
+
 	for {
 		r, err := http.ReadRequest(connReader)
 		if err == io.EOF {
@@ -182,52 +393,71 @@ func (p *Proxy) handleTLSConnection(tlsConn net.Conn, proxyReq *http.Request) {
 		} else if errors.Is(err, syscall.ECONNRESET) {
 			p.Logger.Errorf("This is connection reset by peer error")
 			break
+		} else if errors.Is(err, net.ErrClosed) {
+			// The writer closed the connection after Connection: close.
+			break
 		} else if err != nil {
-			p.Logger.Fatal(proxyReq, err)
+			p.Logger.Errorf("failed to read request from %v: %v", proxyReq.Host, err)
 			break
 		}
 
-		go p.handleHTTPRequest(r, tlsConn, proxyReq)
-	}
-}
-
-func (p *Proxy) handleHTTPRequest(r *http.Request, tlsConn net.Conn, proxyReq *http.Request) {
-	if b, err := httputil.DumpRequest(r, false); err == nil {
-		p.Logger.Infof("incoming request:\n%s\n", string(b))
-	}
+		if isWebSocketUpgrade(r) {
+			// Earlier responses go out first, then the connection belongs
+			// to the websocket.
+			drain()
+			p.handleWebSocket(tlsConn, connReader, r, proxyReq.Host, secure)
+			return
+		}
 
-	cpReq := *r
+		if b, err := httputil.DumpRequest(r, false); err == nil {
+			p.Logger.Infof("incoming request:\n%s\n", string(b))
+		}
 
-	changeRequestToTarget(r, proxyReq.Host)
+		ex := &pipelinedExchange{
+			req:  r,
+			done: make(chan struct{}),
+		}
+		bodyRead := trackBody(r)
+		changeRequestToTarget(r, proxyReq.Host, secure)
 
-	client := http.Client{}
+		queue <- ex
+		go p.handleHTTPRequest(ex, client)
 
-	resp, err := client.Do(r)
-	if err != nil {
-		p.Logger.Errorf("error sending request to target: %v", err)
-		return
-	}
-	defer resp.Body.Close()
+		// The next request starts where this body ends.
+		<-bodyRead
 
-	cpResp := *resp
-	if err := resp.Write(tlsConn); err != nil {
-		p.Logger.Errorf("error writing response back: %v", err)
-		return
+		if r.Close {
+			break
+		}
 	}
+}
 
-	reqSave := requestUtils.ParseRequest(cpReq)
-	respSave := requestUtils.ParseResponse(cpResp)
+// saveExchange queues a request together with the response it got for
+// saving. start is when the request went out and headersAt when the
+// response headers came back; the exchange ends now, once the body has
+// been passed on. Timestamps and the project are taken here, at capture,
+// rather than when the batch reaches the database. fired lists the rules
+// that rewrote the exchange.
+func (p *Proxy) saveExchange(reqSave *models.Request, respSave models.Response, start, headersAt time.Time, fired []uint64) {
+	now := time.Now()
+	reqSave.ProjectId = p.activeProject()
+	reqSave.FiredRules = fired
+	reqSave.CreatedAt = start
+	respSave.CreatedAt = headersAt
+	respSave.WaitMs = headersAt.Sub(start).Milliseconds()
+	respSave.DurationMs = now.Sub(start).Milliseconds()
+
+	p.recorder.Record(models.Exchange{Request: reqSave, Response: &respSave})
+}
 
-	id, err := p.Usecase.SaveRequest(r.Context(), *reqSave)
+// activeProject returns the project captures go to. If it can't be looked
+// up the exchange is left untagged and lands in whichever project is
+// active when it is saved.
+func (p *Proxy) activeProject() uint64 {
+	id, err := p.Usecase.ActiveProject(context.Background())
 	if err != nil {
-		p.Logger.Errorf("failed to insert request: %v", err)
-		return
-	}
-
-	respSave.RequestId = id
-
-	if err := p.Usecase.SaveResponse(r.Context(), respSave); err != nil {
-		p.Logger.Errorf("error while saving response: %v", err)
-		return
+		p.Logger.Errorf("failed to look up the active project: %v", err)
+		return 0
 	}
+	return id
 }
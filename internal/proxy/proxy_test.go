@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"proxy/pkg/config"
+	"proxy/pkg/upstream"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testCA makes a fresh CA for leaves minted in tests.
+func testCA(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateCA("test CA", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	cert, err := ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatalf("ParseCertificatePEM: %v", err)
+	}
+	key, err := ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM: %v", err)
+	}
+	return cert, key
+}
+
+func testLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestUpstreamClient(t *testing.T) {
+	router, err := upstream.New([]config.Upstream{{Host: "*.corp", URL: "http://gw.corp:3128"}})
+	if err != nil {
+		t.Fatalf("upstream.New: %v", err)
+	}
+	p := &Proxy{upstream: router, clients: &clientCache{}}
+
+	direct := p.upstreamClient("example.com", "")
+	if p.upstreamClient("example.org", "") != direct {
+		t.Error("direct hosts got separate clients, want one kept alive")
+	}
+	if p.upstreamClient("example.com", "example.com") != direct {
+		t.Error("SNI on a named host made a new client")
+	}
+	if p.upstreamClient("wiki.corp", "") == direct {
+		t.Error("a host behind the gateway shares the direct client")
+	}
+
+	byIP := p.upstreamClient("192.0.2.1", "site.example")
+	if byIP == direct || p.upstreamClient("192.0.2.1", "site.example") != byIP {
+		t.Error("an IP target named in SNI needs a client of its own, reused")
+	}
+	tlsConfig := byIP.Transport.(*ProxyTransport).TLSClientConfig
+	if tlsConfig == nil || tlsConfig.ServerName != "site.example" {
+		t.Errorf("IP target client sends server name %v, want site.example", tlsConfig)
+	}
+	if _, ok := direct.Transport.(*ProxyTransport); !ok || direct.CheckRedirect(nil, nil) != http.ErrUseLastResponse {
+		t.Error("upstream client follows redirects or skips the proxy transport")
+	}
+}
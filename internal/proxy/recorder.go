@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"proxy/internal/models"
+	"proxy/pkg/config"
+	"proxy/pkg/logger"
+)
+
+const (
+	defaultRecorderQueueSize     = 4096
+	defaultRecorderWorkers       = 2
+	defaultRecorderBatchSize     = 200
+	defaultRecorderFlushInterval = 250 * time.Millisecond
+
+	// recorderSaveTimeout bounds a single batch write, so a stuck database
+	// can't hold a worker forever.
+	recorderSaveTimeout = 30 * time.Second
+
+	RecorderPolicyBlock = "block"
+	RecorderPolicyDrop  = "drop"
+)
+
+// RecorderStats is a snapshot of the recorder counters.
+type RecorderStats struct {
+	Queued  uint64 `json:"queued"`
+	Saved   uint64 `json:"saved"`
+	Dropped uint64 `json:"dropped"`
+	Failed  uint64 `json:"failed"`
+	Batches uint64 `json:"batches"`
+	Pending int    `json:"pending"`
+}
+
+// recorder takes captured exchanges off the proxy's hands and writes them
+// in batches from a few workers, so traffic never waits on a database
+// round trip. When the queue is full the block policy makes Record wait
+// for room, the drop policy discards the exchange and counts it.
+type recorder struct {
+	save          func(ctx context.Context, exchanges []models.Exchange) error
+	queue         chan models.Exchange
+	batchSize     int
+	flushInterval time.Duration
+	drop          bool
+	log           logger.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	queued  atomic.Uint64
+	saved   atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	batches atomic.Uint64
+}
+
+func newRecorder(cfg config.Recorder, save func(ctx context.Context, exchanges []models.Exchange) error, log logger.Logger) *recorder {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultRecorderQueueSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultRecorderWorkers
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultRecorderBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultRecorderFlushInterval
+	}
+	switch cfg.Policy {
+	case "", RecorderPolicyBlock, RecorderPolicyDrop:
+	default:
+		log.Warnf("unknown recorder policy %q, using %s", cfg.Policy, RecorderPolicyBlock)
+	}
+
+	r := &recorder{
+		save:          save,
+		queue:         make(chan models.Exchange, cfg.QueueSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		drop:          cfg.Policy == RecorderPolicyDrop,
+		log:           log,
+	}
+
+	r.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+// Record queues an exchange for saving.
+func (r *recorder) Record(ex models.Exchange) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+
+	if r.drop {
+		select {
+		case r.queue <- ex:
+		default:
+			if r.dropped.Add(1)%1000 == 1 {
+				r.log.Warnf("recorder queue full, dropping exchanges (%d so far)", r.dropped.Load())
+			}
+			return
+		}
+	} else {
+		r.queue <- ex
+	}
+	r.queued.Add(1)
+}
+
+// Close stops accepting exchanges and waits for everything queued to be
+// written, or for ctx to end.
+func (r *recorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *recorder) Stats() RecorderStats {
+	return RecorderStats{
+		Queued:  r.queued.Load(),
+		Saved:   r.saved.Load(),
+		Dropped: r.dropped.Load(),
+		Failed:  r.failed.Load(),
+		Batches: r.batches.Load(),
+		Pending: len(r.queue),
+	}
+}
+
+// work collects exchanges until a batch is full or the flush interval
+// passes, and flushes what is left once the queue is closed.
+func (r *recorder) work() {
+	defer r.wg.Done()
+
+	batch := make([]models.Exchange, 0, r.batchSize)
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ex, ok := <-r.queue:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, ex)
+			if len(batch) < r.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		r.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+func (r *recorder) flush(batch []models.Exchange) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recorderSaveTimeout)
+	defer cancel()
+
+	r.batches.Add(1)
+	err := r.save(ctx, batch)
+	var partial *models.ErrPartialSave
+	switch {
+	case errors.As(err, &partial):
+		// The rest of the batch made it, the refused exchanges are lost
+		// like those that found the queue full.
+		r.saved.Add(uint64(len(batch) - partial.Dropped))
+		r.dropped.Add(uint64(partial.Dropped))
+		r.log.Errorf("failed to save some exchanges: %v", err)
+	case err != nil:
+		r.failed.Add(uint64(len(batch)))
+		r.log.Errorf("failed to save %d exchanges: %v", len(batch), err)
+	default:
+		r.saved.Add(uint64(len(batch)))
+	}
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"proxy/internal/models"
+	"proxy/pkg/config"
+)
+
+// stalledSave is a save function that tells when a batch comes in and
+// holds it until release is closed.
+type stalledSave struct {
+	started chan int
+	release chan struct{}
+}
+
+func newStalledSave() *stalledSave {
+	return &stalledSave{started: make(chan int, 16), release: make(chan struct{})}
+}
+
+func (s *stalledSave) save(ctx context.Context, exchanges []models.Exchange) error {
+	s.started <- len(exchanges)
+	<-s.release
+	return nil
+}
+
+func (s *stalledSave) waitStarted(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch was saved")
+	}
+}
+
+func closeRecorder(t *testing.T, r *recorder) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func exchange(path string) models.Exchange {
+	return models.Exchange{Request: &models.Request{Method: "GET", Host: "a.test", Path: path}}
+}
+
+func TestRecorderDropPolicy(t *testing.T) {
+	s := newStalledSave()
+	r := newRecorder(config.Recorder{QueueSize: 1, Workers: 1, BatchSize: 1, Policy: RecorderPolicyDrop}, s.save, testLogger())
+
+	// One exchange is being saved, one waits in the queue, the third finds
+	// it full.
+	r.Record(exchange("/1"))
+	s.waitStarted(t)
+	r.Record(exchange("/2"))
+	r.Record(exchange("/3"))
+
+	if got := r.Stats(); got.Queued != 2 || got.Dropped != 1 || got.Pending != 1 {
+		t.Errorf("stats with a full queue = %+v, want 2 queued, 1 dropped, 1 pending", got)
+	}
+
+	close(s.release)
+	closeRecorder(t, r)
+	if got := r.Stats(); got.Saved != 2 || got.Batches != 2 || got.Pending != 0 {
+		t.Errorf("stats after close = %+v, want 2 saved in 2 batches", got)
+	}
+}
+
+func TestRecorderBlockPolicy(t *testing.T) {
+	s := newStalledSave()
+	r := newRecorder(config.Recorder{QueueSize: 1, Workers: 1, BatchSize: 1}, s.save, testLogger())
+
+	r.Record(exchange("/1"))
+	s.waitStarted(t)
+	r.Record(exchange("/2"))
+
+	recorded := make(chan struct{})
+	go func() {
+		r.Record(exchange("/3"))
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+		t.Fatal("Record returned with the queue full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(s.release)
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record still waits once the queue drained")
+	}
+	closeRecorder(t, r)
+	if got := r.Stats(); got.Queued != 3 || got.Saved != 3 || got.Dropped != 0 {
+		t.Errorf("stats = %+v, want 3 queued and saved, none dropped", got)
+	}
+}
+
+func TestRecorderFlushOnClose(t *testing.T) {
+	var batches [][]models.Exchange
+	save := func(ctx context.Context, exchanges []models.Exchange) error {
+		batches = append(batches, append([]models.Exchange(nil), exchanges...))
+		return nil
+	}
+	r := newRecorder(config.Recorder{Workers: 1, BatchSize: 100, FlushInterval: time.Hour}, save, testLogger())
+
+	for _, path := range []string{"/1", "/2", "/3"} {
+		r.Record(exchange(path))
+	}
+	closeRecorder(t, r)
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("close flushed %d batches, want the 3 exchanges in one", len(batches))
+	}
+
+	r.Record(exchange("/late"))
+	if got := r.Stats(); got.Saved != 3 || got.Dropped != 1 {
+		t.Errorf("stats = %+v, want 3 saved and the one recorded after close dropped", got)
+	}
+}
+
+func TestRecorderSaveErrors(t *testing.T) {
+	errs := make(chan error, 2)
+	errs <- errors.New("database is down")
+	errs <- &models.ErrPartialSave{Dropped: 1, Total: 2, Err: errors.New("value too long")}
+	save := func(ctx context.Context, exchanges []models.Exchange) error {
+		return <-errs
+	}
+	r := newRecorder(config.Recorder{Workers: 1, BatchSize: 2, FlushInterval: time.Hour}, save, testLogger())
+
+	for _, path := range []string{"/1", "/2", "/3", "/4"} {
+		r.Record(exchange(path))
+	}
+	closeRecorder(t, r)
+
+	// A failed batch is lost whole, of a partly saved one only the
+	// refused exchanges are.
+	if got := r.Stats(); got.Batches != 2 || got.Failed != 2 || got.Saved != 1 || got.Dropped != 1 {
+		t.Errorf("stats = %+v, want 2 batches: 2 failed, 1 saved, 1 dropped", got)
+	}
+}
@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"proxy/internal/models"
+	"proxy/pkg/rewrite"
+
+	requestUtils "proxy/pkg/http"
+)
+
+// rulesFor returns the enabled rules that rewrite traffic to host.
+func (p *Proxy) rulesFor(host string) []*rewrite.Rule {
+	compiled := p.rules.get(p.Logger, "rules", func() ([]models.Rule, error) {
+		return p.Usecase.EnabledRules(context.Background())
+	}, func(rule models.Rule) (*rewrite.Rule, error) {
+		c, err := rewrite.Compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", rule.Id, err)
+		}
+		return c, nil
+	})
+
+	var out []*rewrite.Rule
+	for _, rule := range compiled {
+		if rule.AppliesTo(host) {
+			out = append(out, rule)
+		}
+	}
+	return out
+}
+
+// rewriteRequest applies the rules to r before it is sent and returns the
+// ids of those that changed it.
+func (p *Proxy) rewriteRequest(r *http.Request) []uint64 {
+	rules := p.rulesFor(hostOnly(r.Host))
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var fired, bodyRules []*rewrite.Rule
+	for _, rule := range rules {
+		switch rule.Target {
+		case models.RuleRequestLine:
+			line, ok := rule.Apply(r.Method + " " + r.URL.RequestURI() + " " + r.Proto)
+			if ok && setRequestLine(r, line) {
+				fired = append(fired, rule)
+			}
+		case models.RuleRequestHeader:
+			if lines, ok := rule.ApplyLines(requestHeaderLines(r)); ok {
+				setRequestHeaders(r, lines)
+				fired = append(fired, rule)
+			}
+		case models.RuleRequestBody:
+			bodyRules = append(bodyRules, rule)
+		}
+	}
+
+	if len(bodyRules) > 0 {
+		body, bodyFired := p.rewriteBody(&r.Body, r.Header, bodyRules)
+		if body != nil {
+			setRequestBody(r, body)
+			fired = append(fired, bodyFired...)
+		}
+	}
+	return ruleIds(fired)
+}
+
+// rewriteResponse applies the rules to resp, the answer to r, before it
+// is passed back and returns the ids of those that changed it.
+func (p *Proxy) rewriteResponse(r *http.Request, resp *http.Response) []uint64 {
+	rules := p.rulesFor(hostOnly(r.Host))
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var fired, bodyRules []*rewrite.Rule
+	for _, rule := range rules {
+		switch rule.Target {
+		case models.RuleResponseStatus:
+			line, ok := rule.Apply(resp.Proto + " " + resp.Status)
+			if ok && setStatusLine(resp, line) {
+				fired = append(fired, rule)
+			}
+		case models.RuleResponseHeader:
+			if lines, ok := rule.ApplyLines(headerLines(resp.Header)); ok {
+				resp.Header = parseHeaderLines(lines)
+				fired = append(fired, rule)
+			}
+		case models.RuleResponseBody:
+			bodyRules = append(bodyRules, rule)
+		}
+	}
+
+	if len(bodyRules) > 0 && holdableResponse(resp, p.maxBodySize) {
+		body, bodyFired := p.rewriteBody(&resp.Body, resp.Header, bodyRules)
+		if body != nil {
+			setResponseBody(resp, body)
+			fired = append(fired, bodyFired...)
+		}
+	}
+	return ruleIds(fired)
+}
+
+// rewriteBody applies rules to the decoded text of *body and returns the
+// new body with the rules that changed it, or nil when none did. Bodies
+// that are empty, too large to hold or can't be decoded are left alone.
+// A changed body is sent without its content coding.
+func (p *Proxy) rewriteBody(body *io.ReadCloser, header http.Header, rules []*rewrite.Rule) ([]byte, []*rewrite.Rule) {
+	raw, ok := readHeldBody(body, p.maxBodySize)
+	if !ok || len(raw) == 0 {
+		return nil, nil
+	}
+	decoded, applied, err := requestUtils.DecodeBody(raw, header, p.maxBodySize)
+	if err != nil {
+		p.Logger.Errorf("not rewriting body: %v", err)
+		return nil, nil
+	}
+
+	text := string(decoded)
+	var fired []*rewrite.Rule
+	for _, rule := range rules {
+		var ok bool
+		if text, ok = rule.Apply(text); ok {
+			fired = append(fired, rule)
+		}
+	}
+	if len(fired) == 0 {
+		return nil, nil
+	}
+
+	header.Del("Content-Encoding")
+	for _, coding := range applied {
+		if strings.HasPrefix(coding, "charset:") {
+			// The text was converted to UTF-8 on the way.
+			if mt, params, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+				params["charset"] = "utf-8"
+				header.Set("Content-Type", mime.FormatMediaType(mt, params))
+			}
+		}
+	}
+	return []byte(text), fired
+}
+
+// setRequestLine sets the method, path and query of r from a request line
+// "METHOD /path?query PROTO". The protocol can't be changed. It reports
+// false for a line that doesn't parse, leaving r as it was.
+func setRequestLine(r *http.Request, line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return false
+	}
+	u, err := url.ParseRequestURI(fields[1])
+	if err != nil {
+		return false
+	}
+
+	r.Method = fields[0]
+	r.URL.Path = u.Path
+	r.URL.RawPath = u.RawPath
+	r.URL.RawQuery = u.RawQuery
+	return true
+}
+
+// setStatusLine sets the status of resp from a status line
+// "PROTO CODE TEXT". The protocol can't be changed.
+func setStatusLine(resp *http.Response, line string) bool {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+	if len(fields) < 2 {
+		return false
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil || code < 100 || code > 999 {
+		return false
+	}
+
+	text := http.StatusText(code)
+	if len(fields) == 3 {
+		text = fields[2]
+	}
+	resp.StatusCode = code
+	resp.Status = strings.TrimSpace(fields[1] + " " + text)
+	return true
+}
+
+// requestHeaderLines returns the header lines of r, starting with Host.
+func requestHeaderLines(r *http.Request) []string {
+	return append([]string{"Host: " + r.Host}, headerLines(r.Header)...)
+}
+
+// setRequestHeaders replaces the headers of r. Without a Host line the
+// request keeps its host.
+func setRequestHeaders(r *http.Request, lines []string) {
+	header := parseHeaderLines(lines)
+	if host := header.Get("Host"); host != "" {
+		r.Host = host
+	}
+	header.Del("Host")
+	r.Header = header
+}
+
+func headerLines(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		for _, v := range header[name] {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	return lines
+}
+
+// parseHeaderLines collects "Name: value" lines, lines without a name are
+// dropped.
+func parseHeaderLines(lines []string) http.Header {
+	header := make(http.Header)
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header
+}
+
+func ruleIds(rules []*rewrite.Rule) []uint64 {
+	var ids []uint64
+	for _, rule := range rules {
+		if !slices.Contains(ids, rule.Id) {
+			ids = append(ids, rule.Id)
+		}
+	}
+	return ids
+}
+
+func setRequestBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.TransferEncoding = nil
+	r.Header.Del("Content-Length")
+}
+
+func setResponseBody(resp *http.Response, body []byte) {
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy/internal/api/usecase"
+	"proxy/internal/models"
+	"proxy/pkg/rewrite"
+
+	requestUtils "proxy/pkg/http"
+)
+
+type rulesUsecase struct {
+	usecase.Usecase
+	rules []models.Rule
+}
+
+func (u *rulesUsecase) EnabledRules(ctx context.Context) ([]models.Rule, error) {
+	return u.rules, nil
+}
+
+func TestRewriteResponseBody(t *testing.T) {
+	p := &Proxy{
+		rules: &compiledSet[models.Rule, *rewrite.Rule]{},
+		Usecase: &rulesUsecase{rules: []models.Rule{
+			{Id: 1, Enabled: true, Target: models.RuleResponseBody, Match: "secret", Replace: "public"},
+		}},
+		Logger: testLogger(),
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example/", nil)
+
+	resp := &http.Response{
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		ContentLength: int64(len("a secret")),
+		Body:          io.NopCloser(strings.NewReader("a secret")),
+	}
+	if fired := p.rewriteResponse(req, resp); len(fired) != 1 {
+		t.Errorf("rule on a body of known length fired %v, want [1]", fired)
+	}
+	if got := body(t, resp.Body); got != "a public" {
+		t.Errorf("rewritten body = %q, want %q", got, "a public")
+	}
+
+	// Streams go out as they come, the rule must not wait for their end.
+	for _, tt := range []struct {
+		contentType string
+		length      int64
+	}{
+		{"text/event-stream", -1},
+		{"text/event-stream; charset=utf-8", 14},
+		{"text/plain", -1},
+		{"text/plain", requestUtils.DefaultMaxCapture + 1},
+	} {
+		contentType := tt.contentType
+		stream, origin := io.Pipe()
+		resp := &http.Response{
+			Header:        http.Header{"Content-Type": {contentType}},
+			ContentLength: tt.length,
+			Body:          stream,
+		}
+
+		done := make(chan []uint64, 1)
+		go func() { done <- p.rewriteResponse(req, resp) }()
+		go origin.Write([]byte("data: secret\n\n"))
+		select {
+		case fired := <-done:
+			if len(fired) != 0 {
+				t.Errorf("%s stream fired rules %v", contentType, fired)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s stream held until the origin ends it", contentType)
+		}
+
+		chunk := make([]byte, 64)
+		n, _ := resp.Body.Read(chunk)
+		if got := string(chunk[:n]); got != "data: secret\n\n" {
+			t.Errorf("%s stream passed on %q", contentType, got)
+		}
+		origin.Close()
+	}
+}
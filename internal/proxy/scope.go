@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"proxy/pkg/scope"
+)
+
+// inScope tells whether traffic to u is in the scope of the active
+// project. Traffic out of scope is passed on without being recorded or
+// held. When the scope can't be looked up everything is taken to be in
+// it.
+func (p *Proxy) inScope(u *url.URL) bool {
+	s, err := p.Usecase.ActiveScope(context.Background())
+	if err != nil {
+		p.Logger.Errorf("failed to look up the scope: %v", err)
+		return true
+	}
+	return s.Contains(u)
+}
+
+// hostInScope tells whether any traffic through a CONNECT tunnel to
+// hostport may be in scope. Tunnels that can't carry any are not
+// intercepted at all.
+func (p *Proxy) hostInScope(hostport string) bool {
+	s, err := p.Usecase.ActiveScope(context.Background())
+	if err != nil {
+		p.Logger.Errorf("failed to look up the scope: %v", err)
+		return true
+	}
+	host, port := scope.SplitHostPort("https", hostport)
+	return s.ContainsHost(host, port)
+}
+
+// tunnel answers a CONNECT by relaying bytes between the client and addr
+// untouched until either side closes.
+func (p *Proxy) tunnel(clientConn net.Conn, addr string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	upstream, err := p.upstream.Dial(ctx, addr)
+	cancel()
+	if err != nil {
+		p.Logger.Errorf("tunnel to %s failed: %v", addr, err)
+		clientConn.Write([]byte(badGatewayResponse))
+		return
+	}
+	defer upstream.Close()
+
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n")); err != nil {
+		p.Logger.Errorf("error writing status to client: %v", err)
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	relay := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		// Pass the end of one direction on and let the other finish.
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		} else {
+			dst.Close()
+		}
+		done <- struct{}{}
+	}
+	go relay(upstream, clientConn)
+	go relay(clientConn, upstream)
+	<-done
+	<-done
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"proxy/internal/api/repository/memory"
+	"proxy/internal/api/usecase/requests"
+	"proxy/pkg/config"
+
+	"golang.org/x/net/http2"
+)
+
+// interceptingServer starts a proxy in front of origin, which it reaches
+// with a client that trusts the origin's certificate. Clients of the
+// proxy are handed the CA pool that verifies its leaves.
+func interceptingServer(t *testing.T, origin *httptest.Server) (addr string, roots *x509.CertPool) {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateCA("test CA", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	log := testLogger()
+	p, err := NewProxy(config.Proxy{}, certFile, keyFile, requests.NewUsecase(memory.NewRepository(), log, nil), log, nil)
+	if err != nil {
+		t.Fatalf("NewProxy: %v", err)
+	}
+	transport := origin.Client().Transport.(*http.Transport).Clone()
+	transport.DisableCompression = true
+	p.clients.clients = map[clientKey]*http.Client{{}: {
+		Transport: &ProxyTransport{transport},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+
+	server := httptest.NewServer(p)
+	t.Cleanup(func() {
+		server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.Close(ctx)
+	})
+
+	roots = x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	return server.Listener.Addr().String(), roots
+}
+
+// dialIntercepted opens a CONNECT tunnel to target through the proxy and
+// completes a TLS handshake offering protos.
+func dialIntercepted(t *testing.T, addr, target string, roots *x509.CertPool, protos ...string) *tls.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("CONNECT: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT answered %s", resp.Status)
+	}
+
+	// An IP server name is only verified against, never sent as SNI.
+	tlsConn := tls.Client(&bufferedConn{Conn: conn, r: br}, &tls.Config{
+		ServerName: hostOnly(target),
+		RootCAs:    roots,
+		NextProtos: protos,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("handshake with the proxy: %v", err)
+	}
+	return tlsConn
+}
+
+func TestInterceptH2(t *testing.T) {
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+	}))
+	origin.EnableHTTP2 = true
+	origin.StartTLS()
+	defer origin.Close()
+
+	addr, roots := interceptingServer(t, origin)
+	target := origin.Listener.Addr().String()
+	conn := dialIntercepted(t, addr, target, roots, http2.NextProtoTLS, "http/1.1")
+	if got := conn.ConnectionState().NegotiatedProtocol; got != http2.NextProtoTLS {
+		t.Fatalf("ALPN negotiated %q, want h2", got)
+	}
+
+	cc, err := (&http2.Transport{}).NewClientConn(conn)
+	if err != nil {
+		t.Fatalf("NewClientConn: %v", err)
+	}
+	for _, path := range []string{"/a", "/b"} {
+		req, _ := http.NewRequest(http.MethodGet, "https://"+target+path, nil)
+		resp, err := cc.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 || string(got) != "GET "+path {
+			t.Errorf("GET %s answered %q over %s", path, got, resp.Proto)
+		}
+	}
+}
+
+func TestInterceptPipelined(t *testing.T) {
+	// The first request is answered last, its response must still be
+	// the first one written back.
+	delays := map[string]time.Duration{"/1": 300 * time.Millisecond, "/2": 100 * time.Millisecond}
+	origin := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delays[r.URL.Path])
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer origin.Close()
+
+	addr, roots := interceptingServer(t, origin)
+	target := origin.Listener.Addr().String()
+	conn := dialIntercepted(t, addr, target, roots, "http/1.1")
+	if got := conn.ConnectionState().NegotiatedProtocol; got != "http/1.1" {
+		t.Fatalf("ALPN negotiated %q, want http/1.1", got)
+	}
+
+	paths := []string{"/1", "/2", "/3"}
+	go func() {
+		var all []byte
+		for _, path := range paths {
+			all = fmt.Appendf(all, "GET %s HTTP/1.1\r\nHost: %s\r\n\r\n", path, target)
+		}
+		conn.Write(all)
+	}()
+
+	br := bufio.NewReader(conn)
+	for _, path := range paths {
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("reading the response to %s: %v", path, err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(got) != path {
+			t.Fatalf("response %q came back where %s's belongs", got, path)
+		}
+	}
+}
@@ -1,24 +1,32 @@
 package proxy
 
 import (
+	"bufio"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 )
 
-func changeRequestToTarget(req *http.Request, targetHost string) {
-	targetUrl := addrToUrl(targetHost)
+func changeRequestToTarget(req *http.Request, targetHost string, secure bool) {
+	targetUrl := addrToUrl(targetHost, secure)
 	targetUrl.Path = req.URL.Path
+	targetUrl.RawPath = req.URL.RawPath
 	targetUrl.RawQuery = req.URL.RawQuery
 	req.URL = targetUrl
 
 	req.RequestURI = ""
 }
 
-func addrToUrl(addr string) *url.URL {
-	if !strings.HasPrefix(addr, "https") {
-		addr = "https://" + addr
+func addrToUrl(addr string, secure bool) *url.URL {
+	scheme := "http://"
+	if secure {
+		scheme = "https://"
+	}
+	if !strings.Contains(addr, "://") {
+		addr = scheme + addr
 	}
 	u, err := url.Parse(addr)
 	if err != nil {
@@ -26,3 +34,92 @@ func addrToUrl(addr string) *url.URL {
 	}
 	return u
 }
+
+// tlsRecordHandshake is the first byte of every TLS ClientHello.
+const tlsRecordHandshake = 0x16
+
+// bufferedConn is a net.Conn whose reads go through a reader that may
+// already hold peeked bytes.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// hostOnly strips the port from a host[:port] string, tolerating hosts
+// that come without one.
+func hostOnly(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]")
+	}
+	return host
+}
+
+// hopHeaders are connection-level headers that must not be forwarded,
+// they are also illegal in HTTP/2.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		// Te: trailers is the one value allowed to pass (gRPC relies on it).
+		if name == "Te" && h.Get("Te") == "trailers" {
+			continue
+		}
+		h.Del(name)
+	}
+}
+
+// writeResponse streams resp to w, flushing after every chunk so that
+// server-sent events and long polling are not held back, and forwards
+// trailers once the body is done.
+func writeResponse(w http.ResponseWriter, resp *http.Response) error {
+	for k, values := range resp.Header {
+		w.Header()[k] = append([]string(nil), values...)
+	}
+	removeHopHeaders(w.Header())
+	for k := range resp.Trailer {
+		w.Header().Add("Trailer", k)
+	}
+
+	w.WriteHeader(resp.StatusCode)
+
+	_, err := io.Copy(flushWriter{w}, resp.Body)
+
+	for k, values := range resp.Trailer {
+		for _, v := range values {
+			w.Header().Add(http.TrailerPrefix+k, v)
+		}
+	}
+	return err
+}
+
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
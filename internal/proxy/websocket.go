@@ -0,0 +1,357 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"proxy/internal/models"
+
+	requestUtils "proxy/pkg/http"
+)
+
+const (
+	wsOpContinuation = 0x0
+	wsOpClose        = 0x8
+
+	// wsMaxCapture bounds how much of a single message is kept for history,
+	// the frames themselves are always relayed in full.
+	wsMaxCapture = 1 << 20
+
+	wsCloseTimeout = 5 * time.Second
+
+	// wsMaxPending bounds the messages of one socket waiting to be saved.
+	wsMaxPending = 256
+	// wsSaveTimeout bounds each history write of a socket.
+	wsSaveTimeout = 10 * time.Second
+)
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleWebSocket forwards an upgrade request to target and, once the
+// origin switches protocols, relays frames in both directions while
+// recording every message. clientBuf holds whatever was already read from
+// clientConn after the handshake request.
+func (p *Proxy) handleWebSocket(clientConn net.Conn, clientBuf *bufio.Reader, r *http.Request, target string, secure bool) {
+	defer clientConn.Close()
+
+	upstream, err := p.dialWebSocket(target, hostOnly(r.Host), secure)
+	if err != nil {
+		p.Logger.Errorf("websocket dial to %s failed: %v", target, err)
+		clientConn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n"))
+		return
+	}
+	defer upstream.Close()
+
+	cpReq := *r
+
+	// Compression would hide the payloads from the history, ask the origin
+	// for plain frames instead.
+	r.Header.Del("Sec-WebSocket-Extensions")
+	r.Header.Del("Proxy-Connection")
+	start := time.Now()
+	if err := r.Write(upstream); err != nil {
+		p.Logger.Errorf("error writing websocket handshake to %s: %v", target, err)
+		return
+	}
+
+	upstreamBuf := bufio.NewReader(upstream)
+	resp, err := http.ReadResponse(upstreamBuf, r)
+	if err != nil {
+		p.Logger.Errorf("error reading websocket handshake from %s: %v", target, err)
+		return
+	}
+
+	cpResp := *resp
+	if err := resp.Write(clientConn); err != nil {
+		p.Logger.Errorf("error writing websocket handshake back: %v", err)
+		return
+	}
+
+	// Out of scope the frames are relayed without a request to record
+	// them under.
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	var history *wsHistory
+	if p.inScope(&url.URL{Scheme: scheme, Host: target, Path: r.URL.Path}) {
+		reqSave := requestUtils.ParseRequest(cpReq, nil)
+		reqSave.Scheme = scheme
+		reqSave.ProjectId = p.activeProject()
+		respSave := requestUtils.ParseResponse(cpResp, nil)
+		respSave.WaitMs = time.Since(start).Milliseconds()
+		respSave.DurationMs = respSave.WaitMs
+
+		history = p.newWebSocketHistory(*reqSave, respSave)
+		defer history.close()
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return
+	}
+
+	// After a close frame the peer gets a grace period to answer with its
+	// own; any other end of a direction tears the whole tunnel down.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if p.relayWebSocket(clientBuf, upstream, history, "client") {
+			upstream.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+		} else {
+			upstream.Close()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if p.relayWebSocket(upstreamBuf, clientConn, history, "server") {
+			clientConn.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+		} else {
+			clientConn.Close()
+		}
+	}()
+	wg.Wait()
+}
+
+func (p *Proxy) dialWebSocket(target, serverName string, secure bool) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := p.upstream.Dial(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if !secure {
+		return conn, nil
+	}
+
+	if serverName == "" {
+		serverName = hostOnly(target)
+	}
+	// Same TLS settings as the regular upstream transport, minus h2: the
+	// upgrade only exists in HTTP/1.1.
+	tlsConfig := &tls.Config{}
+	if base := http.DefaultTransport.(*http.Transport).TLSClientConfig; base != nil {
+		tlsConfig = base.Clone()
+	}
+	tlsConfig.ServerName = serverName
+	tlsConfig.NextProtos = []string{"http/1.1"}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// relayWebSocket copies frames from src to dst untouched and saves each
+// reassembled message until the stream ends or a close frame passes. It
+// reports whether it stopped on a close frame.
+func (p *Proxy) relayWebSocket(src io.Reader, dst io.Writer, history *wsHistory, direction string) bool {
+	out := bufio.NewWriter(dst)
+	in := io.TeeReader(src, out)
+
+	var message *models.WebSocketMessage
+	for {
+		frame, err := readWebSocketFrame(in)
+		if flushErr := out.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				p.Logger.Debugf("websocket %s relay ended: %v", direction, err)
+			}
+			return false
+		}
+
+		switch {
+		case frame.opcode >= wsOpClose:
+			// Control frames may be interleaved with fragmented messages.
+			history.record(models.WebSocketMessage{
+				Direction: direction,
+				Opcode:    int(frame.opcode),
+				Payload:   frame.payload,
+				CreatedAt: time.Now(),
+			})
+		case frame.opcode == wsOpContinuation && message != nil:
+			message.Payload = appendCapped(message.Payload, frame.payload)
+		default:
+			message = &models.WebSocketMessage{
+				Direction: direction,
+				Opcode:    int(frame.opcode),
+				Payload:   frame.payload,
+				CreatedAt: time.Now(),
+			}
+		}
+
+		if frame.fin && frame.opcode < wsOpClose && message != nil {
+			history.record(*message)
+			message = nil
+		}
+
+		if frame.opcode == wsOpClose {
+			return true
+		}
+	}
+}
+
+// wsHistory writes the history of one socket off the relay path: the
+// upgrade exchange first, then its messages in order. Messages beyond
+// wsMaxPending waiting for the database are dropped, never the frames.
+type wsHistory struct {
+	p        *Proxy
+	messages chan models.WebSocketMessage
+	dropped  atomic.Uint64
+}
+
+func (p *Proxy) newWebSocketHistory(request models.Request, response models.Response) *wsHistory {
+	h := &wsHistory{p: p, messages: make(chan models.WebSocketMessage, wsMaxPending)}
+	go h.write(request, response)
+	return h
+}
+
+// record queues a message; the nil history of an out of scope socket
+// ignores it.
+func (h *wsHistory) record(message models.WebSocketMessage) {
+	if h == nil {
+		return
+	}
+	select {
+	case h.messages <- message:
+	default:
+		if dropped := h.dropped.Add(1); dropped%100 == 1 {
+			h.p.Logger.Warnf("websocket history falling behind, dropped %d messages", dropped)
+		}
+	}
+}
+
+func (h *wsHistory) close() {
+	close(h.messages)
+}
+
+func (h *wsHistory) write(request models.Request, response models.Response) {
+	ctx, cancel := context.WithTimeout(context.Background(), wsSaveTimeout)
+	id, err := h.p.Usecase.SaveRequest(ctx, request)
+	if err != nil {
+		h.p.Logger.Errorf("failed to insert request: %v", err)
+	} else {
+		response.RequestId = id
+		if err := h.p.Usecase.SaveResponse(ctx, response); err != nil {
+			h.p.Logger.Errorf("error while saving response: %v", err)
+		}
+	}
+	cancel()
+
+	for message := range h.messages {
+		if id == 0 {
+			continue
+		}
+		message.RequestId = id
+		ctx, cancel := context.WithTimeout(context.Background(), wsSaveTimeout)
+		if err := h.p.Usecase.SaveWebSocketMessage(ctx, message); err != nil {
+			h.p.Logger.Errorf("failed to save websocket message: %v", err)
+		}
+		cancel()
+	}
+}
+
+type wsFrame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// readWebSocketFrame parses one RFC 6455 frame, unmasking the captured
+// payload. Payload bytes beyond wsMaxCapture are consumed but not kept.
+func readWebSocketFrame(r io.Reader) (*wsFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	frame := &wsFrame{
+		fin:    header[0]&0x80 != 0,
+		opcode: header[0] & 0x0f,
+	}
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+		if length > 1<<62 {
+			return nil, fmt.Errorf("invalid websocket frame length %d", length)
+		}
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	keep := length
+	if keep > wsMaxCapture {
+		keep = wsMaxCapture
+	}
+	frame.payload = make([]byte, keep)
+	if _, err := io.ReadFull(r, frame.payload); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(length-keep)); err != nil {
+		return nil, err
+	}
+
+	if masked {
+		for i := range frame.payload {
+			frame.payload[i] ^= mask[i%4]
+		}
+	}
+	return frame, nil
+}
+
+func appendCapped(dst, src []byte) []byte {
+	if room := wsMaxCapture - len(dst); room < len(src) {
+		if room <= 0 {
+			return dst
+		}
+		src = src[:room]
+	}
+	return append(dst, src...)
+}
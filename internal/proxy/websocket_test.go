@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"proxy/internal/api/usecase"
+	"proxy/internal/models"
+)
+
+// historyUsecase holds the upgrade request until release is closed and
+// passes saved messages on.
+type historyUsecase struct {
+	usecase.Usecase
+	release  chan struct{}
+	messages chan models.WebSocketMessage
+}
+
+func (u *historyUsecase) SaveRequest(ctx context.Context, request models.Request) (uint64, error) {
+	<-u.release
+	return 7, nil
+}
+
+func (u *historyUsecase) SaveResponse(ctx context.Context, response models.Response) error {
+	return nil
+}
+
+func (u *historyUsecase) SaveWebSocketMessage(ctx context.Context, message models.WebSocketMessage) error {
+	u.messages <- message
+	return nil
+}
+
+func TestWebSocketHistory(t *testing.T) {
+	u := &historyUsecase{release: make(chan struct{}), messages: make(chan models.WebSocketMessage, 2*wsMaxPending)}
+	p := &Proxy{Usecase: u, Logger: testLogger()}
+
+	// A database that hangs on the upgrade must not hold the relay up.
+	h := p.newWebSocketHistory(models.Request{Host: "ws.example"}, models.Response{Code: 101})
+	recorded := make(chan struct{})
+	go func() {
+		for i := 0; i < wsMaxPending+5; i++ {
+			h.record(models.WebSocketMessage{Direction: "client", Payload: []byte{byte(i)}})
+		}
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("record blocked on the database")
+	}
+	if got := h.dropped.Load(); got != 5 {
+		t.Errorf("dropped %d messages past the queue, want 5", got)
+	}
+
+	close(u.release)
+	h.close()
+	for i := 0; i < wsMaxPending; i++ {
+		select {
+		case m := <-u.messages:
+			if m.RequestId != 7 || m.Payload[0] != byte(i) {
+				t.Fatalf("message %d saved as request %d payload %v", i, m.RequestId, m.Payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d messages saved", i, wsMaxPending)
+		}
+	}
+
+	var outOfScope *wsHistory
+	outOfScope.record(models.WebSocketMessage{Direction: "server"})
+}
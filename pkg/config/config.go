@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"reflect"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
@@ -23,9 +24,67 @@ type (
 		Ssl      string `yaml:"ssl"`
 	}
 
+	// Storage picks where the history is kept: "postgres" (the default)
+	// uses Database, "sqlite" the single project file at Path and "memory"
+	// nothing that outlives the process.
+	Storage struct {
+		Driver string `yaml:"driver"`
+		Path   string `yaml:"path"`
+	}
+
 	Proxy struct {
-		Addr string `yaml:"addr"`
-		Port string `yaml:"port"`
+		Addr        string    `yaml:"addr"`
+		Port        string    `yaml:"port"`
+		InfoHost    string    `yaml:"info_host" mapstructure:"info_host"`
+		PublicAddr  string    `yaml:"public_addr" mapstructure:"public_addr"`
+		MaxBodySize int64     `yaml:"max_body_size" mapstructure:"max_body_size"`
+		CertCache   CertCache `yaml:"cert_cache" mapstructure:"cert_cache"`
+		Recorder    Recorder  `yaml:"recorder"`
+		Intercept   Intercept `yaml:"intercept"`
+
+		// Passthrough lists host globs such as *.bank.example whose
+		// CONNECT tunnels are relayed without being decrypted, on top of
+		// the hosts enabled through the passthrough api.
+		Passthrough []string `yaml:"passthrough"`
+
+		// Upstream lists the proxies outgoing traffic is chained through.
+		Upstream []Upstream `yaml:"upstream"`
+	}
+
+	// Upstream sends traffic to hosts matching the Host glob through the
+	// proxy at URL: http://, https:// or socks5://, with credentials as
+	// user:password@ when it wants them. The first match wins, an entry
+	// without Host matches every host and one without URL goes direct.
+	Upstream struct {
+		Host string `yaml:"host"`
+		URL  string `yaml:"url"`
+	}
+
+	CertCache struct {
+		Size int    `yaml:"size"`
+		Dir  string `yaml:"dir"`
+	}
+
+	// Recorder tunes how captured traffic is queued and written to the
+	// database. Policy is "block" to slow traffic down while the database
+	// catches up, or "drop" to discard exchanges when the queue is full.
+	Recorder struct {
+		QueueSize     int           `yaml:"queue_size" mapstructure:"queue_size"`
+		Workers       int           `yaml:"workers"`
+		BatchSize     int           `yaml:"batch_size" mapstructure:"batch_size"`
+		FlushInterval time.Duration `yaml:"flush_interval" mapstructure:"flush_interval"`
+		Policy        string        `yaml:"policy"`
+	}
+
+	// Intercept is the initial state of interception, which the intercept
+	// api changes at runtime. The proxy serves that api on Addr and the api
+	// server forwards /api/intercept there.
+	Intercept struct {
+		Addr      string        `yaml:"addr"`
+		Enabled   bool          `yaml:"enabled"`
+		Responses bool          `yaml:"responses"`
+		Filter    string        `yaml:"filter"`
+		Timeout   time.Duration `yaml:"timeout"`
 	}
 
 	Logger struct {
@@ -36,6 +95,7 @@ type (
 type Config struct {
 	Server   Server   `yaml:"server"`
 	Database Database `yaml:"database"`
+	Storage  Storage  `yaml:"storage"`
 	Proxy    Proxy    `yaml:"proxy"`
 	Logger   Logger   `yaml:"logger"`
 }
@@ -50,7 +110,10 @@ func GetConfig(cfgPath string) (Config, error) {
 	}
 
 	cfg := &Config{}
-	if err := viper.Unmarshal(&cfg, viper.DecodeHook(StringExpandEnv())); err != nil {
+	if err := viper.Unmarshal(&cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		StringExpandEnv(),
+		mapstructure.StringToTimeDurationHookFunc(),
+	))); err != nil {
 		return Config{}, err
 	}
 
@@ -0,0 +1,211 @@
+package filterexpr
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Comparison operators.
+const (
+	OpEq       = "=="
+	OpNe       = "!="
+	OpMatch    = "~"
+	OpNotMatch = "!~"
+	OpContains = "contains"
+	OpLt       = "<"
+	OpLe       = "<="
+	OpGt       = ">"
+	OpGe       = ">="
+)
+
+// Node is a parsed expression: *And, *Or, *Not, *Compare or *Exists.
+type Node interface {
+	Pos() int
+	String() string
+}
+
+type And struct {
+	Left, Right Node
+}
+
+type Or struct {
+	Left, Right Node
+}
+
+type Not struct {
+	X   Node
+	pos int
+}
+
+// Compare tests a field against a literal.
+type Compare struct {
+	Field Field
+	Op    string
+	Value Value
+
+	re *regexp.Regexp
+}
+
+// Exists is a field on its own: true when a keyed field is present or a
+// plain field is non-empty.
+type Exists struct {
+	Field Field
+}
+
+func (n *And) Pos() int     { return n.Left.Pos() }
+func (n *Or) Pos() int      { return n.Left.Pos() }
+func (n *Not) Pos() int     { return n.pos }
+func (n *Compare) Pos() int { return n.Field.Pos }
+func (n *Exists) Pos() int  { return n.Field.Pos }
+
+func (n *And) String() string { return "(" + n.Left.String() + " and " + n.Right.String() + ")" }
+func (n *Or) String() string  { return "(" + n.Left.String() + " or " + n.Right.String() + ")" }
+func (n *Not) String() string { return "not " + n.X.String() }
+func (n *Compare) String() string {
+	return n.Field.String() + " " + n.Op + " " + n.Value.String()
+}
+func (n *Exists) String() string { return n.Field.String() }
+
+// Uses tells whether node refers to any of the fields named, by canonical
+// name.
+func Uses(node Node, names ...string) bool {
+	switch n := node.(type) {
+	case *And:
+		return Uses(n.Left, names...) || Uses(n.Right, names...)
+	case *Or:
+		return Uses(n.Left, names...) || Uses(n.Right, names...)
+	case *Not:
+		return Uses(n.X, names...)
+	case *Compare:
+		return slices.Contains(names, n.Field.Name)
+	case *Exists:
+		return slices.Contains(names, n.Field.Name)
+	}
+	return false
+}
+
+// Field is a resolved field reference, Name being the canonical name from
+// Fields and Key the bracketed key of keyed fields.
+type Field struct {
+	Name string
+	Key  string
+	Pos  int
+}
+
+// Info returns the field's definition.
+func (f Field) Info() FieldInfo {
+	return Fields[f.Name]
+}
+
+func (f Field) String() string {
+	if f.Info().Keyed {
+		return f.Name + "[" + strconv.Quote(f.Key) + "]"
+	}
+	return f.Name
+}
+
+type ValueKind int
+
+const (
+	StringValue ValueKind = iota
+	NumberValue
+	TimeValue
+)
+
+// Value is a literal, already converted to the type of the field it is
+// compared with.
+type Value struct {
+	Kind ValueKind
+	Str  string
+	Num  int64
+	Time time.Time
+	Pos  int
+}
+
+func (v Value) String() string {
+	switch v.Kind {
+	case NumberValue:
+		return strconv.FormatInt(v.Num, 10)
+	case TimeValue:
+		return strconv.Quote(v.Time.Format(time.RFC3339Nano))
+	}
+	return strconv.Quote(v.Str)
+}
+
+// FieldInfo describes what a field holds.
+type FieldInfo struct {
+	Type ValueKind
+	// Keyed fields are maps indexed with field["key"].
+	Keyed bool
+	// Multi fields may hold several values per key, a comparison holds when
+	// any of them matches.
+	Multi bool
+	// CaseInsensitiveKey marks keys that are header names.
+	CaseInsensitiveKey bool
+}
+
+// Fields lists every field of the language by canonical name.
+var Fields = map[string]FieldInfo{
+	"id":          {Type: NumberValue},
+	"created_at":  {Type: TimeValue},
+	"req.method":  {Type: StringValue},
+	"req.host":    {Type: StringValue},
+	"req.path":    {Type: StringValue},
+	"req.proto":   {Type: StringValue},
+	"req.body":    {Type: StringValue},
+	"req.mime":    {Type: StringValue},
+	"req.size":    {Type: NumberValue},
+	"req.header":  {Type: StringValue, Keyed: true, Multi: true, CaseInsensitiveKey: true},
+	"req.query":   {Type: StringValue, Keyed: true, Multi: true},
+	"req.form":    {Type: StringValue, Keyed: true, Multi: true},
+	"req.cookie":  {Type: StringValue, Keyed: true},
+	"resp.status": {Type: NumberValue},
+	"resp.proto":  {Type: StringValue},
+	"resp.body":   {Type: StringValue},
+	"resp.mime":   {Type: StringValue},
+	"resp.size":   {Type: NumberValue},
+	"resp.header": {Type: StringValue, Keyed: true, Multi: true, CaseInsensitiveKey: true},
+}
+
+// aliases are accepted shorthands for canonical field names.
+var aliases = map[string]string{
+	"method":       "req.method",
+	"host":         "req.host",
+	"path":         "req.path",
+	"status":       "resp.status",
+	"request.":     "req.",
+	"response.":    "resp.",
+	"req.headers":  "req.header",
+	"req.cookies":  "req.cookie",
+	"req.params":   "req.query",
+	"resp.code":    "resp.status",
+	"resp.headers": "resp.header",
+}
+
+func resolveField(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for _, prefix := range []string{"request.", "response."} {
+		if strings.HasPrefix(name, prefix) {
+			name = aliases[prefix] + strings.TrimPrefix(name, prefix)
+		}
+	}
+	if canonical, ok := aliases[name]; ok {
+		name = canonical
+	}
+	_, ok := Fields[name]
+	return name, ok
+}
+
+// Error is a parse error at a byte offset of the expression.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos+1, e.Msg)
+}
@@ -0,0 +1,93 @@
+package filterexpr
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Record exposes one exchange to Match. Lookup returns the values a field
+// holds, of the field's type; a missing field, key or response has none.
+type Record interface {
+	Lookup(field Field) []Value
+}
+
+// Negated reports whether op is the negation of a positive operator, and
+// returns that operator. Backends evaluate a negated comparison as "not
+// (positive comparison)", where a positive comparison only holds if some
+// value of the field satisfies it.
+func Negated(op string) (string, bool) {
+	switch op {
+	case OpNe:
+		return OpEq, true
+	case OpNotMatch:
+		return OpMatch, true
+	}
+	return op, false
+}
+
+// Match evaluates node against r.
+func Match(node Node, r Record) bool {
+	switch n := node.(type) {
+	case *And:
+		return Match(n.Left, r) && Match(n.Right, r)
+	case *Or:
+		return Match(n.Left, r) || Match(n.Right, r)
+	case *Not:
+		return !Match(n.X, r)
+	case *Exists:
+		for _, v := range r.Lookup(n.Field) {
+			if n.Field.Info().Keyed || v.Kind != StringValue || v.Str != "" {
+				return true
+			}
+		}
+		return false
+	case *Compare:
+		op, negated := Negated(n.Op)
+		matched := false
+		for _, v := range r.Lookup(n.Field) {
+			if compare(v, op, n.Value, n.re) {
+				matched = true
+				break
+			}
+		}
+		return matched != negated
+	}
+	return false
+}
+
+func compare(v Value, op string, lit Value, re *regexp.Regexp) bool {
+	var c int
+	switch lit.Kind {
+	case NumberValue:
+		switch {
+		case v.Num < lit.Num:
+			c = -1
+		case v.Num > lit.Num:
+			c = 1
+		}
+	case TimeValue:
+		c = v.Time.Compare(lit.Time)
+	default:
+		switch op {
+		case OpMatch:
+			return re != nil && re.MatchString(v.Str)
+		case OpContains:
+			return strings.Contains(v.Str, lit.Str)
+		}
+		c = strings.Compare(v.Str, lit.Str)
+	}
+
+	switch op {
+	case OpEq:
+		return c == 0
+	case OpLt:
+		return c < 0
+	case OpLe:
+		return c <= 0
+	case OpGt:
+		return c > 0
+	case OpGe:
+		return c >= 0
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package filterexpr
+
+import "proxy/internal/models"
+
+// Exchange exposes a stored request and the response it got, if any, to
+// Match.
+type Exchange struct {
+	Request  *models.Request
+	Response *models.Response
+}
+
+func (r Exchange) Lookup(field Field) []Value {
+	req := r.Request
+	switch field.Name {
+	case "id":
+		return number(int64(req.Id))
+	case "created_at":
+		return []Value{{Kind: TimeValue, Time: req.CreatedAt}}
+	case "req.method":
+		return text(req.Method)
+	case "req.host":
+		return text(req.Host)
+	case "req.path":
+		return text(req.Path)
+	case "req.proto":
+		return text(req.Proto)
+	case "req.body":
+		return text(req.DecodedBody)
+	case "req.mime":
+		return text(req.MimeType)
+	case "req.size":
+		return number(req.BodySize)
+	case "req.header":
+		return text(req.Headers[field.Key]...)
+	case "req.query":
+		return text(req.Get_Params[field.Key]...)
+	case "req.form":
+		return text(req.Post_Params[field.Key]...)
+	case "req.cookie":
+		if v, ok := req.Cookies[field.Key]; ok {
+			return text(v)
+		}
+		return nil
+	}
+
+	resp := r.Response
+	if resp == nil {
+		return nil
+	}
+	switch field.Name {
+	case "resp.status":
+		return number(int64(resp.Code))
+	case "resp.proto":
+		return text(resp.Proto)
+	case "resp.body":
+		return text(resp.DecodedBody)
+	case "resp.mime":
+		return text(resp.MimeType)
+	case "resp.size":
+		return number(resp.BodySize)
+	case "resp.header":
+		return text(resp.Headers[field.Key]...)
+	}
+	return nil
+}
+
+func text(values ...string) []Value {
+	out := make([]Value, len(values))
+	for i, v := range values {
+		out[i] = Value{Kind: StringValue, Str: v}
+	}
+	return out
+}
+
+func number(n int64) []Value {
+	return []Value{{Kind: NumberValue, Num: n}}
+}
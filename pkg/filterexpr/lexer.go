@@ -0,0 +1,200 @@
+package filterexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of expression"
+	case tokIdent:
+		return "field"
+	case tokString:
+		return "string"
+	case tokNumber:
+		return "number"
+	case tokOp:
+		return "operator"
+	case tokAnd:
+		return "and"
+	case tokOr:
+		return "or"
+	case tokNot:
+		return "not"
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	case tokLBracket:
+		return "["
+	case tokRBracket:
+		return "]"
+	}
+	return "token"
+}
+
+type token struct {
+	kind tokenKind
+	text string // identifier, operator, or the unquoted string value
+	pos  int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return t.kind.String()
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits src into tokens, the last one always being tokEOF.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: start})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: start})
+			i++
+		case r == '[':
+			tokens = append(tokens, token{kind: tokLBracket, text: "[", pos: start})
+			i++
+		case r == ']':
+			tokens = append(tokens, token{kind: tokRBracket, text: "]", pos: start})
+			i++
+		case r == '"' || r == '\'':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, &Error{Pos: start, Msg: err.Error()}
+			}
+			tokens = append(tokens, token{kind: tokString, text: s, pos: start})
+			i += n
+		case r >= '0' && r <= '9':
+			for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start})
+		case r == '_' || unicode.IsLetter(r):
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			word := src[start:i]
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, token{kind: tokAnd, text: word, pos: start})
+			case "or":
+				tokens = append(tokens, token{kind: tokOr, text: word, pos: start})
+			case "not":
+				tokens = append(tokens, token{kind: tokNot, text: word, pos: start})
+			case "contains":
+				tokens = append(tokens, token{kind: tokOp, text: OpContains, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+			}
+		default:
+			tok, ok := lexSymbol(src[i:])
+			if !ok {
+				return nil, &Error{Pos: start, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+			tok.pos = start
+			tokens = append(tokens, tok)
+			i += len(tok.text)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+var symbols = []struct {
+	text string
+	kind tokenKind
+}{
+	// Longest first so "!=" is not read as "!" followed by "=".
+	{"&&", tokAnd}, {"||", tokOr},
+	{"==", tokOp}, {"!=", tokOp}, {"!~", tokOp}, {"<=", tokOp}, {">=", tokOp},
+	{"=", tokOp}, {"~", tokOp}, {"<", tokOp}, {">", tokOp},
+	{"!", tokNot},
+}
+
+func lexSymbol(s string) (token, bool) {
+	for _, sym := range symbols {
+		if strings.HasPrefix(s, sym.text) {
+			text := sym.text
+			if text == "=" {
+				text = OpEq
+			}
+			return token{kind: sym.kind, text: text}, true
+		}
+	}
+	return token{}, false
+}
+
+// lexString reads a quoted string at the start of s and returns its value
+// and the number of bytes consumed. A backslash escapes the quote, another
+// backslash, and n, r and t; before anything else it is kept as is, so
+// regular expressions such as "api\." need no double escaping.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case quote, '\\':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
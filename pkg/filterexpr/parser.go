@@ -0,0 +1,246 @@
+// Package filterexpr implements the history filter language, for example
+//
+//	host ~ "api\." and resp.status >= 500 and not req.header["Authorization"]
+//
+// Comparisons take a field on the left and a literal on the right. Strings
+// support ==, !=, ~ and !~ (regular expressions) and contains; numbers and
+// created_at also support <, <=, > and >=. A field on its own tests that it
+// is present (keyed fields) or non-empty. Comparisons combine with and, or,
+// not (also written &&, || and !) and parentheses.
+//
+// Parse produces a syntax tree which storage backends translate into their
+// own queries; Match evaluates it directly.
+package filterexpr
+
+import (
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse parses src into an expression. Errors are *Error values carrying
+// the offending position.
+func Parse(src string) (Node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, &Error{Pos: 0, Msg: "empty expression"}
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s, expected and, or or end of expression", tok.describe())}
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, &Error{Pos: tok.pos, Msg: fmt.Sprintf("expected %s, got %s", kind, tok.describe())}
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if tok := p.peek(); tok.kind == tokNot {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x, pos: tok.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokLParen:
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case tokIdent:
+		return p.parseCondition()
+	}
+	return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("expected field, not or (, got %s", tok.describe())}
+}
+
+func (p *parser) parseCondition() (Node, error) {
+	field, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.peek()
+	if opTok.kind != tokOp {
+		return &Exists{Field: field}, nil
+	}
+	p.next()
+
+	info := field.Info()
+	switch opTok.text {
+	case OpLt, OpLe, OpGt, OpGe:
+		if info.Type == StringValue {
+			return nil, &Error{Pos: opTok.pos, Msg: fmt.Sprintf("%s can't be compared with %s, it is not a number or time", field.Name, opTok.text)}
+		}
+	case OpMatch, OpNotMatch, OpContains:
+		if info.Type != StringValue {
+			return nil, &Error{Pos: opTok.pos, Msg: fmt.Sprintf("%s works on text, %s is not", opTok.text, field.Name)}
+		}
+	}
+
+	value, err := p.parseValue(info.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp := &Compare{Field: field, Op: opTok.text, Value: value}
+	if opTok.text == OpMatch || opTok.text == OpNotMatch {
+		if cmp.re, err = regexp.Compile(value.Str); err != nil {
+			return nil, &Error{Pos: value.Pos, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+		}
+	}
+
+	return cmp, nil
+}
+
+func (p *parser) parseField() (Field, error) {
+	tok := p.next()
+	name, ok := resolveField(tok.text)
+	if !ok {
+		return Field{}, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unknown field %q", tok.text)}
+	}
+
+	field := Field{Name: name, Pos: tok.pos}
+	info := field.Info()
+
+	if p.peek().kind != tokLBracket {
+		if info.Keyed {
+			return Field{}, &Error{Pos: tok.pos, Msg: fmt.Sprintf("%s needs a key, e.g. %s[\"name\"]", name, name)}
+		}
+		return field, nil
+	}
+
+	bracket := p.next()
+	if !info.Keyed {
+		return Field{}, &Error{Pos: bracket.pos, Msg: fmt.Sprintf("%s does not take a key", name)}
+	}
+
+	key, err := p.expect(tokString)
+	if err != nil {
+		return Field{}, err
+	}
+	if _, err := p.expect(tokRBracket); err != nil {
+		return Field{}, err
+	}
+
+	field.Key = key.text
+	if info.CaseInsensitiveKey {
+		field.Key = textproto.CanonicalMIMEHeaderKey(key.text)
+	}
+	return field, nil
+}
+
+func (p *parser) parseValue(kind ValueKind) (Value, error) {
+	tok := p.next()
+	value := Value{Kind: kind, Pos: tok.pos}
+
+	switch kind {
+	case NumberValue:
+		if tok.kind != tokNumber {
+			return value, &Error{Pos: tok.pos, Msg: fmt.Sprintf("expected number, got %s", tok.describe())}
+		}
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return value, &Error{Pos: tok.pos, Msg: fmt.Sprintf("number %s out of range", tok.text)}
+		}
+		value.Num = n
+	case TimeValue:
+		if tok.kind != tokString {
+			return value, &Error{Pos: tok.pos, Msg: fmt.Sprintf("expected quoted RFC 3339 time, got %s", tok.describe())}
+		}
+		t, err := time.Parse(time.RFC3339, tok.text)
+		if err != nil {
+			return value, &Error{Pos: tok.pos, Msg: fmt.Sprintf("invalid time %q, expected RFC 3339", tok.text)}
+		}
+		value.Time = t
+	default:
+		if tok.kind != tokString {
+			hint := ""
+			if tok.kind == tokIdent || tok.kind == tokNumber {
+				hint = fmt.Sprintf(", quote it as \"%s\"", strings.ReplaceAll(tok.text, `"`, `\"`))
+			}
+			return value, &Error{Pos: tok.pos, Msg: fmt.Sprintf("expected string, got %s%s", tok.describe(), hint)}
+		}
+		value.Str = tok.text
+	}
+
+	return value, nil
+}
@@ -0,0 +1,202 @@
+package filterexpr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`host ~ "api."`, `req.host ~ "api."`},
+		{`method == 'POST'`, `req.method == "POST"`},
+		{`method = "GET"`, `req.method == "GET"`},
+		{`resp.status >= 500`, `resp.status >= 500`},
+		{`status < 300`, `resp.status < 300`},
+		{`req.header["authorization"]`, `req.header["Authorization"]`},
+		{`req.query["ID"] == "1"`, `req.query["ID"] == "1"`},
+		{`resp.body contains "token"`, `resp.body contains "token"`},
+		{`request.path !~ "^/static/"`, `req.path !~ "^/static/"`},
+		{`created_at >= "2024-01-02T03:04:05Z"`, `created_at >= "2024-01-02T03:04:05Z"`},
+		{`not req.header["Cookie"]`, `not req.header["Cookie"]`},
+		{`! resp.body`, `not resp.body`},
+		{
+			`host ~ "api." and resp.status >= 500 and not req.header["Authorization"]`,
+			`((req.host ~ "api." and resp.status >= 500) and not req.header["Authorization"])`,
+		},
+		{
+			`method == "GET" or method == "HEAD" and status == 200`,
+			`(req.method == "GET" or (req.method == "HEAD" and resp.status == 200))`,
+		},
+		{
+			`(method == "GET" || method == "HEAD") && status == 200`,
+			`((req.method == "GET" or req.method == "HEAD") and resp.status == 200)`,
+		},
+		{`path == "say \"hi\""`, `req.path == "say \"hi\""`},
+		{`path == 'it\'s'`, `req.path == "it's"`},
+		{`host ~ "api\.example"`, `req.host ~ "api\\.example"`},
+		{`path == "a\\b\tc"`, `req.path == "a\\b\tc"`},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error %v", tt.src, err)
+			continue
+		}
+		if got := node.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{``, 0, `empty expression`},
+		{`   `, 0, `empty expression`},
+		{`foo == "x"`, 0, `unknown field "foo"`},
+		{`host == "x" and`, 15, `expected field, not or (, got end of expression`},
+		{`host == `, 8, `expected string, got end of expression`},
+		{`host == api`, 8, `expected string, got "api", quote it as "api"`},
+		{`status >= "500"`, 10, `expected number, got "500"`},
+		{`host > "a"`, 5, `req.host can't be compared with >, it is not a number or time`},
+		{`status ~ "5.."`, 7, `~ works on text, resp.status is not`},
+		{`req.header == "x"`, 0, `req.header needs a key, e.g. req.header["name"]`},
+		{`host["x"]`, 4, `req.host does not take a key`},
+		{`req.header[x]`, 11, `expected string, got "x"`},
+		{`req.header["x" == "y"`, 15, `expected ], got "=="`},
+		{`(host == "x"`, 12, `expected ), got end of expression`},
+		{`host == "x")`, 11, `unexpected ")", expected and, or or end of expression`},
+		{`host == "x`, 8, `unterminated string`},
+		{`host ~ "("`, 7, "invalid regular expression: error parsing regexp: missing closing ): `(`"},
+		{`created_at > "yesterday"`, 13, `invalid time "yesterday", expected RFC 3339`},
+		{`host == "x" # comment`, 12, `unexpected character '#'`},
+		{`status == 99999999999999999999`, 10, `number 99999999999999999999 out of range`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q): got %v, want *Error", tt.src, err)
+			continue
+		}
+		if perr.Pos != tt.pos || perr.Msg != tt.msg {
+			t.Errorf("Parse(%q): got error at %d %q, want at %d %q", tt.src, perr.Pos, perr.Msg, tt.pos, tt.msg)
+		}
+	}
+}
+
+func TestErrorString(t *testing.T) {
+	_, err := Parse(`host == "x" and`)
+	if got, want := err.Error(), "column 16: expected field, not or (, got end of expression"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+type testRecord map[string][]Value
+
+func (r testRecord) Lookup(f Field) []Value {
+	return r[f.String()]
+}
+
+func strs(values ...string) []Value {
+	out := make([]Value, len(values))
+	for i, v := range values {
+		out[i] = Value{Kind: StringValue, Str: v}
+	}
+	return out
+}
+
+func TestUses(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`resp.body contains "token"`, true},
+		{`status == 200 and not response.size > 10`, true},
+		{`method == "GET" or resp.mime`, true},
+		{`status == 200 and req.body contains "x"`, false},
+		{`resp.header["Content-Type"] ~ "json"`, false},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := Uses(node, "resp.body", "resp.size", "resp.mime"); got != tt.want {
+			t.Errorf("Uses(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	record := testRecord{
+		"req.method":              strs("POST"),
+		"req.host":                strs("api.example.com"),
+		"req.path":                strs("/v1/login"),
+		"req.body":                strs(""),
+		`req.header["Accept"]`:    strs("text/html", "application/json"),
+		`req.header["X-Token"]`:   strs("abc"),
+		`req.cookie["session"]`:   strs("s1"),
+		"resp.status":             {{Kind: NumberValue, Num: 502}},
+		"resp.body":               strs(`{"error":"bad gateway"}`),
+		"created_at":              {{Kind: TimeValue, Time: created}},
+		`resp.header["Location"]`: nil,
+	}
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`host ~ "api\."`, true},
+		{`host ~ "^www\."`, false},
+		{`host !~ "^www\."`, true},
+		{`method == "POST"`, true},
+		{`method != "POST"`, false},
+		{`status >= 500 and status < 600`, true},
+		{`status == 200`, false},
+		{`status != 200`, true},
+		{`req.header["accept"] == "application/json"`, true},
+		{`req.header["Accept"] ~ "xml"`, false},
+		{`req.header["Accept"] != "text/plain"`, true},
+		{`req.header["Authorization"]`, false},
+		{`not req.header["Authorization"]`, true},
+		{`req.header["X-Token"]`, true},
+		{`req.header["Authorization"] != "x"`, true},
+		{`req.header["Authorization"] == "x"`, false},
+		{`req.body`, false},
+		{`resp.body`, true},
+		{`resp.body contains "gateway"`, true},
+		{`req.cookie["session"] == "s1"`, true},
+		{`resp.header["Location"]`, false},
+		{`created_at >= "2024-01-01T00:00:00Z"`, true},
+		{`created_at < "2024-01-02T03:04:05Z"`, false},
+		{`host ~ "api." and status >= 500 and not req.header["Authorization"]`, true},
+		{`method == "GET" or path contains "login"`, true},
+		{`not (method == "GET" or path contains "login")`, false},
+		{`resp.size > 0`, false},
+		{`not resp.size > 0`, true},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := Match(node, record); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package har
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxy/internal/models"
+
+	reqUtils "proxy/pkg/http"
+)
+
+// maxDecodedBody bounds what a stored body may inflate to when exported.
+const maxDecodedBody = 64 << 20
+
+var creator = Creator{Name: "proxy", Version: "1.0"}
+
+// New returns an archive of entries.
+func New(entries []Entry) *HAR {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return &HAR{Log: Log{Version: Version, Creator: creator, Entries: entries}}
+}
+
+// FromExchange converts a stored exchange. An exchange without a response
+// gets the empty response browsers write for failed requests.
+func FromExchange(ex models.Exchange) Entry {
+	req := ex.Request
+	entry := Entry{
+		StartedDateTime: req.CreatedAt,
+		Request:         fromRequest(req),
+		Response: Response{
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			Content:     Content{MimeType: "x-unknown"},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+
+	if resp := ex.Response; resp != nil {
+		entry.Response = fromResponse(resp)
+		entry.Time = float64(resp.DurationMs)
+		entry.Timings.Wait = float64(resp.WaitMs)
+		entry.Timings.Receive = float64(max(resp.DurationMs-resp.WaitMs, 0))
+	}
+	return entry
+}
+
+func fromRequest(req *models.Request) Request {
+	scheme := req.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     req.Host,
+		Path:     req.Path,
+		RawQuery: url.Values(req.Get_Params).Encode(),
+	}
+
+	out := Request{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []Cookie{},
+		Headers:     fromValues(req.Headers),
+		QueryString: fromValues(req.Get_Params),
+		HeadersSize: -1,
+		BodySize:    req.BodySize,
+	}
+
+	names := make([]string, 0, len(req.Cookies))
+	for name := range req.Cookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.Cookies = append(out.Cookies, Cookie{Name: name, Value: req.Cookies[name]})
+	}
+
+	if len(req.Body) > 0 || len(req.Post_Params) > 0 {
+		text, encoding, _ := exportBody(req.Body, req.DecodedBody, req.Headers)
+		out.PostData = &PostData{
+			MimeType: contentType(req.Headers, req.MimeType),
+			Params:   fromValues(req.Post_Params),
+			Text:     text,
+			Encoding: encoding,
+		}
+	}
+	return out
+}
+
+func fromResponse(resp *models.Response) Response {
+	header := http.Header(resp.Headers)
+	text, encoding, size := exportBody(resp.Body, resp.DecodedBody, resp.Headers)
+
+	out := Response{
+		Status:      resp.Code,
+		StatusText:  http.StatusText(resp.Code),
+		HTTPVersion: resp.Proto,
+		Cookies:     []Cookie{},
+		Headers:     fromValues(resp.Headers),
+		Content: Content{
+			Size:     size,
+			MimeType: contentType(resp.Headers, resp.MimeType),
+			Text:     text,
+			Encoding: encoding,
+		},
+		RedirectURL: header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    resp.BodySize,
+	}
+	if resp.BodySize > 0 && size > resp.BodySize {
+		out.Content.Compression = size - resp.BodySize
+	}
+
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		out.Cookies = append(out.Cookies, cookie)
+	}
+	return out
+}
+
+// exportBody returns a body with its content codings undone, as text when
+// it is text and base64 encoded otherwise, and its decoded size.
+func exportBody(raw []byte, decodedText string, headers map[string][]string) (text, encoding string, size int64) {
+	if decodedText != "" {
+		return decodedText, "", int64(len(decodedText))
+	}
+	if len(raw) == 0 {
+		return "", "", 0
+	}
+
+	decoded, _, _ := reqUtils.DecodeBody(raw, headers, maxDecodedBody)
+	if text, ok := reqUtils.DecodedText(decoded); ok {
+		return text, "", int64(len(decoded))
+	}
+	return base64.StdEncoding.EncodeToString(decoded), "base64", int64(len(decoded))
+}
+
+func contentType(headers map[string][]string, mimeType string) string {
+	if v := http.Header(headers).Get("Content-Type"); v != "" {
+		return v
+	}
+	return mimeType
+}
+
+// fromValues flattens a multi-valued map into pairs sorted by name.
+func fromValues(values map[string][]string) []NameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := []NameValue{}
+	for _, name := range names {
+		for _, v := range values[name] {
+			out = append(out, NameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+// ToExchange converts an archived entry. Entries with status 0, which is
+// how browsers record requests that got no answer, have no response.
+// Bodies are stored as the archive has them, after content decoding.
+func ToExchange(e Entry) (models.Exchange, error) {
+	req, err := toRequest(e)
+	if err != nil {
+		return models.Exchange{}, err
+	}
+	if e.Response.Status == 0 {
+		return models.Exchange{Request: req}, nil
+	}
+
+	resp, err := toResponse(e)
+	if err != nil {
+		return models.Exchange{}, err
+	}
+	return models.Exchange{Request: req, Response: resp}, nil
+}
+
+func toRequest(e Entry) (*models.Request, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("bad request url: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("request url %q has no host", e.Request.URL)
+	}
+	if e.Request.Method == "" {
+		return nil, fmt.Errorf("request to %s has no method", e.Request.URL)
+	}
+
+	header := toHeaders(e.Request.Headers)
+	req := &models.Request{
+		Method:      strings.ToUpper(e.Request.Method),
+		Scheme:      strings.ToLower(u.Scheme),
+		Path:        u.Path,
+		Host:        u.Host,
+		Get_Params:  toValues(e.Request.QueryString),
+		Headers:     header,
+		Cookies:     make(map[string]string),
+		Post_Params: make(map[string][]string),
+		Encodings:   []string{},
+		Proto:       normalizeProto(e.Request.HTTPVersion),
+		CreatedAt:   e.StartedDateTime,
+	}
+	if req.Path == "" {
+		req.Path = "/"
+	}
+	if len(e.Request.QueryString) == 0 {
+		req.Get_Params = u.Query()
+	}
+
+	for _, c := range e.Request.Cookies {
+		req.Cookies[c.Name] = c.Value
+	}
+	if len(req.Cookies) == 0 {
+		for _, c := range (&http.Request{Header: header}).Cookies() {
+			req.Cookies[c.Name] = c.Value
+		}
+	}
+
+	if post := e.Request.PostData; post != nil {
+		body, err := decodeText(post.Text, post.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+		req.Body = body
+		dropContentCoding(header, body)
+		req.Post_Params = toValues(post.Params)
+		if len(post.Params) == 0 && isForm(post.MimeType) {
+			if values, err := url.ParseQuery(string(body)); err == nil {
+				req.Post_Params = values
+			}
+		}
+		if len(body) > 0 {
+			req.MimeType = mediaType(post.MimeType, header, body)
+		}
+	}
+	req.DecodedBody, _ = reqUtils.DecodedText(req.Body)
+	req.BodySize = bodySize(e.Request.BodySize, req.Body)
+
+	return req, nil
+}
+
+func toResponse(e Entry) (*models.Response, error) {
+	body, err := decodeText(e.Response.Content.Text, e.Response.Content.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("response body: %w", err)
+	}
+
+	header := toHeaders(e.Response.Headers)
+	dropContentCoding(header, body)
+	resp := &models.Response{
+		Code:      e.Response.Status,
+		Headers:   header,
+		Body:      body,
+		Encodings: []string{},
+		Proto:     normalizeProto(e.Response.HTTPVersion),
+		BodySize:  bodySize(e.Response.BodySize, body),
+	}
+	resp.DecodedBody, _ = reqUtils.DecodedText(body)
+	if len(body) > 0 {
+		resp.MimeType = mediaType(e.Response.Content.MimeType, header, body)
+	}
+
+	t := e.Timings
+	wait := positive(t.Blocked) + positive(t.DNS) + positive(t.Connect) + positive(t.SSL) + positive(t.Send) + positive(t.Wait)
+	total := e.Time
+	if total <= 0 {
+		total = wait + positive(t.Receive)
+	}
+	resp.WaitMs = int64(math.Round(wait))
+	resp.DurationMs = int64(math.Round(total))
+	resp.CreatedAt = e.StartedDateTime.Add(time.Duration(wait * float64(time.Millisecond)))
+
+	return resp, nil
+}
+
+// toHeaders collects header pairs under their canonical names, the way
+// captured headers are kept. HTTP/2 pseudo headers are dropped, the
+// request line already holds them.
+func toHeaders(pairs []NameValue) map[string][]string {
+	header := make(http.Header)
+	for _, p := range pairs {
+		if strings.HasPrefix(p.Name, ":") {
+			continue
+		}
+		header.Add(p.Name, p.Value)
+	}
+	return header
+}
+
+// dropContentCoding fixes up the headers of a body the archive holds
+// decoded: it no longer has the content coding the headers name, nor
+// their length.
+func dropContentCoding(header http.Header, body []byte) {
+	if header.Get("Content-Encoding") != "" {
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+	}
+	if n := header.Get("Content-Length"); n != "" && n != strconv.Itoa(len(body)) {
+		header.Del("Content-Length")
+	}
+}
+
+func toValues(pairs []NameValue) map[string][]string {
+	values := make(map[string][]string)
+	for _, p := range pairs {
+		values[p.Name] = append(values[p.Name], p.Value)
+	}
+	return values
+}
+
+func decodeText(text, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "":
+		if text == "" {
+			return nil, nil
+		}
+		return []byte(text), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", encoding)
+}
+
+func mediaType(declared string, header http.Header, body []byte) string {
+	if mt, _, err := mime.ParseMediaType(declared); err == nil && mt != "x-unknown" && mt != "application/octet-stream" {
+		return mt
+	}
+	return reqUtils.DetectMimeType(header, body)
+}
+
+func isForm(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/x-www-form-urlencoded"
+}
+
+// bodySize takes the archived transfer size when there is one. Bodies
+// served from cache or of unknown size fall back to the content length.
+func bodySize(size int64, body []byte) int64 {
+	if size > 0 {
+		return size
+	}
+	return int64(len(body))
+}
+
+func normalizeProto(v string) string {
+	switch strings.ToLower(v) {
+	case "":
+		return ""
+	case "h2", "http/2", "http/2.0":
+		return "HTTP/2.0"
+	case "h3", "http/3", "http/3.0":
+		return "HTTP/3.0"
+	}
+	return strings.ToUpper(v)
+}
+
+func positive(ms float64) float64 {
+	return max(ms, 0)
+}
@@ -0,0 +1,182 @@
+package har
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"proxy/internal/models"
+)
+
+// roundTrip exports exchanges, encodes and decodes the archive and imports
+// it again.
+func roundTrip(t *testing.T, exchanges ...models.Exchange) (*HAR, []models.Exchange) {
+	t.Helper()
+	var entries []Entry
+	for _, ex := range exchanges {
+		entries = append(entries, FromExchange(ex))
+	}
+	data, err := json.Marshal(New(entries))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var archive HAR
+	if err := json.Unmarshal(data, &archive); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	var back []models.Exchange
+	for _, e := range archive.Log.Entries {
+		ex, err := ToExchange(e)
+		if err != nil {
+			t.Fatalf("ToExchange: %v", err)
+		}
+		back = append(back, ex)
+	}
+	return &archive, back
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTripHTTPS(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	page := "<p>hello</p>"
+	compressed := gzipped(t, page)
+	ex := models.Exchange{
+		Request: &models.Request{
+			Method:     "GET",
+			Scheme:     "https",
+			Host:       "shop.example",
+			Path:       "/search",
+			Get_Params: map[string][]string{"q": {"shoes"}},
+			Headers:    map[string][]string{"Cookie": {"session=s1"}, "Accept-Encoding": {"gzip"}},
+			Cookies:    map[string]string{"session": "s1"},
+			Proto:      "HTTP/2.0",
+			CreatedAt:  at,
+		},
+		Response: &models.Response{
+			Code: 200,
+			Headers: map[string][]string{
+				"Content-Encoding": {"gzip"},
+				"Content-Length":   {strconv.Itoa(len(compressed))},
+				"Content-Type":     {"text/html; charset=utf-8"},
+			},
+			Body:        compressed,
+			DecodedBody: page,
+			Encodings:   []string{"gzip"},
+			MimeType:    "text/html",
+			BodySize:    int64(len(compressed)),
+			Proto:       "HTTP/2.0",
+			WaitMs:      5,
+			DurationMs:  9,
+		},
+	}
+
+	archive, back := roundTrip(t, ex)
+	if got := archive.Log.Entries[0].Request.URL; got != "https://shop.example/search?q=shoes" {
+		t.Errorf("exported URL = %s, want the https one", got)
+	}
+
+	req, resp := back[0].Request, back[0].Response
+	if req.Scheme != "https" || req.Host != "shop.example" || req.Path != "/search" || req.Method != "GET" || req.Proto != "HTTP/2.0" {
+		t.Errorf("imported request = %s %s://%s%s %s", req.Method, req.Scheme, req.Host, req.Path, req.Proto)
+	}
+	if !reflect.DeepEqual(req.Get_Params, ex.Request.Get_Params) || !reflect.DeepEqual(req.Cookies, ex.Request.Cookies) {
+		t.Errorf("imported query %v and cookies %v", req.Get_Params, req.Cookies)
+	}
+	if !req.CreatedAt.Equal(at) {
+		t.Errorf("imported CreatedAt = %v, want %v", req.CreatedAt, at)
+	}
+
+	if resp == nil {
+		t.Fatal("imported exchange lost its response")
+	}
+	if string(resp.Body) != page || resp.DecodedBody != page || resp.MimeType != "text/html" || resp.Code != 200 {
+		t.Errorf("imported response %d %q (%q, %s)", resp.Code, resp.Body, resp.DecodedBody, resp.MimeType)
+	}
+	header := resp.Headers
+	if header["Content-Encoding"] != nil || header["Content-Length"] != nil {
+		t.Errorf("decoded body kept Content-Encoding %v and Content-Length %v", header["Content-Encoding"], header["Content-Length"])
+	}
+	if got := header["Content-Type"]; len(got) != 1 || got[0] != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %v", got)
+	}
+	if resp.WaitMs != 5 || resp.DurationMs != 9 || resp.BodySize != int64(len(compressed)) {
+		t.Errorf("imported timings %d/%d ms, size %d", resp.WaitMs, resp.DurationMs, resp.BodySize)
+	}
+}
+
+func TestRoundTripBodies(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0, 0, 0, 0}
+	form := "name=a+b&tag=x&tag=y"
+	ex := models.Exchange{
+		Request: &models.Request{
+			Method: "POST",
+			Host:   "upload.example:8080",
+			Path:   "/form",
+			Headers: map[string][]string{
+				"Content-Type":   {"application/x-www-form-urlencoded"},
+				"Content-Length": {strconv.Itoa(len(form))},
+			},
+			Body:        []byte(form),
+			DecodedBody: form,
+			Post_Params: map[string][]string{"name": {"a b"}, "tag": {"x", "y"}},
+			Proto:       "HTTP/1.1",
+		},
+		Response: &models.Response{
+			Code:     201,
+			Headers:  map[string][]string{"Content-Type": {"image/png"}},
+			Body:     png,
+			MimeType: "image/png",
+			BodySize: int64(len(png)),
+			Proto:    "HTTP/1.1",
+		},
+	}
+
+	archive, back := roundTrip(t, ex)
+	if got := archive.Log.Entries[0].Request.URL; got != "http://upload.example:8080/form" {
+		t.Errorf("exported URL = %s", got)
+	}
+	if got := archive.Log.Entries[0].Response.Content.Encoding; got != "base64" {
+		t.Errorf("binary body exported with encoding %q, want base64", got)
+	}
+
+	req, resp := back[0].Request, back[0].Response
+	if req.Scheme != "http" || string(req.Body) != form || !reflect.DeepEqual(req.Post_Params, ex.Request.Post_Params) {
+		t.Errorf("imported request %s body %q params %v", req.Scheme, req.Body, req.Post_Params)
+	}
+	if got := req.Headers["Content-Length"]; len(got) != 1 || got[0] != strconv.Itoa(len(form)) {
+		t.Errorf("request Content-Length = %v, want it kept since it still matches", got)
+	}
+	if !bytes.Equal(resp.Body, png) || resp.MimeType != "image/png" || resp.Code != 201 {
+		t.Errorf("imported response %d %q %s", resp.Code, resp.Body, resp.MimeType)
+	}
+}
+
+func TestRoundTripWithoutResponse(t *testing.T) {
+	ex := models.Exchange{Request: &models.Request{Method: "GET", Scheme: "https", Host: "down.example", Path: "/"}}
+
+	archive, back := roundTrip(t, ex)
+	if status := archive.Log.Entries[0].Response.Status; status != 0 {
+		t.Errorf("exported status = %d, want 0 for no response", status)
+	}
+	if back[0].Response != nil {
+		t.Errorf("imported a response %+v for an exchange that had none", back[0].Response)
+	}
+	if back[0].Request.Scheme != "https" || back[0].Request.Host != "down.example" {
+		t.Errorf("imported request %s://%s", back[0].Request.Scheme, back[0].Request.Host)
+	}
+}
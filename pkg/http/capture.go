@@ -0,0 +1,122 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxCapture is used when no capture limit is configured.
+const DefaultMaxCapture = 1 << 20
+
+// BodyCapture keeps a bounded copy of a body that streams past it. Bytes
+// beyond the limit are counted but not stored.
+type BodyCapture struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	max       int64
+	size      int64
+	truncated bool
+}
+
+func NewBodyCapture(max int64) *BodyCapture {
+	if max <= 0 {
+		max = DefaultMaxCapture
+	}
+	return &BodyCapture{max: max}
+}
+
+func (c *BodyCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size += int64(len(p))
+	if room := c.max - int64(c.buf.Len()); room < int64(len(p)) {
+		c.truncated = true
+		if room > 0 {
+			c.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+
+	return c.buf.Write(p)
+}
+
+// Bytes returns the captured prefix of the body.
+func (c *BodyCapture) Bytes() []byte {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.buf.Bytes())
+}
+
+// Size is the number of body bytes seen, captured or not.
+func (c *BodyCapture) Size() int64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Truncated reports whether the body was larger than the capture limit.
+func (c *BodyCapture) Truncated() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.truncated
+}
+
+// Limit is the capture size limit.
+func (c *BodyCapture) Limit() int64 {
+	if c == nil {
+		return DefaultMaxCapture
+	}
+	return c.max
+}
+
+// decode returns the decoded form of the captured body and the codings
+// that were undone to get it, starting with the transfer codings net/http
+// already removed. A coding that fails to decode is left out of the list,
+// so the markers show how far decoding got.
+func (c *BodyCapture) decode(header http.Header, transferEncoding []string) ([]byte, []string) {
+	applied := []string{}
+	for _, te := range transferEncoding {
+		applied = append(applied, strings.ToLower(te))
+	}
+
+	raw := c.Bytes()
+	if len(raw) == 0 {
+		return nil, applied
+	}
+
+	decoded, codings, _ := DecodeBody(raw, header, c.Limit())
+	return decoded, append(applied, codings...)
+}
+
+// TeeBody wraps body so that everything read from it is also captured, up
+// to max bytes. The returned reader must be used in place of body.
+func TeeBody(body io.ReadCloser, max int64) (io.ReadCloser, *BodyCapture) {
+	capture := NewBodyCapture(max)
+	if body == nil || body == http.NoBody {
+		return body, capture
+	}
+
+	return &teeBody{ReadCloser: body, r: io.TeeReader(body, capture)}, capture
+}
+
+type teeBody struct {
+	io.ReadCloser
+	r io.Reader
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	return t.r.Read(p)
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// DecodeBody undoes the content codings listed in header (last applied
+// first) and converts textual bodies to UTF-8. applied lists what was
+// removed, in the order it was undone, with charset conversions recorded
+// as "charset:<name>". Output is bounded by max bytes. On an unsupported
+// or broken coding the body decoded so far is returned with the error.
+func DecodeBody(raw []byte, header http.Header, max int64) (decoded []byte, applied []string, err error) {
+	if max <= 0 {
+		max = DefaultMaxCapture
+	}
+	decoded = raw
+
+	codings := contentCodings(header)
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := codings[i]
+
+		var r io.Reader
+		switch coding {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(decoded))
+		case "deflate":
+			r, err = deflateReader(decoded)
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(decoded))
+		case "identity":
+			continue
+		default:
+			return decoded, applied, fmt.Errorf("unsupported content encoding %q", coding)
+		}
+		if err != nil {
+			return decoded, applied, fmt.Errorf("%s: %w", coding, err)
+		}
+
+		// A capture cut at the size limit ends mid-stream, keep whatever
+		// could be inflated.
+		out, readErr := io.ReadAll(io.LimitReader(r, max))
+		if len(out) == 0 && readErr != nil {
+			return decoded, applied, fmt.Errorf("%s: %w", coding, readErr)
+		}
+		decoded = out
+		applied = append(applied, coding)
+	}
+
+	contentType := header.Get("Content-Type")
+	if !isTextual(contentType) {
+		return decoded, applied, nil
+	}
+
+	// Only HTML gets its charset sniffed, other text without an explicit
+	// charset parameter is taken to be UTF-8 already. A sniff that found
+	// no declaration falls back on windows-1252, which would garble UTF-8.
+	var enc encoding.Encoding
+	var name string
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if label, ok := params["charset"]; ok {
+		enc, name = charset.Lookup(label)
+	} else if mediaType == "text/html" {
+		var certain bool
+		enc, name, certain = charset.DetermineEncoding(decoded, contentType)
+		if !certain && utf8.Valid(decoded) {
+			enc = nil
+		}
+	}
+	if enc == nil || name == "utf-8" {
+		return decoded, applied, nil
+	}
+
+	utf8Body, convErr := io.ReadAll(io.LimitReader(enc.NewDecoder().Reader(bytes.NewReader(decoded)), max))
+	if convErr != nil {
+		return decoded, applied, fmt.Errorf("charset %s: %w", name, convErr)
+	}
+	return utf8Body, append(applied, "charset:"+name), nil
+}
+
+// DecodedText returns the decoded body as text when it is valid UTF-8
+// without NUL bytes, which is what makes it storable and searchable.
+func DecodedText(decoded []byte) (string, bool) {
+	if !utf8.Valid(decoded) || bytes.IndexByte(decoded, 0) >= 0 {
+		return "", false
+	}
+	return string(decoded), true
+}
+
+// DetectMimeType returns the media type of a body: the declared
+// Content-Type when there is a specific one, otherwise whatever the decoded
+// bytes sniff as. Empty bodies have no type.
+func DetectMimeType(header http.Header, decoded []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if len(decoded) == 0 {
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(decoded))
+	return mediaType
+}
+
+func contentCodings(header http.Header) []string {
+	var codings []string
+	for _, v := range header.Values("Content-Encoding") {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+				codings = append(codings, c)
+			}
+		}
+	}
+	return codings
+}
+
+// deflateReader accepts both zlib-wrapped and raw deflate streams, servers
+// disagree on what "deflate" means.
+func deflateReader(data []byte) (io.Reader, error) {
+	if r, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		return r, nil
+	}
+	return flate.NewReader(bytes.NewReader(data)), nil
+}
+
+func isTextual(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-javascript", "application/x-www-form-urlencoded",
+		"application/xhtml+xml", "application/ld+json", "application/graphql":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	page := []byte("<p>hello, world</p>")
+	long := bytes.Repeat([]byte("a"), 1000)
+	gzipped := compress(t, "gzip", page)
+
+	tests := []struct {
+		name    string
+		raw     []byte
+		header  http.Header
+		max     int64
+		want    string
+		applied []string
+		wantErr bool
+	}{
+		{"plain", page, http.Header{"Content-Type": {"text/html"}}, 0, string(page), nil, false},
+		{"gzip", gzipped, http.Header{"Content-Encoding": {"gzip"}}, 0, string(page), []string{"gzip"}, false},
+		{"x-gzip upper case", gzipped, http.Header{"Content-Encoding": {"X-GZIP"}}, 0, string(page), []string{"x-gzip"}, false},
+		{"zlib deflate", compress(t, "zlib", page), http.Header{"Content-Encoding": {"deflate"}}, 0, string(page), []string{"deflate"}, false},
+		{"raw deflate", compress(t, "flate", page), http.Header{"Content-Encoding": {"deflate"}}, 0, string(page), []string{"deflate"}, false},
+		{"br", compress(t, "br", page), http.Header{"Content-Encoding": {"br"}}, 0, string(page), []string{"br"}, false},
+		{
+			"stacked, last applied undone first",
+			compress(t, "gzip", compress(t, "br", page)),
+			http.Header{"Content-Encoding": {"br, identity", "gzip"}},
+			0, string(page), []string{"gzip", "br"}, false,
+		},
+		{
+			"declared charset",
+			[]byte("caf\xe9"),
+			http.Header{"Content-Type": {"text/plain; charset=ISO-8859-1"}},
+			0, "café", []string{"charset:windows-1252"}, false,
+		},
+		{
+			"sniffed html charset",
+			[]byte(`<meta charset="windows-1251"><p>` + "\xcf\xf0\xe8\xe2\xe5\xf2"),
+			http.Header{"Content-Type": {"text/html"}},
+			0, `<meta charset="windows-1251"><p>Привет`, []string{"charset:windows-1251"}, false,
+		},
+		{
+			"charset after gzip",
+			compress(t, "gzip", []byte("{\"name\": \"caf\xe9\"}")),
+			http.Header{"Content-Encoding": {"gzip"}, "Content-Type": {"application/json; charset=latin1"}},
+			0, `{"name": "café"}`, []string{"gzip", "charset:windows-1252"}, false,
+		},
+		{
+			"undeclared utf-8 html",
+			[]byte("<p>café"),
+			http.Header{"Content-Type": {"text/html"}},
+			0, "<p>café", nil, false,
+		},
+		{"binary keeps its bytes", []byte("caf\xe9"), http.Header{"Content-Type": {"image/png; charset=latin1"}}, 0, "caf\xe9", nil, false},
+		{"unsupported coding", page, http.Header{"Content-Encoding": {"zstd"}}, 0, string(page), nil, true},
+		{"corrupt gzip", []byte("not gzip at all"), http.Header{"Content-Encoding": {"gzip"}}, 0, "not gzip at all", nil, true},
+		{
+			"gzip cut at the capture limit",
+			gzipped[:len(gzipped)-8],
+			http.Header{"Content-Encoding": {"gzip"}},
+			0, string(page), []string{"gzip"}, false,
+		},
+		{"inflated past the limit", compress(t, "gzip", long), http.Header{"Content-Encoding": {"gzip"}}, 100, string(long[:100]), []string{"gzip"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, applied, err := DecodeBody(tt.raw, tt.header, tt.max)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decoded %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(applied, tt.applied) {
+				t.Errorf("applied %v, want %v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestDecodedText(t *testing.T) {
+	for _, tt := range []struct {
+		in string
+		ok bool
+	}{
+		{"héllo", true},
+		{"", true},
+		{"caf\xe9", false},
+		{"a\x00b", false},
+	} {
+		if _, ok := DecodedText([]byte(tt.in)); ok != tt.ok {
+			t.Errorf("DecodedText(%q) ok = %v, want %v", tt.in, ok, tt.ok)
+		}
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	for _, tt := range []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"application/json; charset=utf-8", "{}", "application/json"},
+		{"application/octet-stream", "<html><body>", "text/html"},
+		{"", "\x89PNG\r\n\x1a\n", "image/png"},
+		{"", "", ""},
+	} {
+		header := http.Header{}
+		if tt.contentType != "" {
+			header.Set("Content-Type", tt.contentType)
+		}
+		if got := DetectMimeType(header, []byte(tt.body)); got != tt.want {
+			t.Errorf("DetectMimeType(%q, %q) = %q, want %q", tt.contentType, strings.TrimSpace(tt.body), got, tt.want)
+		}
+	}
+}
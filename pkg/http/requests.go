@@ -1,18 +1,27 @@
 package http
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"proxy/internal/models"
-	"strings"
 )
 
-func ParseRequest(r http.Request) *models.Request {
+// ParseRequest converts r into its stored form. The body is taken from
+// body, the capture that was attached to r before it was sent, since r's
+// own body has been consumed by then.
+func ParseRequest(r http.Request, body *BodyCapture) *models.Request {
 	ri := &models.Request{
-		Method: r.Method,
-		Path:   r.URL.Path,
+		Method:    r.Method,
+		Scheme:    requestScheme(r),
+		Path:      r.URL.Path,
+		Proto:     r.Proto,
+		Body:      body.Bytes(),
+		BodySize:  body.Size(),
+		Truncated: body.Truncated(),
 	}
 
 	getParamVals := make(url.Values)
@@ -35,33 +44,54 @@ func ParseRequest(r http.Request) *models.Request {
 	}
 	ri.Cookies = cookies
 
-	if err := r.ParseForm(); err == nil {
-		postFormVals := make(url.Values)
-		for k, values := range r.PostForm {
-			postFormVals[k] = append(postFormVals[k], values...)
-		}
+	decoded, encodings := body.decode(r.Header, r.TransferEncoding)
+	ri.DecodedBody, _ = DecodedText(decoded)
+	ri.Encodings = encodings
+	ri.MimeType = DetectMimeType(r.Header, decoded)
 
-		ri.Post_Params = postFormVals
-	} else {
-		body := &strings.Builder{}
-		defer r.Body.Close()
-		if _, err := io.Copy(body, r.Body); err == nil {
-			ri.Body = body.String()
+	ri.Post_Params = make(url.Values)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" && !ri.Truncated {
+		if postFormVals, err := url.ParseQuery(string(ri.Body)); err == nil {
+			ri.Post_Params = postFormVals
 		}
 	}
 
 	return ri
 }
 
+// requestScheme is the scheme r was sent with, https for requests read
+// off a TLS connection whose URL doesn't say.
+func requestScheme(r http.Request) string {
+	switch {
+	case r.URL.Scheme != "":
+		return r.URL.Scheme
+	case r.TLS != nil:
+		return "https"
+	default:
+		return "http"
+	}
+}
+
+// MakeRequest rebuilds a stored request so it can be sent again, to the
+// scheme it was captured with.
 func MakeRequest(ri *models.Request) (*http.Request, error) {
+	rawBody := ri.Body
+	if len(rawBody) == 0 && len(ri.Post_Params) > 0 {
+		rawBody = []byte(url.Values(ri.Post_Params).Encode())
+	}
+
 	var body io.Reader
-	if ri.Body != "" {
-		body = strings.NewReader(ri.Body)
+	if len(rawBody) > 0 {
+		body = bytes.NewReader(rawBody)
 	}
 
+	scheme := ri.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
 	r, err := http.NewRequest(
 		ri.Method,
-		fmt.Sprintf("http://%s%s", ri.Host, ri.Path),
+		fmt.Sprintf("%s://%s%s", scheme, ri.Host, ri.Path),
 		body,
 	)
 	if err != nil {
@@ -86,11 +116,5 @@ func MakeRequest(ri *models.Request) (*http.Request, error) {
 		}
 	}
 
-	for param, valList := range ri.Post_Params {
-		for _, val := range valList {
-			r.PostForm.Add(param, val)
-		}
-	}
-
 	return r, nil
 }
@@ -1,15 +1,19 @@
 package http
 
 import (
-	"io"
 	"net/http"
 	"proxy/internal/models"
-	"strings"
 )
 
-func ParseResponse(r http.Response) models.Response {
+// ParseResponse converts r into its stored form, with the body taken from
+// the capture that was attached to r's body while it streamed to the client.
+func ParseResponse(r http.Response, body *BodyCapture) models.Response {
 	ri := models.Response{
-		Code: r.StatusCode,
+		Code:      r.StatusCode,
+		Proto:     r.Proto,
+		Body:      body.Bytes(),
+		BodySize:  body.Size(),
+		Truncated: body.Truncated(),
 	}
 
 	headers := make(http.Header)
@@ -18,11 +22,10 @@ func ParseResponse(r http.Response) models.Response {
 	}
 	ri.Headers = headers
 
-	body := &strings.Builder{}
-	defer r.Body.Close()
-	if _, err := io.Copy(body, r.Body); err == nil {
-		ri.Body = body.String()
-	}
+	decoded, encodings := body.decode(r.Header, r.TransferEncoding)
+	ri.DecodedBody, _ = DecodedText(decoded)
+	ri.Encodings = encodings
+	ri.MimeType = DetectMimeType(r.Header, decoded)
 
 	return ri
 }
@@ -0,0 +1,102 @@
+// Package rewrite compiles match and replace rules and applies them to the
+// text of a message: its first line, its header lines or its body.
+package rewrite
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"proxy/internal/models"
+	"proxy/pkg/scope"
+)
+
+// Rule is a compiled models.Rule.
+type Rule struct {
+	models.Rule
+	host  *regexp.Regexp
+	match *regexp.Regexp
+}
+
+// Compile checks r and prepares it for matching.
+func Compile(r models.Rule) (*Rule, error) {
+	switch r.Target {
+	case models.RuleRequestLine, models.RuleRequestBody, models.RuleResponseStatus, models.RuleResponseBody:
+		if r.Match == "" {
+			return nil, errors.New("match is empty")
+		}
+	case models.RuleRequestHeader, models.RuleResponseHeader:
+		if r.Match == "" && strings.TrimSpace(r.Replace) == "" {
+			return nil, errors.New("match and replace are empty")
+		}
+	default:
+		return nil, fmt.Errorf("unknown target %q", r.Target)
+	}
+
+	c := &Rule{Rule: r}
+	if r.Host != "" {
+		host, err := scope.HostPattern(r.Host)
+		if err != nil {
+			return nil, err
+		}
+		c.host = host
+	}
+	if r.Regex && r.Match != "" {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("match: %w", err)
+		}
+		c.match = re
+	}
+	return c, nil
+}
+
+// AppliesTo tells whether the rule rewrites traffic to host, given
+// without a port.
+func (r *Rule) AppliesTo(host string) bool {
+	return r.host == nil || r.host.MatchString(strings.ToLower(host))
+}
+
+// Apply rewrites s and reports whether that changed it.
+func (r *Rule) Apply(s string) (string, bool) {
+	var out string
+	if r.match != nil {
+		out = r.match.ReplaceAllString(s, r.Replace)
+	} else {
+		out = strings.ReplaceAll(s, r.Match, r.Replace)
+	}
+	return out, out != s
+}
+
+// ApplyLines rewrites header lines of the form "Name: value". A rule
+// with an empty match adds its replacement as new lines. Lines replaced
+// by nothing are dropped, a replacement may also span several lines.
+func (r *Rule) ApplyLines(lines []string) ([]string, bool) {
+	if r.Match == "" {
+		return append(lines, splitLines(r.Replace)...), true
+	}
+
+	out := make([]string, 0, len(lines))
+	changed := false
+	for _, line := range lines {
+		replaced, ok := r.Apply(line)
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+		changed = true
+		out = append(out, splitLines(replaced)...)
+	}
+	return out, changed
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
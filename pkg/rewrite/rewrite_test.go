@@ -0,0 +1,124 @@
+package rewrite
+
+import (
+	"reflect"
+	"testing"
+
+	"proxy/internal/models"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []models.Rule{
+		{Target: "cookie", Match: "a"},
+		{Target: models.RuleRequestBody},
+		{Target: models.RuleRequestHeader},
+		{Target: models.RuleResponseBody, Match: "(", Regex: true},
+		{Target: models.RuleRequestLine, Match: "a", Host: "example.com:443"},
+	}
+
+	for _, rule := range tests {
+		if _, err := Compile(rule); err == nil {
+			t.Errorf("Compile(%+v): expected an error", rule)
+		}
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	tests := []struct {
+		host  string
+		on    string
+		match bool
+	}{
+		{"", "anything.test", true},
+		{"example.com", "example.com", true},
+		{"example.com", "EXAMPLE.com", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "example.com.evil.test", false},
+		{"api.*", "api.test", true},
+	}
+
+	for _, tt := range tests {
+		rule, err := Compile(models.Rule{Target: models.RuleRequestBody, Match: "a", Host: tt.host})
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		if got := rule.AppliesTo(tt.on); got != tt.match {
+			t.Errorf("host %q AppliesTo(%q) = %v, want %v", tt.host, tt.on, got, tt.match)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		rule    models.Rule
+		in      string
+		want    string
+		changed bool
+	}{
+		{models.Rule{Match: "a.b", Replace: "x"}, "a.b aab", "x aab", true},
+		{models.Rule{Match: "a.b", Replace: "x", Regex: true}, "a.b aab", "x x", true},
+		{models.Rule{Match: `user=(\w+)`, Replace: "user=admin&was=$1", Regex: true}, "user=bob", "user=admin&was=bob", true},
+		{models.Rule{Match: "missing", Replace: "x"}, "text", "text", false},
+	}
+
+	for _, tt := range tests {
+		tt.rule.Target = models.RuleResponseBody
+		rule, err := Compile(tt.rule)
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		got, changed := rule.Apply(tt.in)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("%+v on %q = %q, %v, want %q, %v", tt.rule, tt.in, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestApplyLines(t *testing.T) {
+	lines := []string{"Accept: */*", "User-Agent: curl/8.0", "X-Debug: 1"}
+	tests := []struct {
+		rule    models.Rule
+		want    []string
+		changed bool
+	}{
+		{
+			models.Rule{Replace: "X-Forwarded-For: 127.0.0.1"},
+			[]string{"Accept: */*", "User-Agent: curl/8.0", "X-Debug: 1", "X-Forwarded-For: 127.0.0.1"},
+			true,
+		},
+		{
+			models.Rule{Match: `^User-Agent: .*$`, Replace: "User-Agent: proxy", Regex: true},
+			[]string{"Accept: */*", "User-Agent: proxy", "X-Debug: 1"},
+			true,
+		},
+		{
+			models.Rule{Match: `^X-Debug:.*$`, Regex: true},
+			[]string{"Accept: */*", "User-Agent: curl/8.0"},
+			true,
+		},
+		{
+			models.Rule{Match: "Accept: */*", Replace: "Accept: text/html\r\nAccept-Language: en"},
+			[]string{"Accept: text/html", "Accept-Language: en", "User-Agent: curl/8.0", "X-Debug: 1"},
+			true,
+		},
+		{
+			models.Rule{Match: "Cookie"},
+			lines,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt.rule.Target = models.RuleRequestHeader
+		rule, err := Compile(tt.rule)
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		got, changed := rule.ApplyLines(append([]string{}, lines...))
+		if !reflect.DeepEqual(got, tt.want) || changed != tt.changed {
+			t.Errorf("%+v = %q, %v, want %q, %v", tt.rule, got, changed, tt.want, tt.changed)
+		}
+	}
+}
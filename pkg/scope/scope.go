@@ -0,0 +1,174 @@
+// Package scope decides whether traffic falls into the target scope of a
+// project, as set by its include and exclude rules.
+package scope
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"proxy/internal/models"
+)
+
+// HostPattern compiles a host or a glob such as *.example.com into an
+// expression matching lowercase host names without a port.
+func HostPattern(glob string) (*regexp.Regexp, error) {
+	if strings.ContainsAny(glob, " /:") {
+		return nil, fmt.Errorf("host %q is not a host name or glob", glob)
+	}
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(glob)), `\*`, `.*`)
+	return regexp.Compile(`^` + pattern + `$`)
+}
+
+// Scope is a compiled set of scope rules. The nil Scope has everything in
+// scope.
+type Scope struct {
+	include []*rule
+	exclude []*rule
+}
+
+type rule struct {
+	scheme string
+	host   *regexp.Regexp
+	port   int
+	path   *regexp.Regexp
+}
+
+// Compile checks rules and prepares them for matching.
+func Compile(rules []models.ScopeRule) (*Scope, error) {
+	s := &Scope{}
+	for _, r := range rules {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, err
+		}
+		switch r.Kind {
+		case models.ScopeInclude:
+			s.include = append(s.include, c)
+		case models.ScopeExclude:
+			s.exclude = append(s.exclude, c)
+		}
+	}
+	return s, nil
+}
+
+func compileRule(r models.ScopeRule) (*rule, error) {
+	if r.Kind != models.ScopeInclude && r.Kind != models.ScopeExclude {
+		return nil, fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	c := &rule{scheme: strings.ToLower(r.Scheme), port: r.Port}
+	switch c.scheme {
+	case "", "http", "https":
+	default:
+		return nil, fmt.Errorf("unknown scheme %q", r.Scheme)
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		return nil, errors.New("port out of range")
+	}
+
+	var err error
+	if r.Host != "" {
+		if c.host, err = HostPattern(r.Host); err != nil {
+			return nil, err
+		}
+	}
+	if r.Path != "" {
+		if c.path, err = regexp.Compile(r.Path); err != nil {
+			return nil, fmt.Errorf("path: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Contains tells whether traffic to u is in scope. URLs without a port
+// are on the default port of their scheme.
+func (s *Scope) Contains(u *url.URL) bool {
+	if s == nil {
+		return true
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := Port(scheme, u.Port())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	covers := func(r *rule) bool {
+		return r.coversHost(host, port) && (r.scheme == "" || r.scheme == scheme) &&
+			(r.path == nil || r.path.MatchString(path))
+	}
+	return s.decide(covers)
+}
+
+// ContainsHost tells whether some traffic to host and port, of whichever
+// scheme and path, may be in scope. It is how a connection is judged
+// before any request on it is seen.
+func (s *Scope) ContainsHost(host string, port int) bool {
+	if s == nil {
+		return true
+	}
+	host = strings.ToLower(host)
+
+	for _, r := range s.exclude {
+		if r.scheme == "" && r.path == nil && r.coversHost(host, port) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, r := range s.include {
+		if r.coversHost(host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) decide(covers func(r *rule) bool) bool {
+	for _, r := range s.exclude {
+		if covers(r) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, r := range s.include {
+		if covers(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rule) coversHost(host string, port int) bool {
+	return (r.host == nil || r.host.MatchString(host)) && (r.port == 0 || r.port == port)
+}
+
+// Port returns the port of a URL or host:port, the default one of scheme
+// when it has none.
+func Port(scheme, port string) int {
+	if n, err := strconv.Atoi(port); err == nil {
+		return n
+	}
+	if strings.EqualFold(scheme, "https") || strings.EqualFold(scheme, "wss") {
+		return 443
+	}
+	return 80
+}
+
+// SplitHostPort splits an address, taking the port to be the default one
+// of scheme when it has none.
+func SplitHostPort(scheme, hostport string) (string, int) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]"), Port(scheme, "")
+	}
+	return host, Port(scheme, port)
+}
@@ -0,0 +1,93 @@
+package scope
+
+import (
+	"net/url"
+	"testing"
+
+	"proxy/internal/models"
+)
+
+func TestContains(t *testing.T) {
+	rules := []models.ScopeRule{
+		{Kind: models.ScopeInclude, Host: "*.example.com"},
+		{Kind: models.ScopeInclude, Scheme: "https", Host: "example.com", Port: 8443},
+		{Kind: models.ScopeExclude, Host: "cdn.example.com"},
+		{Kind: models.ScopeExclude, Path: `\.(png|css)$`},
+	}
+	s, err := Compile(rules)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	tests := []struct {
+		url string
+		in  bool
+	}{
+		{"http://api.example.com/users", true},
+		{"https://API.example.com:444/", true},
+		{"http://example.com/", false},
+		{"https://example.com:8443/login", true},
+		{"http://example.com:8443/login", false},
+		{"https://cdn.example.com/app.js", false},
+		{"https://www.example.com/logo.png", false},
+		{"https://www.example.com/logo.png.html", true},
+		{"https://telemetry.browser.test/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := s.Contains(u); got != tt.in {
+			t.Errorf("Contains(%s) = %v, want %v", tt.url, got, tt.in)
+		}
+	}
+
+	hosts := []struct {
+		host string
+		port int
+		in   bool
+	}{
+		{"api.example.com", 443, true},
+		{"example.com", 8443, true},
+		{"example.com", 443, false},
+		// Only the images of www are out of scope.
+		{"www.example.com", 443, true},
+		{"cdn.example.com", 443, false},
+		{"telemetry.browser.test", 443, false},
+	}
+	for _, tt := range hosts {
+		if got := s.ContainsHost(tt.host, tt.port); got != tt.in {
+			t.Errorf("ContainsHost(%s, %d) = %v, want %v", tt.host, tt.port, got, tt.in)
+		}
+	}
+}
+
+func TestEverythingInScope(t *testing.T) {
+	u, _ := url.Parse("http://anything.test/")
+
+	var none *Scope
+	if !none.Contains(u) || !none.ContainsHost("anything.test", 80) {
+		t.Errorf("the nil scope does not have everything in scope")
+	}
+
+	s, err := Compile([]models.ScopeRule{{Kind: models.ScopeExclude, Host: "ads.test"}})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if !s.Contains(u) || s.ContainsHost("ads.test", 443) {
+		t.Errorf("a scope of exclude rules only decides wrongly")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []models.ScopeRule{
+		{Kind: "maybe"},
+		{Kind: models.ScopeInclude, Scheme: "ftp"},
+		{Kind: models.ScopeInclude, Port: 70000},
+		{Kind: models.ScopeInclude, Host: "example.com:443"},
+		{Kind: models.ScopeInclude, Path: "("},
+	}
+	for _, rule := range tests {
+		if _, err := Compile([]models.ScopeRule{rule}); err == nil {
+			t.Errorf("Compile(%+v): expected an error", rule)
+		}
+	}
+}
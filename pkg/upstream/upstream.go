@@ -0,0 +1,205 @@
+// Package upstream chains outgoing connections through other proxies,
+// picked by the host they go to.
+package upstream
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"proxy/pkg/config"
+	"proxy/pkg/scope"
+
+	"golang.org/x/net/proxy"
+)
+
+// Router picks the upstream proxy for a host. The nil Router, like one
+// without routes, sends everything direct.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	host  *regexp.Regexp
+	proxy *url.URL // nil to go direct
+}
+
+// New checks the configured upstreams and compiles them in order.
+func New(upstreams []config.Upstream) (*Router, error) {
+	r := &Router{}
+	for _, up := range upstreams {
+		glob := up.Host
+		if glob == "" {
+			glob = "*"
+		}
+		host, err := scope.HostPattern(glob)
+		if err != nil {
+			return nil, fmt.Errorf("upstream: %w", err)
+		}
+
+		var proxyURL *url.URL
+		if up.URL != "" {
+			if proxyURL, err = parseProxyURL(up.URL); err != nil {
+				return nil, fmt.Errorf("upstream for %s: %w", glob, err)
+			}
+		}
+		r.routes = append(r.routes, route{host: host, proxy: proxyURL})
+	}
+	return r, nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		// Hostnames always reach SOCKS proxies unresolved.
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy %q has no host", raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPorts[u.Scheme])
+	}
+	return u, nil
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443", "socks5": "1080"}
+
+// For returns the proxy traffic to host goes through, nil for none.
+func (r *Router) For(host string) *url.URL {
+	if r == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	for _, rt := range r.routes {
+		if rt.host.MatchString(host) {
+			return rt.proxy
+		}
+	}
+	return nil
+}
+
+// Proxy is meant for http.Transport.Proxy, which speaks all the supported
+// schemes itself.
+func (r *Router) Proxy(req *http.Request) (*url.URL, error) {
+	return r.For(req.URL.Hostname()), nil
+}
+
+// Transport returns a copy of the default transport going through the
+// routes.
+func (r *Router) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = r.Proxy
+	return transport
+}
+
+// Dial opens a TCP connection to addr through the proxy for its host: a
+// CONNECT tunnel through HTTP proxies or a SOCKS5 one. The deadline of
+// ctx covers the whole setup.
+func (r *Router) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	proxyURL := r.For(host)
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	if proxyURL.Scheme == "socks5" {
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, &dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("upstream proxy %s: %w", proxyURL.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	tunnel, err := connect(conn, proxyURL, addr)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy %s: %w", proxyURL.Host, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// connect asks the HTTP proxy on conn for a tunnel to addr.
+func connect(conn net.Conn, proxyURL *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := proxyURL.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+
+	// Bytes the origin sent right after the tunnel opened are already in br.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}